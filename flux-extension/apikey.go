@@ -0,0 +1,126 @@
+package fluxext
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/spf13/cast"
+	"net/http"
+	"time"
+)
+
+const (
+	TypeIdAPIKeyFilter = "apikey_filter"
+)
+
+const (
+	ConfigKeyAPIKeyLookups = "lookups"
+)
+
+var _ flux.Filter = new(APIKeyFilter)
+
+// APIKeyConfig API Key认证配置
+type APIKeyConfig struct {
+	SkipFunc     flux.FilterSkipper
+	AttKeyPrefix string
+	// 查找API Key的Lookup表达式，按顺序查找第一个非空值
+	Lookups []string
+}
+
+func NewAPIKeyFilter(c APIKeyConfig) *APIKeyFilter {
+	return &APIKeyFilter{
+		Config: c,
+	}
+}
+
+// APIKeyFilter 通过API Key查找已注册的Consumer，并校验Consumer允许访问的应用和Endpoint；
+// Consumer信息写入Context属性：<prefix>.id, <prefix>.name, <prefix>.metadata.<key>, <prefix>.quota.<key>
+type APIKeyFilter struct {
+	Config APIKeyConfig
+}
+
+func (f *APIKeyFilter) FilterId() string {
+	return TypeIdAPIKeyFilter
+}
+
+func (f *APIKeyFilter) Init(config *flux.Configuration) error {
+	logger.Info("APIKey filter initializing")
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyAttachmentKey: "consumer",
+		ConfigKeyAPIKeyLookups: []string{"header:X-Api-Key", "query:api_key"},
+	})
+	if "" == f.Config.AttKeyPrefix {
+		f.Config.AttKeyPrefix = config.GetString(ConfigKeyAttachmentKey)
+	}
+	if len(f.Config.Lookups) == 0 {
+		f.Config.Lookups = config.GetStringSlice(ConfigKeyAPIKeyLookups)
+	}
+	if f.Config.SkipFunc == nil {
+		f.Config.SkipFunc = func(_ *flux.Context) bool {
+			return false
+		}
+	}
+	logger.Infow("APIKey config", "lookups", f.Config.Lookups, "attachment-key", f.Config.AttKeyPrefix)
+	return nil
+}
+
+func (f *APIKeyFilter) DoFilter(next flux.FilterInvoker) flux.FilterInvoker {
+	return func(ctx *flux.Context) *flux.ServeError {
+		// Endpoint指定不需要授权
+		if !ctx.Endpoint().Authorize() || f.Config.SkipFunc(ctx) {
+			return next(ctx)
+		}
+		apikey := f.lookupKey(ctx)
+		if apikey == "" {
+			return newAPIKeyError(http.StatusUnauthorized, flux.ErrorCodeApiKeyNotFound, "APIKEY:VALIDATE: api key not found")
+		}
+		consumer, ok := ext.ConsumerByKey(apikey)
+		if !ok || consumer.Disabled {
+			ctx.Logger().Infow("APIKEY:VALIDATE:REJECTED", "consumer-id", consumer.ConsumerId, "disabled", consumer.Disabled)
+			return newAPIKeyError(http.StatusUnauthorized, flux.ErrorCodeApiKeyInvalid, "APIKEY:VALIDATE: api key is invalid")
+		}
+		endpoint := ctx.Endpoint()
+		if !consumer.AllowApplication(ctx.Application()) || !consumer.AllowEndpoint(endpoint.HttpMethod, endpoint.HttpPattern) {
+			ctx.Logger().Infow("APIKEY:CONSUMER:DENIED", "consumer-id", consumer.ConsumerId)
+			return newAPIKeyError(http.StatusForbidden, flux.ErrorCodeConsumerDenied, "APIKEY:CONSUMER: access denied")
+		}
+		setConsumerAttributes(ctx, f.Config.AttKeyPrefix, consumer)
+		ctx.AddMetric(f.FilterId(), time.Since(ctx.StartAt()))
+		return next(ctx)
+	}
+}
+
+func (f *APIKeyFilter) lookupKey(ctx *flux.Context) string {
+	for _, expr := range f.Config.Lookups {
+		value, err := common.LookupMTValueByExpr(expr, ctx)
+		if nil != err {
+			ctx.Logger().Warnw("APIKEY:LOOKUP_KEY", "expr", expr, "error", err)
+			continue
+		}
+		if key := cast.ToString(value); key != "" {
+			return key
+		}
+	}
+	return ""
+}
+
+// setConsumerAttributes 将Consumer信息写入Context属性
+func setConsumerAttributes(ctx *flux.Context, prefix string, consumer flux.Consumer) {
+	ctx.SetAttribute(prefix+".id", consumer.ConsumerId)
+	ctx.SetAttribute(prefix+".name", consumer.Name)
+	for k, v := range consumer.Metadata {
+		ctx.SetAttribute(prefix+".metadata."+k, v)
+	}
+	for k, v := range consumer.Quotas {
+		ctx.SetAttribute(prefix+".quota."+k, v)
+	}
+}
+
+func newAPIKeyError(status int, code, message string) *flux.ServeError {
+	return &flux.ServeError{
+		StatusCode: status,
+		ErrorCode:  code,
+		Message:    message,
+	}
+}
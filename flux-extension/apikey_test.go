@@ -0,0 +1,80 @@
+package fluxext
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	assert2 "github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func newAPIKeyContext(application, method, pattern string, headers map[string]string) *flux.Context {
+	endpoint := newAuthorizeEndpoint()
+	endpoint.Application, endpoint.HttpMethod, endpoint.HttpPattern = application, method, pattern
+	return newTestContext(endpoint, headers)
+}
+
+func TestAPIKeyFilter(t *testing.T) {
+	assert := assert2.New(t)
+	ext.RegisterConsumer(flux.Consumer{
+		ConsumerId: "apikey.shop", Name: "Shop", KeyHashes: []string{ext.HashConsumerKey("k-shop")},
+		Applications: []string{"shop"}, Endpoints: []string{"/orders", "POST#/payments"},
+		Metadata: map[string]string{"tier": "gold"}, Quotas: map[string]int64{"daily": 1000},
+	})
+	ext.RegisterConsumer(flux.Consumer{
+		ConsumerId: "apikey.disabled", KeyHashes: []string{ext.HashConsumerKey("k-disabled")}, Disabled: true,
+	})
+	defer ext.RemoveConsumerByID("apikey.shop")
+	defer ext.RemoveConsumerByID("apikey.disabled")
+	filter := NewAPIKeyFilter(APIKeyConfig{})
+	initTestFilter(t, filter, "apikey.test", nil)
+	cases := []struct {
+		name        string
+		application string
+		method      string
+		pattern     string
+		key         string
+		status      int
+		code        string
+	}{
+		{name: "allowed", application: "shop", method: http.MethodGet, pattern: "/orders", key: "k-shop"},
+		{name: "allowed-method", application: "shop", method: http.MethodPost, pattern: "/payments", key: "k-shop"},
+		{name: "missing", application: "shop", method: http.MethodGet, pattern: "/orders",
+			status: http.StatusUnauthorized, code: flux.ErrorCodeApiKeyNotFound},
+		{name: "unknown", application: "shop", method: http.MethodGet, pattern: "/orders", key: "k-unknown",
+			status: http.StatusUnauthorized, code: flux.ErrorCodeApiKeyInvalid},
+		{name: "disabled", application: "shop", method: http.MethodGet, pattern: "/orders", key: "k-disabled",
+			status: http.StatusUnauthorized, code: flux.ErrorCodeApiKeyInvalid},
+		{name: "application-denied", application: "admin", method: http.MethodGet, pattern: "/orders", key: "k-shop",
+			status: http.StatusForbidden, code: flux.ErrorCodeConsumerDenied},
+		{name: "endpoint-denied", application: "shop", method: http.MethodGet, pattern: "/payments", key: "k-shop",
+			status: http.StatusForbidden, code: flux.ErrorCodeConsumerDenied},
+	}
+	for _, c := range cases {
+		headers := map[string]string{}
+		if c.key != "" {
+			headers["X-Api-Key"] = c.key
+		}
+		serr := invokeTestFilter(filter, newAPIKeyContext(c.application, c.method, c.pattern, headers))
+		if c.code == "" {
+			assert.Nil(serr, c.name)
+		} else if assert.NotNil(serr, c.name) {
+			assert.Equal(c.status, serr.StatusCode, c.name)
+			assert.Equal(c.code, serr.ErrorCode, c.name)
+		}
+	}
+	// Consumer信息写入Context属性；按顺序查找Query参数
+	ctx := newAPIKeyContext("shop", http.MethodGet, "/orders", nil)
+	ctx.Request().URL.RawQuery = "api_key=k-shop"
+	assert.Nil(invokeTestFilter(filter, ctx))
+	for key, expected := range map[string]interface{}{
+		"consumer.id": "apikey.shop", "consumer.name": "Shop",
+		"consumer.metadata.tier": "gold", "consumer.quota.daily": int64(1000),
+	} {
+		value, ok := ctx.GetAttribute(key)
+		assert.True(ok, key)
+		assert.Equal(expected, value, key)
+	}
+	// Endpoint不需要授权
+	assert.Nil(invokeTestFilter(filter, newTestContext(flux.Endpoint{}, nil)))
+}
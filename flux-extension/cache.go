@@ -0,0 +1,136 @@
+package fluxext
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// expiringCache 有过期时间和容量上限的LRU缓存
+type expiringCache struct {
+	mu    sync.Mutex
+	size  int
+	items map[string]*list.Element
+	order *list.List
+}
+
+type expiringEntry struct {
+	key      string
+	value    interface{}
+	expireAt time.Time
+}
+
+func newExpiringCache(size int) *expiringCache {
+	if size <= 0 {
+		size = 1024
+	}
+	return &expiringCache{
+		size:  size,
+		items: make(map[string]*list.Element, size),
+		order: list.New(),
+	}
+}
+
+// Get 返回未过期的缓存值
+func (c *expiringCache) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*expiringEntry)
+	if time.Now().After(entry.expireAt) {
+		c.remove(elem)
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return entry.value, true
+}
+
+// Set 设置缓存值；超过容量时淘汰最久未使用的缓存
+func (c *expiringCache) Set(key string, value interface{}, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expireAt := time.Now().Add(ttl)
+	if elem, ok := c.items[key]; ok {
+		entry := elem.Value.(*expiringEntry)
+		entry.value, entry.expireAt = value, expireAt
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.items[key] = c.order.PushFront(&expiringEntry{key: key, value: value, expireAt: expireAt})
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+// Delete 删除缓存值
+func (c *expiringCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		c.remove(elem)
+	}
+}
+
+// DeleteFunc 删除满足条件的缓存值，返回删除的数量
+func (c *expiringCache) DeleteFunc(match func(key string, value interface{}) bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	removed := 0
+	for elem := c.order.Front(); elem != nil; {
+		next := elem.Next()
+		if entry := elem.Value.(*expiringEntry); match(entry.key, entry.value) {
+			c.remove(elem)
+			removed++
+		}
+		elem = next
+	}
+	return removed
+}
+
+func (c *expiringCache) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.items, elem.Value.(*expiringEntry).key)
+}
+
+// callGroup 合并相同Key的并发调用，只执行一次
+type callGroup struct {
+	mu    sync.Mutex
+	calls map[string]*groupCall
+}
+
+type groupCall struct {
+	wg    sync.WaitGroup
+	value interface{}
+	err   error
+}
+
+// Do 执行函数；相同Key的调用执行中时，等待并共享其结果
+func (g *callGroup) Do(key string, fn func() (interface{}, error)) (value interface{}, err error, shared bool) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*groupCall)
+	}
+	if call, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		call.wg.Wait()
+		return call.value, call.err, true
+	}
+	call := new(groupCall)
+	call.wg.Add(1)
+	g.calls[key] = call
+	g.mu.Unlock()
+	defer func() {
+		call.wg.Done()
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+	}()
+	call.value, call.err = fn()
+	return call.value, call.err, false
+}
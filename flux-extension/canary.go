@@ -0,0 +1,114 @@
+package fluxext
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/bytepowered/flux/flux-pkg"
+	"hash/fnv"
+	"strings"
+	"sync/atomic"
+)
+
+// Canary属性定义在各版本的Endpoint上
+const (
+	// 版本的流量权重；未定义权重的版本不参与按权重分流
+	EndpointAttrTagCanaryWeight = "canaryWeight"
+	// 粘性分流的Key查找表达式，例如：header:X-User-Id, cookie:uid, query:uid
+	EndpointAttrTagCanaryHashKey = "canaryHashKey"
+	// 版本的匹配规则，例如：header:X-Env=gray, query:tag=beta；多个规则需要同时匹配；值为*时，表示参数存在即匹配
+	EndpointAttrTagCanaryMatch = "canaryMatch"
+)
+
+var _ flux.EndpointSelector = new(CanarySelector)
+
+// NewCanarySelector 创建Canary版本选择器；请求指定版本Header时，不参与选择。
+func NewCanarySelector(versionHeader string) *CanarySelector {
+	return &CanarySelector{versionHeader: versionHeader}
+}
+
+// CanarySelector 根据Endpoint的Canary属性选择版本：
+// 1. 按版本号顺序，选择匹配规则全部匹配的版本；
+// 2. 按权重分流：存在粘性Key时，按Key的哈希值选择；否则按请求顺序轮询分配。
+type CanarySelector struct {
+	versionHeader string
+	counter       uint64
+}
+
+func (s *CanarySelector) Active(webex flux.ServerWebContext, _ string) bool {
+	return s.versionHeader == "" || webex.HeaderVar(s.versionHeader) == ""
+}
+
+func (s *CanarySelector) DoSelect(webex flux.ServerWebContext, _ string, multi *flux.MVCEndpoint) (flux.Endpoint, bool) {
+	endpoints := multi.Endpoints()
+	if len(endpoints) < 2 {
+		return flux.Endpoint{}, false
+	}
+	// Match rules
+	for _, ep := range endpoints {
+		if rules := canaryRules(ep); len(rules) > 0 && s.matches(webex, rules) {
+			return *ep, true
+		}
+	}
+	// Weights
+	total := 0
+	weights := make([]int, len(endpoints))
+	hashKey := ""
+	for i, ep := range endpoints {
+		if w := ep.GetAttr(EndpointAttrTagCanaryWeight).GetInt(); w > 0 {
+			weights[i] = w
+			total += w
+		}
+		if hashKey == "" {
+			hashKey = ep.GetAttr(EndpointAttrTagCanaryHashKey).GetString()
+		}
+	}
+	if total == 0 {
+		return flux.Endpoint{}, false
+	}
+	var point uint64
+	if key := common.LookupWebValueByExpr(webex, hashKey); key != "" {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(key))
+		point = uint64(h.Sum32()) % uint64(total)
+	} else {
+		point = (atomic.AddUint64(&s.counter, 1) - 1) % uint64(total)
+	}
+	for i, w := range weights {
+		if point < uint64(w) {
+			return *endpoints[i], true
+		}
+		point -= uint64(w)
+	}
+	return flux.Endpoint{}, false
+}
+
+func (s *CanarySelector) matches(webex flux.ServerWebContext, rules []string) bool {
+	for _, rule := range rules {
+		idx := strings.Index(rule, "=")
+		if idx <= 0 {
+			return false
+		}
+		scope, key, ok := fluxpkg.LookupParseExpr(strings.TrimSpace(rule[:idx]))
+		if !ok {
+			return false
+		}
+		expected := strings.TrimSpace(rule[idx+1:])
+		value := common.LookupWebValue(webex, scope, key)
+		if value == "" || (expected != "*" && expected != value) {
+			return false
+		}
+	}
+	return true
+}
+
+func canaryRules(ep *flux.Endpoint) []string {
+	rules := make([]string, 0, 2)
+	for _, attr := range ep.GetAttrs(EndpointAttrTagCanaryMatch) {
+		for _, r := range attr.GetStringSlice() {
+			if r = strings.TrimSpace(r); r != "" {
+				rules = append(rules, r)
+			}
+		}
+	}
+	return rules
+}
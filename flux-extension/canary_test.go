@@ -0,0 +1,118 @@
+package fluxext
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	assert2 "github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func newCanaryEndpoints(endpoints ...*flux.Endpoint) *flux.MVCEndpoint {
+	multi := flux.NewMVCEndpoint(endpoints[0])
+	for _, ep := range endpoints[1:] {
+		multi.Update(ep.Version, ep)
+	}
+	return multi
+}
+
+func newCanaryEndpoint(version string, attrs ...flux.Attribute) *flux.Endpoint {
+	return &flux.Endpoint{Version: version, EmbeddedAttributes: flux.EmbeddedAttributes{Attributes: attrs}}
+}
+
+func newCanaryWebContext(target string, headers map[string]string) flux.ServerWebContext {
+	webex := common.MockWebContext("canary")
+	request := httptest.NewRequest("GET", target, nil)
+	for k, v := range headers {
+		request.Header.Set(k, v)
+	}
+	*webex.Request() = *request
+	return webex
+}
+
+func TestCanarySelectorWeights(t *testing.T) {
+	assert := assert2.New(t)
+	// 按版本号排序分配权重区间，未定义权重的版本不参与分流
+	multi := newCanaryEndpoints(
+		newCanaryEndpoint("v3"),
+		newCanaryEndpoint("v2", flux.Attribute{Name: EndpointAttrTagCanaryWeight, Value: 3}),
+		newCanaryEndpoint("v1", flux.Attribute{Name: EndpointAttrTagCanaryWeight, Value: 1}),
+	)
+	selector := NewCanarySelector("X-Version")
+	counts := make(map[string]int)
+	for i := 0; i < 400; i++ {
+		ep, ok := selector.DoSelect(newCanaryWebContext("/orders", nil), "", multi)
+		assert.True(ok)
+		counts[ep.Version]++
+	}
+	assert.Equal(map[string]int{"v1": 100, "v2": 300}, counts)
+	// 没有权重定义，或只有一个版本时，不参与选择
+	_, ok := selector.DoSelect(newCanaryWebContext("/orders", nil), "", newCanaryEndpoints(newCanaryEndpoint("v1"), newCanaryEndpoint("v2")))
+	assert.False(ok)
+	_, ok = selector.DoSelect(newCanaryWebContext("/orders", nil), "", newCanaryEndpoints(
+		newCanaryEndpoint("v1", flux.Attribute{Name: EndpointAttrTagCanaryWeight, Value: 1}),
+	))
+	assert.False(ok)
+	// 请求指定版本时，不参与选择
+	assert.True(selector.Active(newCanaryWebContext("/orders", nil), ""))
+	assert.False(selector.Active(newCanaryWebContext("/orders", map[string]string{"X-Version": "v1"}), ""))
+	assert.True(NewCanarySelector("").Active(newCanaryWebContext("/orders", map[string]string{"X-Version": "v1"}), ""))
+}
+
+func TestCanarySelectorStickyKey(t *testing.T) {
+	assert := assert2.New(t)
+	multi := newCanaryEndpoints(
+		newCanaryEndpoint("v1", flux.Attribute{Name: EndpointAttrTagCanaryWeight, Value: 50},
+			flux.Attribute{Name: EndpointAttrTagCanaryHashKey, Value: "header:X-User-Id"}),
+		newCanaryEndpoint("v2", flux.Attribute{Name: EndpointAttrTagCanaryWeight, Value: 50}),
+	)
+	selector := NewCanarySelector("")
+	counts := make(map[string]int)
+	for i := 0; i < 200; i++ {
+		headers := map[string]string{"X-User-Id": "user-" + strconv.Itoa(i)}
+		first, ok := selector.DoSelect(newCanaryWebContext("/orders", headers), "", multi)
+		assert.True(ok)
+		counts[first.Version]++
+		// 相同Key总是选择相同的版本
+		for j := 0; j < 5; j++ {
+			ep, _ := selector.DoSelect(newCanaryWebContext("/orders", headers), "", multi)
+			assert.Equal(first.Version, ep.Version, headers["X-User-Id"])
+		}
+	}
+	assert.True(counts["v1"] > 50 && counts["v2"] > 50, "counts: %v", counts)
+}
+
+func TestCanarySelectorMatchRules(t *testing.T) {
+	assert := assert2.New(t)
+	multi := newCanaryEndpoints(
+		newCanaryEndpoint("v1", flux.Attribute{Name: EndpointAttrTagCanaryWeight, Value: 1}),
+		newCanaryEndpoint("v2",
+			flux.Attribute{Name: EndpointAttrTagCanaryMatch, Value: []string{"header:X-Env=gray"}},
+			flux.Attribute{Name: EndpointAttrTagCanaryMatch, Value: "query:tag=*"}),
+		newCanaryEndpoint("v3", flux.Attribute{Name: EndpointAttrTagCanaryMatch, Value: []string{"header:X-Env = *"}}),
+	)
+	selector := NewCanarySelector("")
+	cases := []struct {
+		target  string
+		env     string
+		version string
+	}{
+		// 多个版本匹配时，按版本号顺序选择
+		{target: "/orders?tag=beta", env: "gray", version: "v2"},
+		// 多个规则需要同时匹配
+		{target: "/orders", env: "gray", version: "v3"},
+		{target: "/orders", env: "beta", version: "v3"},
+		{target: "/orders?tag=beta", env: "", version: "v1"},
+		{target: "/orders", env: "", version: "v1"},
+	}
+	for _, c := range cases {
+		headers := map[string]string{}
+		if c.env != "" {
+			headers["X-Env"] = c.env
+		}
+		ep, ok := selector.DoSelect(newCanaryWebContext(c.target, headers), "", multi)
+		assert.True(ok, c.target)
+		assert.Equal(c.version, ep.Version, c.target+", env: "+c.env)
+	}
+}
@@ -0,0 +1,66 @@
+package fluxext
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	assert2 "github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func init() {
+	ext.SetLoggerFactory(logger.DefaultFactory)
+	ext.RegisterSerializer(ext.TypeNameSerializerJson, flux.NewJsonSerializer())
+}
+
+// testFilter 测试使用的Filter，需要支持初始化
+type testFilter interface {
+	flux.Filter
+	flux.Initializer
+}
+
+// newTestContext 创建绑定Endpoint的Context，并设置请求Header
+func newTestContext(endpoint flux.Endpoint, headers map[string]string) *flux.Context {
+	ctx := flux.NewContext()
+	ctx.Reset(common.MockWebContext("test"), &endpoint)
+	for k, v := range headers {
+		ctx.Request().Header.Set(k, v)
+	}
+	return ctx
+}
+
+// newRemoteContext 创建指定客户端地址的Context
+func newRemoteContext(endpoint flux.Endpoint, remote string, headers map[string]string) *flux.Context {
+	ctx := newTestContext(endpoint, headers)
+	ctx.Request().RemoteAddr = remote
+	return ctx
+}
+
+// newAuthorizeEndpoint 创建需要授权的Endpoint
+func newAuthorizeEndpoint() flux.Endpoint {
+	return flux.Endpoint{EmbeddedAttributes: flux.EmbeddedAttributes{
+		Attributes: []flux.Attribute{{Name: flux.EndpointAttrTagAuthorize, Value: true}},
+	}}
+}
+
+// newBearerContext 创建需要授权，并携带Bearer Token的Context
+func newBearerContext(token string) *flux.Context {
+	return newTestContext(newAuthorizeEndpoint(), map[string]string{flux.HeaderAuthorization: "Bearer " + token})
+}
+
+// initTestFilter 使用独立命名空间的配置初始化Filter
+func initTestFilter(t *testing.T, filter testFilter, ns string, settings map[string]interface{}) {
+	config := flux.NewConfiguration(ns)
+	for k, v := range settings {
+		config.Set(k, v)
+	}
+	assert2.NoError(t, filter.Init(config))
+}
+
+// invokeTestFilter 执行Filter，后续处理直接返回成功
+func invokeTestFilter(filter flux.Filter, ctx *flux.Context) *flux.ServeError {
+	return filter.DoFilter(func(_ *flux.Context) *flux.ServeError {
+		return nil
+	})(ctx)
+}
@@ -0,0 +1,95 @@
+package fluxext
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+)
+
+// GeoIPResolver 查询IP地址所属的国家代码(ISO 3166-1 alpha-2)
+type GeoIPResolver interface {
+	Country(ip net.IP) (string, bool)
+}
+
+// GeoIPFile 本地GeoIP数据文件；每行格式为：<CIDR>,<国家代码>[,...]，
+// 以#开头的注释行、空行和首列不是CIDR的标题行被忽略；网段之间不能重叠。
+type GeoIPFile struct {
+	ranges []geoIPRange
+}
+
+type geoIPRange struct {
+	start   net.IP
+	end     net.IP
+	country string
+}
+
+// LoadGeoIPFile 加载并索引GeoIP数据文件
+func LoadGeoIPFile(path string) (*GeoIPFile, error) {
+	file, err := os.Open(path)
+	if nil != err {
+		return nil, err
+	}
+	defer file.Close()
+	ranges := make([]geoIPRange, 0, 1024)
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Split(text, ",")
+		_, ipnet, err := net.ParseCIDR(strings.TrimSpace(fields[0]))
+		if nil != err {
+			// 标题行
+			continue
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("geoip: invalid line: %d, file: %s", line, path)
+		}
+		start := ipnet.IP.To16()
+		end := make(net.IP, len(start))
+		mask := ipnet.Mask
+		if len(mask) == net.IPv4len {
+			mask = append(net.CIDRMask(96, 128)[:12], mask...)
+		}
+		for i := range start {
+			end[i] = start[i] | ^mask[i]
+		}
+		ranges = append(ranges, geoIPRange{
+			start:   start,
+			end:     end,
+			country: strings.ToUpper(strings.Trim(strings.TrimSpace(fields[1]), `"`)),
+		})
+	}
+	if err := scanner.Err(); nil != err {
+		return nil, err
+	}
+	sort.Slice(ranges, func(i, j int) bool {
+		return bytes.Compare(ranges[i].start, ranges[j].start) < 0
+	})
+	return &GeoIPFile{ranges: ranges}, nil
+}
+
+// Country 二分查找IP地址所在的网段
+func (g *GeoIPFile) Country(ip net.IP) (string, bool) {
+	ip16 := ip.To16()
+	if ip16 == nil {
+		return "", false
+	}
+	idx := sort.Search(len(g.ranges), func(i int) bool {
+		return bytes.Compare(g.ranges[i].start, ip16) > 0
+	}) - 1
+	if idx < 0 || bytes.Compare(ip16, g.ranges[idx].end) > 0 {
+		return "", false
+	}
+	return g.ranges[idx].country, true
+}
+
+// Size 返回网段数量
+func (g *GeoIPFile) Size() int {
+	return len(g.ranges)
+}
@@ -0,0 +1,235 @@
+package fluxext
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/bytepowered/flux/flux-pkg"
+	"github.com/spf13/cast"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	TypeIdIntrospectionFilter = "introspection_filter"
+)
+
+const (
+	ConfigKeyIntrospectionUrl     = "introspection_url"
+	ConfigKeyClientId             = "client_id"
+	ConfigKeyClientSecret         = "client_secret"
+	ConfigKeyRequestTimeout       = "request_timeout"
+	ConfigKeyNegativeCacheExpired = "negative_cache_expiration"
+)
+
+const (
+	introspectionFieldActive = "active"
+	introspectionFieldExp    = "exp"
+)
+
+var _ flux.Filter = new(IntrospectionFilter)
+
+type (
+	// IntrospectionResult Token内省结果，RFC 7662
+	IntrospectionResult map[string]interface{}
+	// IntrospectFunc 执行Token内省
+	IntrospectFunc func(ctx *flux.Context, token string) (IntrospectionResult, error)
+)
+
+// Active 返回Token是否有效
+func (r IntrospectionResult) Active() bool {
+	return cast.ToBool(r[introspectionFieldActive])
+}
+
+// ExpiresAt 返回Token的过期时间；未定义时返回零值
+func (r IntrospectionResult) ExpiresAt() time.Time {
+	if exp := cast.ToInt64(r[introspectionFieldExp]); exp > 0 {
+		return time.Unix(exp, 0)
+	}
+	return time.Time{}
+}
+
+// IntrospectionConfig Token内省配置
+type IntrospectionConfig struct {
+	SkipFunc     flux.FilterSkipper
+	AttKeyPrefix string
+	// 查找Token的函数，默认从Header:Authorization和form:access_token中查找
+	TokenExtractor func(ctx *flux.Context) (string, error)
+	// 执行Token内省的函数；默认使用Http客户端请求配置的内省端点
+	IntrospectFunc IntrospectFunc
+}
+
+func NewIntrospectionFilter(c IntrospectionConfig) *IntrospectionFilter {
+	return &IntrospectionFilter{
+		Config: c,
+	}
+}
+
+// IntrospectionFilter 通过OAuth2 Token内省端点验证不透明的AccessToken；
+// 有效的内省结果缓存至Token过期，无效结果短暂缓存；内省字段写入Context属性：<prefix>.<field>
+type IntrospectionFilter struct {
+	Config    IntrospectionConfig
+	cache     *expiringCache
+	maxExpire time.Duration
+	negExpire time.Duration
+	endpoint  string
+	clientId  string
+	secret    string
+	client    *http.Client
+}
+
+func (f *IntrospectionFilter) FilterId() string {
+	return TypeIdIntrospectionFilter
+}
+
+func (f *IntrospectionFilter) Init(config *flux.Configuration) error {
+	logger.Info("Introspection filter initializing")
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyAttachmentKey:        "jwt",
+		ConfigKeyCacheExpiration:      "5m",
+		ConfigKeyNegativeCacheExpired: "10s",
+		ConfigKeyCacheSize:            10000,
+		ConfigKeyRequestTimeout:       "5s",
+	})
+	if "" == f.Config.AttKeyPrefix {
+		f.Config.AttKeyPrefix = config.GetString(ConfigKeyAttachmentKey)
+	}
+	if f.Config.SkipFunc == nil {
+		f.Config.SkipFunc = func(_ *flux.Context) bool {
+			return false
+		}
+	}
+	if f.Config.TokenExtractor == nil {
+		f.Config.TokenExtractor = ExtractTokenOAuth2
+	}
+	f.maxExpire = config.GetDuration(ConfigKeyCacheExpiration)
+	f.negExpire = config.GetDuration(ConfigKeyNegativeCacheExpired)
+	f.cache = newExpiringCache(config.GetInt(ConfigKeyCacheSize))
+	if config.GetBool(ConfigKeyCacheDisabled) {
+		f.maxExpire, f.negExpire = 0, 0
+	}
+	if f.Config.IntrospectFunc == nil {
+		f.endpoint = config.GetString(ConfigKeyIntrospectionUrl)
+		f.clientId = config.GetString(ConfigKeyClientId)
+		f.secret = config.GetString(ConfigKeyClientSecret)
+		f.client = &http.Client{Timeout: config.GetDuration(ConfigKeyRequestTimeout)}
+		fluxpkg.Assert(f.endpoint != "", "<introspection_url> must not empty")
+		f.Config.IntrospectFunc = f.introspect
+	}
+	logger.Infow("Introspection config",
+		"introspection-url", f.endpoint,
+		"cache-expiration", f.maxExpire.String(),
+		"negative-cache-expiration", f.negExpire.String(),
+	)
+	return nil
+}
+
+func (f *IntrospectionFilter) DoFilter(next flux.FilterInvoker) flux.FilterInvoker {
+	return func(ctx *flux.Context) *flux.ServeError {
+		// Endpoint指定不需要授权
+		if !ctx.Endpoint().Authorize() || f.Config.SkipFunc(ctx) {
+			return next(ctx)
+		}
+		token, err := f.Config.TokenExtractor(ctx)
+		if token == "" || nil != err {
+			return newOAuth2Error(flux.ErrorCodeOAuth2NotFound, "OAUTH2:VALIDATE: token not found", err)
+		}
+		result, serr := f.lookup(ctx, token)
+		if serr != nil {
+			return serr
+		}
+		if !result.Active() {
+			ctx.Logger().Infow("OAUTH2:VALIDATE:REJECTED")
+			return newOAuth2Error(flux.ErrorCodeOAuth2Inactive, "OAUTH2:VALIDATE: token is inactive", nil)
+		}
+		for k, v := range result {
+			ctx.SetAttribute(f.Config.AttKeyPrefix+"."+k, v)
+		}
+		ctx.AddMetric(f.FilterId(), time.Since(ctx.StartAt()))
+		return next(ctx)
+	}
+}
+
+// lookup 查询缓存的内省结果；缓存不存在时执行Token内省
+func (f *IntrospectionFilter) lookup(ctx *flux.Context, token string) (IntrospectionResult, *flux.ServeError) {
+	sum := sha256.Sum256([]byte(token))
+	key := hex.EncodeToString(sum[:])
+	if cached, ok := f.cache.Get(key); ok {
+		return cached.(IntrospectionResult), nil
+	}
+	result, err := f.Config.IntrospectFunc(ctx, token)
+	if nil != err {
+		ctx.Logger().Warnw("OAUTH2:INTROSPECT:ERROR", "error", err)
+		if serr, ok := err.(*flux.ServeError); ok {
+			return nil, serr
+		}
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusBadGateway,
+			ErrorCode:  flux.ErrorCodeGatewayInternal,
+			Message:    "OAUTH2:INTROSPECT: request error",
+			CauseError: err,
+		}
+	}
+	now := time.Now()
+	expiresAt := result.ExpiresAt()
+	if result.Active() && !expiresAt.IsZero() && !expiresAt.After(now) {
+		result[introspectionFieldActive] = false
+	}
+	if result.Active() {
+		ttl := f.maxExpire
+		if !expiresAt.IsZero() && expiresAt.Sub(now) < ttl {
+			ttl = expiresAt.Sub(now)
+		}
+		f.cache.Set(key, result, ttl)
+	} else {
+		f.cache.Set(key, result, f.negExpire)
+	}
+	return result, nil
+}
+
+// introspect 使用Http客户端请求内省端点；客户端凭证通过Basic认证提交
+func (f *IntrospectionFilter) introspect(ctx *flux.Context, token string) (IntrospectionResult, error) {
+	form := url.Values{"token": []string{token}, "token_type_hint": []string{"access_token"}}
+	req, err := http.NewRequestWithContext(ctx.Context(), http.MethodPost, f.endpoint, strings.NewReader(form.Encode()))
+	if nil != err {
+		return nil, err
+	}
+	req.Header.Set(flux.HeaderContentType, flux.MIMEApplicationForm)
+	req.Header.Set(flux.HeaderAccept, flux.MIMEApplicationJSON)
+	if f.clientId != "" {
+		req.SetBasicAuth(url.QueryEscape(f.clientId), url.QueryEscape(f.secret))
+	}
+	resp, err := f.client.Do(req)
+	if nil != err {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if nil != err {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("introspection endpoint status: %d, body: %s", resp.StatusCode, string(data))
+	}
+	result := make(IntrospectionResult, 8)
+	if err := ext.JSONUnmarshal(data, &result); nil != err {
+		return nil, err
+	}
+	return result, nil
+}
+
+func newOAuth2Error(code, message string, cause error) *flux.ServeError {
+	return &flux.ServeError{
+		StatusCode: http.StatusUnauthorized,
+		ErrorCode:  code,
+		Message:    message,
+		CauseError: cause,
+		Header:     http.Header{flux.HeaderWWWAuthenticate: []string{`Bearer error="invalid_token"`}},
+	}
+}
@@ -0,0 +1,114 @@
+package fluxext
+
+import (
+	"encoding/json"
+	"github.com/bytepowered/flux/flux-node"
+	assert2 "github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIntrospectionFilter(t *testing.T) {
+	assert := assert2.New(t)
+	now := time.Now()
+	results := map[string]map[string]interface{}{
+		"t-active":   {"active": true, "sub": "alice", "scope": "orders:read", "exp": now.Add(time.Hour).Unix()},
+		"t-expiring": {"active": true, "sub": "bob", "exp": now.Add(1500 * time.Millisecond).Unix()},
+		"t-expired":  {"active": true, "sub": "carol", "exp": now.Add(-time.Minute).Unix()},
+		"t-inactive": {"active": false},
+	}
+	hits := make(map[string]*int32, len(results)+1)
+	for token := range results {
+		hits[token] = new(int32)
+	}
+	hits["t-error"] = new(int32)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, ok := r.BasicAuth()
+		assert.True(ok)
+		assert.Equal("gateway", id)
+		assert.Equal("s3cr3t", secret)
+		assert.Equal("access_token", r.PostFormValue("token_type_hint"))
+		token := r.PostFormValue("token")
+		if counter, ok := hits[token]; ok {
+			atomic.AddInt32(counter, 1)
+		}
+		result, ok := results[token]
+		if !ok {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		data, _ := json.Marshal(result)
+		_, _ = w.Write(data)
+	}))
+	defer server.Close()
+	filter := NewIntrospectionFilter(IntrospectionConfig{})
+	initTestFilter(t, filter, "introspection.test", map[string]interface{}{
+		ConfigKeyIntrospectionUrl:     server.URL,
+		ConfigKeyClientId:             "gateway",
+		ConfigKeyClientSecret:         "s3cr3t",
+		ConfigKeyAttachmentKey:        "oauth",
+		ConfigKeyCacheExpiration:      "5m",
+		ConfigKeyNegativeCacheExpired: "50ms",
+	})
+	// 有效Token，内省字段写入Context属性
+	ctx := newBearerContext("t-active")
+	assert.Nil(invokeTestFilter(filter, ctx))
+	sub, _ := ctx.GetAttribute("oauth.sub")
+	assert.Equal("alice", sub)
+	scope, _ := ctx.GetAttribute("oauth.scope")
+	assert.Equal("orders:read", scope)
+	assert.Nil(invokeTestFilter(filter, newBearerContext("t-active")))
+	assert.Equal(int32(1), atomic.LoadInt32(hits["t-active"]))
+	// 有效结果缓存至Token过期
+	assert.Nil(invokeTestFilter(filter, newBearerContext("t-expiring")))
+	assert.Nil(invokeTestFilter(filter, newBearerContext("t-expiring")))
+	assert.Equal(int32(1), atomic.LoadInt32(hits["t-expiring"]))
+	// 无效结果按negative_cache_expiration缓存
+	for i := 0; i < 2; i++ {
+		serr := invokeTestFilter(filter, newBearerContext("t-inactive"))
+		if assert.NotNil(serr) {
+			assert.Equal(http.StatusUnauthorized, serr.StatusCode)
+			assert.Equal(flux.ErrorCodeOAuth2Inactive, serr.ErrorCode)
+		}
+	}
+	assert.Equal(int32(1), atomic.LoadInt32(hits["t-inactive"]))
+	time.Sleep(60 * time.Millisecond)
+	assert.NotNil(invokeTestFilter(filter, newBearerContext("t-inactive")))
+	assert.Equal(int32(2), atomic.LoadInt32(hits["t-inactive"]))
+	// 已过期的exp，强制为无效Token
+	serr := invokeTestFilter(filter, newBearerContext("t-expired"))
+	if assert.NotNil(serr) {
+		assert.Equal(flux.ErrorCodeOAuth2Inactive, serr.ErrorCode)
+	}
+	// 内省端点非200响应
+	serr = invokeTestFilter(filter, newBearerContext("t-error"))
+	if assert.NotNil(serr) {
+		assert.Equal(flux.StatusBadGateway, serr.StatusCode)
+	}
+	assert.NotNil(invokeTestFilter(filter, newBearerContext("t-error")))
+	assert.Equal(int32(2), atomic.LoadInt32(hits["t-error"]))
+	// Token过期后缓存失效，重新内省
+	time.Sleep(time.Until(time.Unix(results["t-expiring"]["exp"].(int64), 0)) + 10*time.Millisecond)
+	serr = invokeTestFilter(filter, newBearerContext("t-expiring"))
+	if assert.NotNil(serr) {
+		assert.Equal(flux.ErrorCodeOAuth2Inactive, serr.ErrorCode)
+	}
+	assert.Equal(int32(2), atomic.LoadInt32(hits["t-expiring"]))
+	assert.Equal(int32(1), atomic.LoadInt32(hits["t-active"]))
+}
+
+func TestIntrospectionFilterTokenNotFound(t *testing.T) {
+	filter := NewIntrospectionFilter(IntrospectionConfig{
+		IntrospectFunc: func(_ *flux.Context, _ string) (IntrospectionResult, error) {
+			return IntrospectionResult{"active": true}, nil
+		},
+	})
+	initTestFilter(t, filter, "introspection.notfound", nil)
+	serr := invokeTestFilter(filter, newTestContext(newAuthorizeEndpoint(), nil))
+	if assert2.NotNil(t, serr) {
+		assert2.Equal(t, flux.ErrorCodeOAuth2NotFound, serr.ErrorCode)
+	}
+}
@@ -0,0 +1,453 @@
+package fluxext
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	TypeIdIPAccessFilter = "ipaccess_filter"
+)
+
+const (
+	ConfigKeyIPTrustedProxies  = "trusted_proxies"
+	ConfigKeyIPAllow           = "allow"
+	ConfigKeyIPDeny            = "deny"
+	ConfigKeyIPAllowCountries  = "allow_countries"
+	ConfigKeyIPDenyCountries   = "deny_countries"
+	ConfigKeyGeoIPFile         = "geoip_file"
+	ConfigKeyIPReloadInterval  = "reload_interval"
+	ConfigListener             = "listeners"
+	ipAccessListFilePrefix     = "@"
+	ipAccessReasonDenyIP       = "deny_ip"
+	ipAccessReasonDenyCountry  = "deny_country"
+	ipAccessReasonNotAllowed   = "not_allowed"
+	ipAccessReasonInvalidIP    = "invalid_ip"
+	ipAccessReasonInvalidRule  = "invalid_rule"
+	ipAccessDecisionAllowed    = "allowed"
+	ipAccessDecisionDenied     = "denied"
+	ipAccessMetricListenerNone = "-"
+)
+
+// IP访问控制的Endpoint属性；只支持内联的IP/CIDR列表，不支持引用文件
+const (
+	EndpointAttrTagIPAllow          = "ipAllow"
+	EndpointAttrTagIPDeny           = "ipDeny"
+	EndpointAttrTagIPAllowCountries = "ipAllowCountries"
+	EndpointAttrTagIPDenyCountries  = "ipDenyCountries"
+)
+
+var _ flux.Filter = new(IPAccessFilter)
+
+var (
+	ipAccessMetric     *prometheus.CounterVec
+	ipAccessMetricOnce sync.Once
+)
+
+// IPAccessRule IP访问控制规则；拒绝列表优先；定义允许列表时，IP或国家不在允许列表中的请求被拒绝
+type IPAccessRule struct {
+	Name           string
+	Allow          []*net.IPNet
+	Deny           []*net.IPNet
+	AllowCountries []string
+	DenyCountries  []string
+	// 规则包含无效的列表项；无效规则拒绝全部请求
+	Invalid bool
+}
+
+// IsEmpty 返回规则是否未定义任何列表
+func (r IPAccessRule) IsEmpty() bool {
+	return !r.Invalid && len(r.Allow) == 0 && len(r.Deny) == 0 && len(r.AllowCountries) == 0 && len(r.DenyCountries) == 0
+}
+
+// Check 检查客户端IP和国家代码；拒绝时返回拒绝原因
+func (r IPAccessRule) Check(ip net.IP, country string) (bool, string) {
+	if r.Invalid {
+		return false, ipAccessReasonInvalidRule
+	}
+	if common.ContainsIP(r.Deny, ip) {
+		return false, ipAccessReasonDenyIP
+	}
+	if country != "" && containsString(r.DenyCountries, country) {
+		return false, ipAccessReasonDenyCountry
+	}
+	if len(r.Allow) == 0 && len(r.AllowCountries) == 0 {
+		return true, ""
+	}
+	if common.ContainsIP(r.Allow, ip) || (country != "" && containsString(r.AllowCountries, country)) {
+		return true, ""
+	}
+	return false, ipAccessReasonNotAllowed
+}
+
+// IPAccessDecision IP访问控制决策结果
+type IPAccessDecision struct {
+	Allowed  bool
+	ClientIP string
+	Country  string
+	// 拒绝请求的规则名称：global, listener:<id>, app:<name>, endpoint
+	Rule   string
+	Reason string
+}
+
+// IPAccessConfig IP访问控制配置
+type IPAccessConfig struct {
+	SkipFunc     flux.FilterSkipper
+	AttKeyPrefix string
+	// GeoIP查询；默认使用geoip_file配置的本地数据文件
+	GeoIP GeoIPResolver
+}
+
+func NewIPAccessFilter(c IPAccessConfig) *IPAccessFilter {
+	return &IPAccessFilter{
+		Config: c,
+	}
+}
+
+// IPAccessFilter 按客户端IP/CIDR和GeoIP国家代码控制访问；支持全局、ListenServer(listeners)、
+// 应用级别(applications)和Endpoint属性的规则，请求需满足全部已定义的规则；
+// 列表项以@开头时引用列表文件，列表文件和GeoIP文件变更后自动重新加载。
+type IPAccessFilter struct {
+	Config    IPAccessConfig
+	config    *flux.Configuration
+	rules     atomic.Value // *ipAccessRules
+	endpoints sync.Map
+	interval  time.Duration
+	reloadMu  sync.Mutex
+	files     map[string]time.Time
+	stop      chan struct{}
+}
+
+type ipAccessRules struct {
+	trusted      []*net.IPNet
+	global       IPAccessRule
+	listeners    map[string]IPAccessRule
+	applications map[string]IPAccessRule
+	geoip        GeoIPResolver
+}
+
+func (f *IPAccessFilter) FilterId() string {
+	return TypeIdIPAccessFilter
+}
+
+func (f *IPAccessFilter) Init(config *flux.Configuration) error {
+	logger.Info("IPAccess filter initializing")
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyAttachmentKey:    "client",
+		ConfigKeyIPReloadInterval: "30s",
+	})
+	if "" == f.Config.AttKeyPrefix {
+		f.Config.AttKeyPrefix = config.GetString(ConfigKeyAttachmentKey)
+	}
+	if f.Config.SkipFunc == nil {
+		f.Config.SkipFunc = func(_ *flux.Context) bool {
+			return false
+		}
+	}
+	ipAccessMetricOnce.Do(func() {
+		ipAccessMetric = promauto.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "flux",
+			Subsystem: "http",
+			Name:      "ipaccess_total",
+			Help:      "Number of ip access control decisions",
+		}, []string{"Listener", "Decision", "Reason"})
+	})
+	f.config = config
+	f.interval = config.GetDuration(ConfigKeyIPReloadInterval)
+	if err := f.Reload(); nil != err {
+		return err
+	}
+	rules := f.rules.Load().(*ipAccessRules)
+	logger.Infow("IPAccess config",
+		"trusted-proxies", len(rules.trusted),
+		"listeners", len(rules.listeners),
+		"applications", len(rules.applications),
+		"geoip-enabled", rules.geoip != nil,
+		"reload-interval", f.interval.String(),
+	)
+	return nil
+}
+
+// Startup 启动列表文件和GeoIP文件的变更检查
+func (f *IPAccessFilter) Startup() error {
+	if f.interval <= 0 {
+		return nil
+	}
+	f.stop = make(chan struct{})
+	go f.watch(f.stop)
+	return nil
+}
+
+// Shutdown 停止文件变更检查
+func (f *IPAccessFilter) Shutdown(_ context.Context) error {
+	if f.stop != nil {
+		close(f.stop)
+		f.stop = nil
+	}
+	return nil
+}
+
+func (f *IPAccessFilter) DoFilter(next flux.FilterInvoker) flux.FilterInvoker {
+	return func(ctx *flux.Context) *flux.ServeError {
+		if f.Config.SkipFunc(ctx) {
+			return next(ctx)
+		}
+		decision := f.Decide(ctx)
+		ctx.SetAttribute(f.Config.AttKeyPrefix+".ip", decision.ClientIP)
+		if decision.Country != "" {
+			ctx.SetAttribute(f.Config.AttKeyPrefix+".country", decision.Country)
+		}
+		ctx.AddMetric(f.FilterId(), time.Since(ctx.StartAt()))
+		listenerId := ipAccessMetricListenerNone
+		if wl := ctx.WebListener(); wl != nil {
+			listenerId = wl.ListenerId()
+		}
+		fields := []interface{}{"client-ip", decision.ClientIP, "country", decision.Country,
+			"rule", decision.Rule, "reason", decision.Reason, "listener-id", listenerId}
+		if decision.Allowed {
+			ipAccessMetric.WithLabelValues(listenerId, ipAccessDecisionAllowed, "").Inc()
+			ctx.Logger().Debugw("IPACCESS:ALLOWED", fields...)
+			return next(ctx)
+		}
+		ipAccessMetric.WithLabelValues(listenerId, ipAccessDecisionDenied, decision.Reason).Inc()
+		ctx.Logger().Infow("IPACCESS:DENIED", fields...)
+		return &flux.ServeError{
+			StatusCode: http.StatusForbidden,
+			ErrorCode:  flux.ErrorCodeRequestIPDenied,
+			Message:    "IPACCESS:DENIED",
+		}
+	}
+}
+
+// Decide 按全局、ListenServer、应用和Endpoint的顺序检查规则，任一规则拒绝时返回拒绝的决策
+func (f *IPAccessFilter) Decide(ctx *flux.Context) IPAccessDecision {
+	rules := f.rules.Load().(*ipAccessRules)
+	decision := IPAccessDecision{Allowed: true, ClientIP: common.ClientIP(ctx, rules.trusted)}
+	ip := net.ParseIP(decision.ClientIP)
+	if ip != nil && rules.geoip != nil {
+		decision.Country, _ = rules.geoip.Country(ip)
+	}
+	checks := make([]IPAccessRule, 0, 4)
+	checks = append(checks, rules.global)
+	if wl := ctx.WebListener(); wl != nil {
+		if rule, ok := rules.listeners[strings.ToLower(wl.ListenerId())]; ok {
+			checks = append(checks, rule)
+		}
+	}
+	if rule, ok := rules.applications[strings.ToLower(ctx.Application())]; ok {
+		checks = append(checks, rule)
+	}
+	checks = append(checks, f.endpointRule(ctx))
+	for _, rule := range checks {
+		if rule.IsEmpty() {
+			continue
+		}
+		if ip == nil {
+			decision.Allowed, decision.Rule, decision.Reason = false, rule.Name, ipAccessReasonInvalidIP
+			return decision
+		}
+		if ok, reason := rule.Check(ip, decision.Country); !ok {
+			decision.Allowed, decision.Rule, decision.Reason = false, rule.Name, reason
+			return decision
+		}
+	}
+	return decision
+}
+
+// Reload 重新读取配置、列表文件和GeoIP文件；加载失败时保留当前的规则
+func (f *IPAccessFilter) Reload() error {
+	f.reloadMu.Lock()
+	defer f.reloadMu.Unlock()
+	files := make(map[string]time.Time, 4)
+	rules := &ipAccessRules{
+		listeners:    make(map[string]IPAccessRule, 2),
+		applications: make(map[string]IPAccessRule, 2),
+		geoip:        f.Config.GeoIP,
+	}
+	var err error
+	if rules.trusted, err = f.readNets(f.config, ConfigKeyIPTrustedProxies, files); nil != err {
+		return err
+	}
+	if rules.global, err = f.readRule("global", f.config, files); nil != err {
+		return err
+	}
+	listeners := f.config.Sub(ConfigListener)
+	for id := range listeners.ToStringMap() {
+		if rules.listeners[strings.ToLower(id)], err = f.readRule("listener:"+id, listeners.Sub(id), files); nil != err {
+			return err
+		}
+	}
+	applications := f.config.Sub(ConfigApplication)
+	for app := range applications.ToStringMap() {
+		if rules.applications[strings.ToLower(app)], err = f.readRule("app:"+app, applications.Sub(app), files); nil != err {
+			return err
+		}
+	}
+	if path := f.config.GetString(ConfigKeyGeoIPFile); rules.geoip == nil && path != "" {
+		geoip, err := LoadGeoIPFile(path)
+		if nil != err {
+			return fmt.Errorf("ipaccess: load geoip file: %s, error: %w", path, err)
+		}
+		if files[path], err = ipAccessModTime(path); nil != err {
+			return err
+		}
+		logger.Infow("IPACCESS:GEOIP:LOADED", "file", path, "networks", geoip.Size())
+		rules.geoip = geoip
+	}
+	f.rules.Store(rules)
+	f.files = files
+	return nil
+}
+
+func (f *IPAccessFilter) watch(stop <-chan struct{}) {
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if !f.changed() {
+				continue
+			}
+			if err := f.Reload(); nil != err {
+				logger.Warnw("IPACCESS:RELOAD:ERROR", "error", err)
+			} else {
+				logger.Info("IPACCESS:RELOADED")
+			}
+		}
+	}
+}
+
+// changed 检查列表文件和GeoIP文件的修改时间
+func (f *IPAccessFilter) changed() bool {
+	f.reloadMu.Lock()
+	defer f.reloadMu.Unlock()
+	for path, modTime := range f.files {
+		if mt, err := ipAccessModTime(path); nil != err || !mt.Equal(modTime) {
+			return true
+		}
+	}
+	return false
+}
+
+// endpointRule 读取并缓存Endpoint属性定义的规则；存在无效的列表项时，规则拒绝全部请求
+func (f *IPAccessFilter) endpointRule(ctx *flux.Context) IPAccessRule {
+	endpoint := ctx.Endpoint()
+	allow := endpoint.GetAttr(EndpointAttrTagIPAllow).GetStringSlice()
+	deny := endpoint.GetAttr(EndpointAttrTagIPDeny).GetStringSlice()
+	allowCountries := endpoint.GetAttr(EndpointAttrTagIPAllowCountries).GetStringSlice()
+	denyCountries := endpoint.GetAttr(EndpointAttrTagIPDenyCountries).GetStringSlice()
+	if len(allow) == 0 && len(deny) == 0 && len(allowCountries) == 0 && len(denyCountries) == 0 {
+		return IPAccessRule{}
+	}
+	key := strings.Join([]string{strings.Join(allow, ","), strings.Join(deny, ","),
+		strings.Join(allowCountries, ","), strings.Join(denyCountries, ",")}, "|")
+	if cached, ok := f.endpoints.Load(key); ok {
+		return cached.(IPAccessRule)
+	}
+	rule := IPAccessRule{
+		Name:           "endpoint",
+		AllowCountries: toUpperCountries(allowCountries),
+		DenyCountries:  toUpperCountries(denyCountries),
+	}
+	var err error
+	if rule.Allow, err = common.ParseIPNets(allow); nil != err {
+		ctx.Logger().Warnw("IPACCESS:ENDPOINT:INVALID", "attr", EndpointAttrTagIPAllow, "error", err)
+		rule.Invalid = true
+	}
+	if rule.Deny, err = common.ParseIPNets(deny); nil != err {
+		ctx.Logger().Warnw("IPACCESS:ENDPOINT:INVALID", "attr", EndpointAttrTagIPDeny, "error", err)
+		rule.Invalid = true
+	}
+	f.endpoints.Store(key, rule)
+	return rule
+}
+
+func (f *IPAccessFilter) readRule(name string, conf *flux.Configuration, files map[string]time.Time) (IPAccessRule, error) {
+	rule := IPAccessRule{
+		Name:           name,
+		AllowCountries: toUpperCountries(conf.GetStringSlice(ConfigKeyIPAllowCountries)),
+		DenyCountries:  toUpperCountries(conf.GetStringSlice(ConfigKeyIPDenyCountries)),
+	}
+	var err error
+	if rule.Allow, err = f.readNets(conf, ConfigKeyIPAllow, files); nil != err {
+		return rule, fmt.Errorf("ipaccess: rule: %s, error: %w", name, err)
+	}
+	if rule.Deny, err = f.readNets(conf, ConfigKeyIPDeny, files); nil != err {
+		return rule, fmt.Errorf("ipaccess: rule: %s, error: %w", name, err)
+	}
+	return rule, nil
+}
+
+// readNets 读取IP/CIDR列表；以@开头的列表项引用列表文件，每行一个IP/CIDR，支持#注释
+func (f *IPAccessFilter) readNets(conf *flux.Configuration, key string, files map[string]time.Time) ([]*net.IPNet, error) {
+	values := make([]string, 0, 8)
+	for _, value := range conf.GetStringSlice(key) {
+		if !strings.HasPrefix(value, ipAccessListFilePrefix) {
+			values = append(values, value)
+			continue
+		}
+		path := strings.TrimPrefix(value, ipAccessListFilePrefix)
+		lines, err := readIPListFile(path)
+		if nil != err {
+			return nil, fmt.Errorf("read list file: %s, error: %w", path, err)
+		}
+		if files[path], err = ipAccessModTime(path); nil != err {
+			return nil, err
+		}
+		values = append(values, lines...)
+	}
+	return common.ParseIPNets(values)
+}
+
+func readIPListFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if nil != err {
+		return nil, err
+	}
+	defer file.Close()
+	lines := make([]string, 0, 16)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if idx := strings.Index(line, "#"); idx >= 0 {
+			line = line[:idx]
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+func ipAccessModTime(path string) (time.Time, error) {
+	info, err := os.Stat(path)
+	if nil != err {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
+
+func toUpperCountries(values []string) []string {
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		if v = strings.ToUpper(strings.TrimSpace(v)); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
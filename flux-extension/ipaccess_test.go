@@ -0,0 +1,143 @@
+package fluxext
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	assert2 "github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIPAccessRulePrecedence(t *testing.T) {
+	assert := assert2.New(t)
+	filter := NewIPAccessFilter(IPAccessConfig{})
+	initTestFilter(t, filter, "ipaccess.precedence", map[string]interface{}{
+		ConfigKeyIPAllow: []string{"10.0.0.0/8"},
+		ConfigKeyIPDeny:  []string{"10.6.6.6"},
+		ConfigApplication + ".shop." + ConfigKeyIPAllow: []string{"10.1.0.0/16"},
+	})
+	shop := flux.Endpoint{Application: "shop"}
+	admin := flux.Endpoint{Application: "admin", EmbeddedAttributes: flux.EmbeddedAttributes{
+		Attributes: []flux.Attribute{{Name: EndpointAttrTagIPDeny, Value: []string{"10.2.2.2"}}},
+	}}
+	cases := []struct {
+		remote   string
+		endpoint flux.Endpoint
+		allowed  bool
+		rule     string
+		reason   string
+	}{
+		{remote: "10.2.2.2:80", endpoint: flux.Endpoint{}, allowed: true},
+		// 拒绝列表优先于允许列表
+		{remote: "10.6.6.6:80", endpoint: flux.Endpoint{}, rule: "global", reason: ipAccessReasonDenyIP},
+		{remote: "9.9.9.9:80", endpoint: flux.Endpoint{}, rule: "global", reason: ipAccessReasonNotAllowed},
+		// 需满足全部规则：全局允许，应用规则不允许
+		{remote: "10.2.2.2:80", endpoint: shop, rule: "app:shop", reason: ipAccessReasonNotAllowed},
+		{remote: "10.1.2.3:80", endpoint: shop, allowed: true},
+		{remote: "10.2.2.2:80", endpoint: admin, rule: "endpoint", reason: ipAccessReasonDenyIP},
+	}
+	for _, c := range cases {
+		decision := filter.Decide(newRemoteContext(c.endpoint, c.remote, nil))
+		assert.Equal(c.allowed, decision.Allowed, c.remote)
+		assert.Equal(c.rule, decision.Rule, c.remote)
+		assert.Equal(c.reason, decision.Reason, c.remote)
+	}
+	serr := invokeTestFilter(filter, newRemoteContext(flux.Endpoint{}, "9.9.9.9:80", nil))
+	if assert.NotNil(serr) {
+		assert.Equal(flux.ErrorCodeRequestIPDenied, serr.ErrorCode)
+	}
+}
+
+func TestIPAccessEndpointInvalidRule(t *testing.T) {
+	assert := assert2.New(t)
+	filter := NewIPAccessFilter(IPAccessConfig{})
+	initTestFilter(t, filter, "ipaccess.invalid", nil)
+	cases := []struct {
+		attr  string
+		value []string
+	}{
+		{attr: EndpointAttrTagIPAllow, value: []string{"10.0.0.0/33"}},
+		{attr: EndpointAttrTagIPAllow, value: []string{"9.9.9.9", "bad-ip"}},
+		{attr: EndpointAttrTagIPDeny, value: []string{"1.1.1.1/99"}},
+	}
+	for _, c := range cases {
+		endpoint := flux.Endpoint{EmbeddedAttributes: flux.EmbeddedAttributes{
+			Attributes: []flux.Attribute{{Name: c.attr, Value: c.value}},
+		}}
+		// 无效的规则拒绝全部请求，并缓存无效规则
+		for i := 0; i < 2; i++ {
+			decision := filter.Decide(newRemoteContext(endpoint, "9.9.9.9:80", nil))
+			assert.False(decision.Allowed, c.value)
+			assert.Equal("endpoint", decision.Rule, c.value)
+			assert.Equal(ipAccessReasonInvalidRule, decision.Reason, c.value)
+		}
+	}
+	size := 0
+	filter.endpoints.Range(func(_, value interface{}) bool {
+		assert.True(value.(IPAccessRule).Invalid)
+		size++
+		return true
+	})
+	assert.Equal(len(cases), size)
+}
+
+func TestIPAccessListFileReload(t *testing.T) {
+	assert := assert2.New(t)
+	dir, err := ioutil.TempDir("", "ipaccess")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "deny.txt")
+	assert.NoError(ioutil.WriteFile(path, []byte("# blocked\n1.1.1.1\n"), 0644))
+	filter := NewIPAccessFilter(IPAccessConfig{})
+	initTestFilter(t, filter, "ipaccess.reload", map[string]interface{}{
+		ConfigKeyIPDeny: []string{ipAccessListFilePrefix + path},
+	})
+	assert.False(filter.Decide(newRemoteContext(flux.Endpoint{}, "1.1.1.1:80", nil)).Allowed)
+	assert.True(filter.Decide(newRemoteContext(flux.Endpoint{}, "2.2.2.2:80", nil)).Allowed)
+	assert.False(filter.changed())
+	// 列表文件变更后重新加载
+	assert.NoError(ioutil.WriteFile(path, []byte("2.2.2.0/24 # new\n"), 0644))
+	future := time.Now().Add(time.Minute)
+	assert.NoError(os.Chtimes(path, future, future))
+	assert.True(filter.changed())
+	assert.NoError(filter.Reload())
+	assert.True(filter.Decide(newRemoteContext(flux.Endpoint{}, "1.1.1.1:80", nil)).Allowed)
+	assert.False(filter.Decide(newRemoteContext(flux.Endpoint{}, "2.2.2.9:80", nil)).Allowed)
+	// 加载失败时保留当前规则
+	assert.NoError(ioutil.WriteFile(path, []byte("invalid\n"), 0644))
+	assert.Error(filter.Reload())
+	assert.False(filter.Decide(newRemoteContext(flux.Endpoint{}, "2.2.2.9:80", nil)).Allowed)
+}
+
+func TestIPAccessGeoIP(t *testing.T) {
+	assert := assert2.New(t)
+	dir, err := ioutil.TempDir("", "geoip")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "geoip.csv")
+	assert.NoError(ioutil.WriteFile(path, []byte("network,country\n1.2.0.0/16,cn\n5.6.7.0/24,\"US\"\n2001:db8::/32,JP\n"), 0644))
+	geoip, err := LoadGeoIPFile(path)
+	assert.NoError(err)
+	assert.Equal(3, geoip.Size())
+	for ip, expected := range map[string]string{"1.2.3.4": "CN", "5.6.7.8": "US", "2001:db8::1": "JP", "8.8.8.8": ""} {
+		country, ok := geoip.Country(net.ParseIP(ip))
+		assert.Equal(expected != "", ok, ip)
+		assert.Equal(expected, country, ip)
+	}
+	filter := NewIPAccessFilter(IPAccessConfig{})
+	initTestFilter(t, filter, "ipaccess.geoip", map[string]interface{}{
+		ConfigKeyGeoIPFile:       path,
+		ConfigKeyIPDenyCountries: []string{"cn"},
+	})
+	decision := filter.Decide(newRemoteContext(flux.Endpoint{}, "1.2.3.4:80", nil))
+	assert.False(decision.Allowed)
+	assert.Equal("CN", decision.Country)
+	assert.Equal(ipAccessReasonDenyCountry, decision.Reason)
+	ctx := newRemoteContext(flux.Endpoint{}, "5.6.7.8:80", nil)
+	assert.Nil(invokeTestFilter(filter, ctx))
+	country, _ := ctx.GetAttribute("client.country")
+	assert.Equal("US", country)
+}
@@ -0,0 +1,338 @@
+package fluxext
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/dgrijalva/jwt-go"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ConfigKeyJWKSUrl          = "jwks_url"
+	ConfigKeyJWKSFile         = "jwks_file"
+	ConfigKeyJWKSTimeout      = "jwks_timeout"
+	ConfigKeyJWKSRefresh      = "jwks_refresh_interval"
+	ConfigKeyJWKSMinRefresh   = "jwks_min_refresh_interval"
+	ConfigKeyJWTAlgorithms    = "algorithms"
+	DefaultJWKSRefresh        = time.Hour
+	DefaultJWKSMinRefresh     = time.Minute
+	DefaultJWKSFetchTimeout   = 5 * time.Second
+	SigningMethodEdDSAAlgName = "EdDSA"
+)
+
+// DefaultJWTAlgorithms 默认允许的JWT签名算法；只允许非对称算法，避免alg混淆攻击
+var DefaultJWTAlgorithms = []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+var (
+	ErrJWKSKeyNotFound    = errors.New("jwks: key not found")
+	ErrJWKSKeyMismatch    = errors.New("jwks: key type does not match token algorithm")
+	ErrJWKSAlgUnsupported = errors.New("jwks: unsupported algorithm")
+)
+
+var SigningMethodEdDSA = new(SigningMethodEd25519)
+
+func init() {
+	jwt.RegisterSigningMethod(SigningMethodEdDSA.Alg(), func() jwt.SigningMethod {
+		return SigningMethodEdDSA
+	})
+}
+
+// SigningMethodEd25519 实现EdDSA(Ed25519)签名算法
+type SigningMethodEd25519 struct{}
+
+func (m *SigningMethodEd25519) Alg() string {
+	return SigningMethodEdDSAAlgName
+}
+
+func (m *SigningMethodEd25519) Verify(signingString, signature string, key interface{}) error {
+	pub, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return jwt.ErrInvalidKeyType
+	}
+	sig, err := jwt.DecodeSegment(signature)
+	if nil != err {
+		return err
+	}
+	if !ed25519.Verify(pub, []byte(signingString), sig) {
+		return errors.New("ed25519: verification error")
+	}
+	return nil
+}
+
+func (m *SigningMethodEd25519) Sign(signingString string, key interface{}) (string, error) {
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return "", jwt.ErrInvalidKeyType
+	}
+	return jwt.EncodeSegment(ed25519.Sign(priv, []byte(signingString))), nil
+}
+
+// JWKSConfig JWKS密钥集配置；URL和File二选一
+type JWKSConfig struct {
+	URL     string
+	File    string
+	Timeout time.Duration
+	// 密钥集的缓存时间，到期后重新加载
+	RefreshInterval time.Duration
+	// Token的kid不存在时触发刷新的最小间隔
+	MinRefreshInterval time.Duration
+}
+
+// JWKSKeySet 从URL或文件加载并缓存JWKS密钥集，按kid选择验证密钥
+type JWKSKeySet struct {
+	config    JWKSConfig
+	client    *http.Client
+	mu        sync.RWMutex
+	keys      map[string]jwksKey
+	loadedAt  time.Time
+	refreshMu sync.Mutex
+	attemptAt time.Time
+}
+
+type jwksKey struct {
+	alg string
+	key crypto.PublicKey
+}
+
+// jsonWebKey JWK定义，RFC 7517
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func NewJWKSKeySet(config JWKSConfig) *JWKSKeySet {
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultJWKSFetchTimeout
+	}
+	if config.RefreshInterval <= 0 {
+		config.RefreshInterval = DefaultJWKSRefresh
+	}
+	if config.MinRefreshInterval <= 0 {
+		config.MinRefreshInterval = DefaultJWKSMinRefresh
+	}
+	return &JWKSKeySet{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+		keys:   make(map[string]jwksKey, 0),
+	}
+}
+
+// KeyFunc 按Token的kid选择验证密钥；kid不存在时，按最小间隔限制刷新密钥集
+func (s *JWKSKeySet) KeyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	s.mu.RLock()
+	key, ok := s.keys[kid]
+	expired := time.Since(s.loadedAt) > s.config.RefreshInterval
+	s.mu.RUnlock()
+	if !ok || expired {
+		if err := s.refresh(!ok); nil != err && !ok {
+			return nil, err
+		}
+		s.mu.RLock()
+		key, ok = s.keys[kid]
+		s.mu.RUnlock()
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w, kid: %s", ErrJWKSKeyNotFound, kid)
+	}
+	if err := checkJWKSKey(token.Method.Alg(), key); nil != err {
+		return nil, err
+	}
+	return key.key, nil
+}
+
+// Load 立即加载密钥集
+func (s *JWKSKeySet) Load() error {
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+	s.attemptAt = time.Now()
+	return s.load()
+}
+
+func (s *JWKSKeySet) refresh(unknownKid bool) error {
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+	s.mu.RLock()
+	expired := time.Since(s.loadedAt) > s.config.RefreshInterval
+	s.mu.RUnlock()
+	// 等待锁期间，密钥集已被其它请求刷新
+	if !expired && !unknownKid {
+		return nil
+	}
+	if time.Since(s.attemptAt) < s.config.MinRefreshInterval {
+		return nil
+	}
+	s.attemptAt = time.Now()
+	return s.load()
+}
+
+func (s *JWKSKeySet) load() error {
+	data, err := s.fetch()
+	if nil != err {
+		logger.Warnw("JWT:JWKS:LOAD_ERROR", "url", s.config.URL, "file", s.config.File, "error", err)
+		return err
+	}
+	var doc struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := ext.JSONUnmarshal(data, &doc); nil != err {
+		return fmt.Errorf("jwks: decode document, error: %w", err)
+	}
+	keys := make(map[string]jwksKey, len(doc.Keys))
+	for _, jwk := range doc.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		pub, err := jwk.publicKey()
+		if nil != err {
+			logger.Warnw("JWT:JWKS:KEY_IGNORED", "kid", jwk.Kid, "kty", jwk.Kty, "error", err)
+			continue
+		}
+		keys[jwk.Kid] = jwksKey{alg: jwk.Alg, key: pub}
+	}
+	s.mu.Lock()
+	s.keys, s.loadedAt = keys, time.Now()
+	s.mu.Unlock()
+	logger.Infow("JWT:JWKS:LOADED", "url", s.config.URL, "file", s.config.File, "keys", len(keys))
+	return nil
+}
+
+func (s *JWKSKeySet) fetch() ([]byte, error) {
+	if s.config.File != "" {
+		return ioutil.ReadFile(s.config.File)
+	}
+	resp, err := s.client.Get(s.config.URL)
+	if nil != err {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks: unexpected status: %d", resp.StatusCode)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// checkJWKSKey 检查密钥类型与Token签名算法是否匹配
+func checkJWKSKey(alg string, key jwksKey) error {
+	if key.alg != "" && key.alg != alg {
+		return fmt.Errorf("%w, key.alg: %s, token.alg: %s", ErrJWKSKeyMismatch, key.alg, alg)
+	}
+	switch {
+	case strings.HasPrefix(alg, "RS"), strings.HasPrefix(alg, "PS"):
+		if _, ok := key.key.(*rsa.PublicKey); ok {
+			return nil
+		}
+	case strings.HasPrefix(alg, "ES"):
+		if pub, ok := key.key.(*ecdsa.PublicKey); ok && "ES"+fmt.Sprint(curveHashBits(pub.Curve)) == alg {
+			return nil
+		}
+	case alg == SigningMethodEdDSAAlgName:
+		if _, ok := key.key.(ed25519.PublicKey); ok {
+			return nil
+		}
+	default:
+		return fmt.Errorf("%w: %s", ErrJWKSAlgUnsupported, alg)
+	}
+	return fmt.Errorf("%w, token.alg: %s", ErrJWKSKeyMismatch, alg)
+}
+
+func curveHashBits(curve elliptic.Curve) int {
+	switch curve {
+	case elliptic.P256():
+		return 256
+	case elliptic.P384():
+		return 384
+	case elliptic.P521():
+		return 512
+	default:
+		return 0
+	}
+}
+
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeJWKBigInt(k.N)
+		if nil != err {
+			return nil, err
+		}
+		e, err := decodeJWKBigInt(k.E)
+		if nil != err {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve: %s", k.Crv)
+		}
+		x, err := decodeJWKBigInt(k.X)
+		if nil != err {
+			return nil, err
+		}
+		y, err := decodeJWKBigInt(k.Y)
+		if nil != err {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point is not on curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve: %s", k.Crv)
+		}
+		x, err := decodeJWKBytes(k.X)
+		if nil != err {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid ed25519 key size")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type: %s", k.Kty)
+	}
+}
+
+func decodeJWKBytes(value string) ([]byte, error) {
+	if value == "" {
+		return nil, errors.New("empty key parameter")
+	}
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+}
+
+func decodeJWKBigInt(value string) (*big.Int, error) {
+	data, err := decodeJWKBytes(value)
+	if nil != err {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(data), nil
+}
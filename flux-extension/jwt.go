@@ -21,14 +21,26 @@ const (
 	ConfigKeyAttachmentKey = "attachment_key"
 )
 
+// JWT声明校验的Endpoint属性
+const (
+	// 允许的签发者列表
+	EndpointAttrTagJwtIssuer = "jwtIssuer"
+	// 允许的受众列表，Token的aud包含任一受众即通过
+	EndpointAttrTagJwtAudience = "jwtAudience"
+	// 必需的声明列表，格式：claim 或 claim=value
+	EndpointAttrTagJwtRequiredClaims = "jwtRequiredClaims"
+)
+
 var _ flux.Filter = new(JWTFilter)
 
 type JWTConfig struct {
 	AttKeyPrefix string
 	// 默认查找Token的函数
 	TokenExtractor func(ctx *flux.Context) (string, error)
-	// 加载签名验证密钥的函数
+	// 加载签名验证密钥的函数；未指定时，从配置的JWKS加载
 	SecretKeyLoader func(ctx *flux.Context, token *jwt.Token) (interface{}, error)
+	// 允许的签名算法列表；为空时不限制
+	Algorithms []string
 }
 
 func NewJWTFilter(config JWTConfig) *JWTFilter {
@@ -39,6 +51,7 @@ func NewJWTFilter(config JWTConfig) *JWTFilter {
 
 type JWTFilter struct {
 	Config JWTConfig
+	parser *jwt.Parser
 }
 
 func (f *JWTFilter) FilterId() string {
@@ -55,7 +68,34 @@ func (f *JWTFilter) Init(config *flux.Configuration) error {
 	if "" == f.Config.AttKeyPrefix {
 		f.Config.AttKeyPrefix = cast.ToString(config.GetOrDefault(ConfigKeyAttachmentKey, "jwt"))
 	}
+	// JWKS；配置为空字符串时不启用
+	jwksUrl, jwksFile := config.GetString(ConfigKeyJWKSUrl), config.GetString(ConfigKeyJWKSFile)
+	if f.Config.SecretKeyLoader == nil && (jwksUrl != "" || jwksFile != "") {
+		keyset := NewJWKSKeySet(JWKSConfig{
+			URL:                jwksUrl,
+			File:               jwksFile,
+			Timeout:            config.GetDuration(ConfigKeyJWKSTimeout),
+			RefreshInterval:    config.GetDuration(ConfigKeyJWKSRefresh),
+			MinRefreshInterval: config.GetDuration(ConfigKeyJWKSMinRefresh),
+		})
+		if err := keyset.Load(); nil != err {
+			return err
+		}
+		f.Config.SecretKeyLoader = func(_ *flux.Context, token *jwt.Token) (interface{}, error) {
+			return keyset.KeyFunc(token)
+		}
+		if len(f.Config.Algorithms) == 0 {
+			f.Config.Algorithms = DefaultJWTAlgorithms
+		}
+	}
+	if algs := config.GetStringSlice(ConfigKeyJWTAlgorithms); len(algs) > 0 {
+		f.Config.Algorithms = algs
+	}
 	fluxpkg.AssertNotNil(f.Config.SecretKeyLoader, "<secret-loader> must not nil")
+	f.parser = new(jwt.Parser)
+	if len(f.Config.Algorithms) > 0 {
+		f.parser.ValidMethods = f.Config.Algorithms
+	}
 	return nil
 }
 
@@ -77,10 +117,19 @@ func (f *JWTFilter) DoFilter(next flux.FilterInvoker) flux.FilterInvoker {
 		}
 		// 解析和校验
 		claims := jwt.MapClaims{}
-		token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
+		token, err := f.parser.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
 			return f.Config.SecretKeyLoader(ctx, token)
 		})
 		if token != nil && token.Valid {
+			if err := VerifyEndpointClaims(ctx.Endpoint(), claims); nil != err {
+				ctx.Logger().Infow("JWT:VALIDATE:CLAIMS_REJECTED", "error", err)
+				return &flux.ServeError{
+					StatusCode: http.StatusUnauthorized,
+					ErrorCode:  flux.ErrorCodeJwtInvalidClaims,
+					Message:    "JWT:VALIDATE: invalid claims",
+					CauseError: err,
+				}
+			}
 			// set claims to attributes
 			ctx.Logger().Infow("JWT:VALIDATE:PASSED", "jwt.claims", claims)
 			for k, v := range claims {
@@ -123,6 +172,51 @@ func (f *JWTFilter) DoFilter(next flux.FilterInvoker) flux.FilterInvoker {
 	}
 }
 
+// VerifyEndpointClaims 按Endpoint属性校验JWT的签发者、受众和必需声明
+func VerifyEndpointClaims(endpoint *flux.Endpoint, claims jwt.MapClaims) error {
+	if issuers := endpoint.GetAttr(EndpointAttrTagJwtIssuer).GetStringSlice(); len(issuers) > 0 {
+		iss, _ := claims["iss"].(string)
+		if !containsString(issuers, iss) {
+			return fmt.Errorf("issuer not allowed: %s", iss)
+		}
+	}
+	if audiences := endpoint.GetAttr(EndpointAttrTagJwtAudience).GetStringSlice(); len(audiences) > 0 {
+		matched := false
+		for _, aud := range cast.ToStringSlice(claims["aud"]) {
+			if containsString(audiences, aud) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("audience not allowed: %v", claims["aud"])
+		}
+	}
+	for _, expr := range endpoint.GetAttr(EndpointAttrTagJwtRequiredClaims).GetStringSlice() {
+		name, expected := expr, ""
+		if idx := strings.Index(expr, "="); idx > 0 {
+			name, expected = strings.TrimSpace(expr[:idx]), strings.TrimSpace(expr[idx+1:])
+		}
+		value, ok := claims[name]
+		if !ok || value == nil {
+			return fmt.Errorf("required claim not found: %s", name)
+		}
+		if expected != "" && !containsString(cast.ToStringSlice(value), expected) && cast.ToString(value) != expected {
+			return fmt.Errorf("required claim not matched: %s", expr)
+		}
+	}
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
 // ExtractTokenOAuth2 按OAuth2请求，从Header:Authorization和form:access_token中抓取Token
 func ExtractTokenOAuth2(ctx *flux.Context) (string, error) {
 	return request.OAuth2Extractor.ExtractToken(ctx.Request())
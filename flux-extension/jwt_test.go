@@ -0,0 +1,160 @@
+package fluxext
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/dgrijalva/jwt-go"
+	assert2 "github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestRSAKey(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert2.NoError(t, err)
+	return key
+}
+
+func newTestJWKS(keys map[string]*rsa.PrivateKey) []byte {
+	jwks := make([]map[string]string, 0, len(keys))
+	for kid, key := range keys {
+		jwks = append(jwks, map[string]string{
+			"kty": "RSA", "kid": kid, "use": "sig", "alg": "RS256",
+			"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		})
+	}
+	data, _ := json.Marshal(map[string]interface{}{"keys": jwks})
+	return data
+}
+
+func newTestToken(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	assert2.NoError(t, err)
+	return signed
+}
+
+func TestJWTFilterEmptyJWKSConfig(t *testing.T) {
+	// 默认配置的空JWKS地址，使用自定义的密钥加载函数
+	filter := NewJWTFilter(JWTConfig{SecretKeyLoader: func(_ *flux.Context, _ *jwt.Token) (interface{}, error) {
+		return []byte("secret"), nil
+	}})
+	initTestFilter(t, filter, "jwt.empty", map[string]interface{}{
+		ConfigKeyJWKSUrl:  "",
+		ConfigKeyJWKSFile: "",
+	})
+}
+
+func TestJWTFilterJWKS(t *testing.T) {
+	assert := assert2.New(t)
+	k1, k2 := newTestRSAKey(t), newTestRSAKey(t)
+	dir, err := ioutil.TempDir("", "jwks")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "jwks.json")
+	assert.NoError(ioutil.WriteFile(path, newTestJWKS(map[string]*rsa.PrivateKey{"k1": k1, "k2": k2}), 0644))
+	filter := NewJWTFilter(JWTConfig{})
+	initTestFilter(t, filter, "jwt.jwks", map[string]interface{}{
+		ConfigKeyJWKSUrl:       "",
+		ConfigKeyJWKSFile:      path,
+		ConfigKeyJWTAlgorithms: []string{"RS256"},
+	})
+	claims := jwt.MapClaims{"sub": "alice", "exp": time.Now().Add(time.Minute).Unix()}
+	// 按kid选择密钥
+	ctx := newBearerContext(newTestToken(t, jwt.SigningMethodRS256, "k2", k2, claims))
+	assert.Nil(invokeTestFilter(filter, ctx))
+	sub, _ := ctx.GetAttribute("jwt.sub")
+	assert.Equal("alice", sub)
+	assert.NotNil(invokeTestFilter(filter, newBearerContext(newTestToken(t, jwt.SigningMethodRS256, "k1", k2, claims))))
+	assert.NotNil(invokeTestFilter(filter, newBearerContext(newTestToken(t, jwt.SigningMethodRS256, "k3", k2, claims))))
+	// 拒绝不在允许列表中的算法
+	assert.NotNil(invokeTestFilter(filter, newBearerContext(newTestToken(t, jwt.SigningMethodRS384, "k1", k1, claims))))
+	assert.NotNil(invokeTestFilter(filter, newBearerContext(newTestToken(t, jwt.SigningMethodHS256, "k1", []byte("secret"), claims))))
+	// 过期Token
+	expired := jwt.MapClaims{"sub": "alice", "exp": time.Now().Add(-time.Minute).Unix()}
+	serr := invokeTestFilter(filter, newBearerContext(newTestToken(t, jwt.SigningMethodRS256, "k1", k1, expired)))
+	if assert.NotNil(serr) {
+		assert.Equal(flux.ErrorCodeJwtExpired, serr.ErrorCode)
+	}
+}
+
+func TestJWKSKeySetRefresh(t *testing.T) {
+	assert := assert2.New(t)
+	k1, k2 := newTestRSAKey(t), newTestRSAKey(t)
+	var hits int32
+	var document atomic.Value
+	document.Store(newTestJWKS(map[string]*rsa.PrivateKey{"k1": k1}))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		_, _ = w.Write(document.Load().([]byte))
+	}))
+	defer server.Close()
+	keyset := NewJWKSKeySet(JWKSConfig{URL: server.URL, MinRefreshInterval: time.Hour})
+	assert.NoError(keyset.Load())
+	assert.Equal(int32(1), atomic.LoadInt32(&hits))
+	parse := func(kid string, key *rsa.PrivateKey) error {
+		_, err := new(jwt.Parser).Parse(newTestToken(t, jwt.SigningMethodRS256, kid, key, jwt.MapClaims{}), keyset.KeyFunc)
+		return err
+	}
+	assert.NoError(parse("k1", k1))
+	// 密钥轮换：未知kid的刷新受最小间隔限制
+	document.Store(newTestJWKS(map[string]*rsa.PrivateKey{"k1": k1, "k2": k2}))
+	assert.Error(parse("k2", k2))
+	assert.Error(parse("k2", k2))
+	assert.Equal(int32(1), atomic.LoadInt32(&hits))
+	// 超过最小间隔后，未知kid触发一次刷新
+	keyset.refreshMu.Lock()
+	keyset.attemptAt = time.Now().Add(-2 * time.Hour)
+	keyset.refreshMu.Unlock()
+	assert.NoError(parse("k2", k2))
+	assert.NoError(parse("k2", k2))
+	assert.Equal(int32(2), atomic.LoadInt32(&hits))
+}
+
+func TestVerifyEndpointClaims(t *testing.T) {
+	endpoint := &flux.Endpoint{EmbeddedAttributes: flux.EmbeddedAttributes{Attributes: []flux.Attribute{
+		{Name: EndpointAttrTagJwtIssuer, Value: []string{"https://idp.brand.com"}},
+		{Name: EndpointAttrTagJwtAudience, Value: []string{"gateway", "shop"}},
+		{Name: EndpointAttrTagJwtRequiredClaims, Value: []string{"tenant", "scope=orders:read"}},
+	}}}
+	valid := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss": "https://idp.brand.com", "aud": []interface{}{"app", "shop"},
+			"tenant": "acme", "scope": []interface{}{"orders:read", "orders:write"},
+		}
+	}
+	cases := []struct {
+		name   string
+		modify func(claims jwt.MapClaims)
+		valid  bool
+	}{
+		{name: "valid", modify: func(_ jwt.MapClaims) {}, valid: true},
+		{name: "aud-string", modify: func(c jwt.MapClaims) { c["aud"] = "gateway" }, valid: true},
+		{name: "scope-string", modify: func(c jwt.MapClaims) { c["scope"] = "orders:read" }, valid: true},
+		{name: "issuer", modify: func(c jwt.MapClaims) { c["iss"] = "https://evil.com" }},
+		{name: "issuer-missing", modify: func(c jwt.MapClaims) { delete(c, "iss") }},
+		{name: "audience", modify: func(c jwt.MapClaims) { c["aud"] = []interface{}{"app"} }},
+		{name: "required-missing", modify: func(c jwt.MapClaims) { delete(c, "tenant") }},
+		{name: "required-value", modify: func(c jwt.MapClaims) { c["scope"] = []interface{}{"orders:write"} }},
+	}
+	for _, c := range cases {
+		claims := valid()
+		c.modify(claims)
+		err := VerifyEndpointClaims(endpoint, claims)
+		assert2.Equal(t, c.valid, err == nil, c.name)
+	}
+}
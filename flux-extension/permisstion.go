@@ -1,14 +1,21 @@
 package fluxext
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	flux "github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
 	"github.com/bytepowered/flux/flux-node/ext"
 	"github.com/bytepowered/flux/flux-node/logger"
 	"github.com/bytepowered/flux/flux-node/transporter"
 	"github.com/bytepowered/flux/flux-pkg"
+	"github.com/spf13/cast"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -16,6 +23,12 @@ const (
 	TypeIdPermissionV2Filter = "permission_filter"
 )
 
+const (
+	ConfigKeyCacheDenyExpiration = "cache_deny_expiration"
+	ConfigKeyCacheSubjectKey     = "cache_subject_key"
+	ConfigKeyCacheArgs           = "cache_args"
+)
+
 type (
 	// PermissionReport 权限验证结果报告
 	PermissionReport struct {
@@ -51,10 +64,24 @@ func NewPermissionFilter(c PermissionConfig) *PermissionFilter {
 	}
 }
 
-// PermissionFilter 提供基于Endpoint.Permission元数据的权限验证
+// PermissionFilter 提供基于Endpoint.Permission元数据的权限验证；
+// 可选缓存验证结果，缓存Key由主体属性、权限服务ID和指定的请求参数组成；权限服务变更时清除相关缓存。
 type PermissionFilter struct {
-	Disabled bool
-	Configs  PermissionConfig
+	Disabled   bool
+	Configs    PermissionConfig
+	cache      *expiringCache
+	group      callGroup
+	generation uint64 // 缓存版本；权限服务变更时递增，丢弃变更前开始的验证结果
+	allowTTL   time.Duration
+	denyTTL    time.Duration
+	subject    string
+	args       []string
+}
+
+// permissionCacheEntry 缓存的权限验证结果
+type permissionCacheEntry struct {
+	report     PermissionReport
+	serviceIds []string
 }
 
 func (p *PermissionFilter) Init(config *flux.Configuration) error {
@@ -74,6 +101,29 @@ func (p *PermissionFilter) Init(config *flux.Configuration) error {
 	if fluxpkg.IsNil(p.Configs.VerifyFunc) {
 		return fmt.Errorf("PermissionFilter.PermissionVerifyFunc is nil")
 	}
+	return p.initCache(config)
+}
+
+func (p *PermissionFilter) initCache(config *flux.Configuration) error {
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyCacheDisabled:       true,
+		ConfigKeyCacheExpiration:     "1m",
+		ConfigKeyCacheDenyExpiration: "10s",
+		ConfigKeyCacheSize:           10000,
+		ConfigKeyCacheSubjectKey:     "jwt.sub",
+	})
+	if config.GetBool(ConfigKeyCacheDisabled) {
+		return nil
+	}
+	p.allowTTL = config.GetDuration(ConfigKeyCacheExpiration)
+	p.denyTTL = config.GetDuration(ConfigKeyCacheDenyExpiration)
+	p.subject = config.GetString(ConfigKeyCacheSubjectKey)
+	p.args = config.GetStringSlice(ConfigKeyCacheArgs)
+	p.cache = newExpiringCache(config.GetInt(ConfigKeyCacheSize))
+	ext.AddServiceEventHook(p.onServiceEvent)
+	logger.Infow("Endpoint PermissionFilter cache enabled",
+		"allow-expiration", p.allowTTL.String(), "deny-expiration", p.denyTTL.String(),
+		"subject-key", p.subject, "args", p.args)
 	return nil
 }
 
@@ -112,7 +162,7 @@ func (p *PermissionFilter) DoFilter(next flux.FilterInvoker) flux.FilterInvoker
 				}
 			}
 		}
-		report, err := p.Configs.VerifyFunc(services, ctx)
+		report, err := p.verify(services, ctx)
 		ctx.AddMetric(p.FilterId(), time.Since(ctx.StartAt()))
 		if nil != err {
 			if serr, ok := err.(*flux.ServeError); ok {
@@ -136,6 +186,102 @@ func (p *PermissionFilter) DoFilter(next flux.FilterInvoker) flux.FilterInvoker
 	}
 }
 
+// verify 执行权限验证；启用缓存时，读取缓存的验证结果，并合并相同缓存Key的并发验证
+func (p *PermissionFilter) verify(services []flux.Service, ctx *flux.Context) (PermissionReport, error) {
+	if p.cache == nil {
+		return p.Configs.VerifyFunc(services, ctx)
+	}
+	key, serviceIds, ok := p.cacheKey(services, ctx)
+	// 没有主体标识，或者请求参数读取失败时，不缓存
+	if !ok {
+		return p.Configs.VerifyFunc(services, ctx)
+	}
+	if cached, ok := p.cache.Get(key); ok {
+		return cached.(permissionCacheEntry).report, nil
+	}
+	// 不合并权限服务变更前开始的验证
+	generation := atomic.LoadUint64(&p.generation)
+	value, err, _ := p.group.Do(key+"#"+strconv.FormatUint(generation, 10), func() (interface{}, error) {
+		report, err := p.Configs.VerifyFunc(services, ctx)
+		if nil != err {
+			return nil, err
+		}
+		ttl := p.allowTTL
+		if !report.Success {
+			ttl = p.denyTTL
+		}
+		p.cache.Set(key, permissionCacheEntry{report: report, serviceIds: serviceIds}, ttl)
+		// 验证期间权限服务已变更，结果可能已过时，删除缓存；先写入后检查，避免与清除缓存交错
+		if atomic.LoadUint64(&p.generation) != generation {
+			p.cache.Delete(key)
+		}
+		return report, nil
+	})
+	if nil != err {
+		return PermissionReport{}, err
+	}
+	report, ok := value.(PermissionReport)
+	if !ok {
+		return PermissionReport{}, errors.New("permission verify aborted")
+	}
+	return report, nil
+}
+
+// cacheKey 按主体属性、权限服务ID和请求参数生成缓存Key；任一请求参数读取失败或不存在时，返回false
+func (p *PermissionFilter) cacheKey(services []flux.Service, ctx *flux.Context) (string, []string, bool) {
+	value, _ := ctx.GetAttribute(p.subject)
+	subject := cast.ToString(value)
+	if subject == "" {
+		return "", nil, false
+	}
+	serviceIds := make([]string, len(services))
+	for i, srv := range services {
+		serviceIds[i] = permissionServiceId(srv)
+	}
+	parts := make([]string, 0, 1+len(serviceIds)+len(p.args))
+	parts = append(parts, subject)
+	parts = append(parts, serviceIds...)
+	for _, expr := range p.args {
+		scope, key, ok := fluxpkg.LookupParseExpr(expr)
+		if !ok {
+			ctx.Logger().Warnw("PERMISSION:CACHE:LOOKUP_ARG", "expr", expr, "error", "illegal lookup expr")
+			return "", nil, false
+		}
+		value, err := common.LookupMTValue(scope, key, ctx)
+		if nil != err || !value.Valid {
+			ctx.Logger().Debugw("PERMISSION:CACHE:LOOKUP_ARG", "expr", expr, "error", err)
+			return "", nil, false
+		}
+		parts = append(parts, cast.ToString(value.Value))
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:]), serviceIds, true
+}
+
+// onServiceEvent 权限服务变更时，清除相关的缓存结果
+func (p *PermissionFilter) onServiceEvent(event flux.ServiceEvent) {
+	ids := []string{permissionServiceId(event.Service), event.Service.AliasId}
+	atomic.AddUint64(&p.generation, 1)
+	removed := p.cache.DeleteFunc(func(_ string, value interface{}) bool {
+		for _, id := range value.(permissionCacheEntry).serviceIds {
+			if id != "" && containsString(ids, id) {
+				return true
+			}
+		}
+		return false
+	})
+	if removed > 0 {
+		logger.Infow("PERMISSION:CACHE:INVALIDATED", "service-id", ids[0], "removed", removed)
+	}
+}
+
+func permissionServiceId(service flux.Service) string {
+	if service.ServiceId != "" {
+		return service.ServiceId
+	}
+	return service.ServiceID()
+}
+
 // InvokeCodec 执行权限验证的后端服务，获取响应结果；
 func (p *PermissionFilter) InvokeCodec(ctx *flux.Context, service flux.Service) (*flux.ResponseBody, *flux.ServeError) {
 	return transporter.DoInvokeCodec(ctx, service)
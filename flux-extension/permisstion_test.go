@@ -0,0 +1,155 @@
+package fluxext
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	assert2 "github.com/stretchr/testify/assert"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testPermissionService = flux.Service{ServiceId: "perm.check", Interface: "com.foo.PermissionService", Method: "check"}
+
+func newPermissionContext(subject string) *flux.Context {
+	ctx := newTestContext(flux.Endpoint{PermissionService: testPermissionService}, nil)
+	if subject != "" {
+		ctx.SetAttribute("jwt.sub", subject)
+	}
+	return ctx
+}
+
+// 权限Filter的SkipFunc需要显式指定
+func testPermissionNoSkip(_ *flux.Context) bool {
+	return false
+}
+
+func TestPermissionCacheTTL(t *testing.T) {
+	assert := assert2.New(t)
+	var calls int32
+	filter := NewPermissionFilter(PermissionConfig{SkipFunc: testPermissionNoSkip, VerifyFunc: func(_ []flux.Service, ctx *flux.Context) (PermissionReport, error) {
+		atomic.AddInt32(&calls, 1)
+		subject, _ := ctx.GetAttribute("jwt.sub")
+		return NewPermissionVerifyReport(subject == "alice", "", ""), nil
+	}})
+	initTestFilter(t, filter, "permission.ttl", map[string]interface{}{
+		ConfigKeyCacheDisabled:       false,
+		ConfigKeyCacheExpiration:     "300ms",
+		ConfigKeyCacheDenyExpiration: "30ms",
+	})
+	// 允许结果按cache_expiration缓存
+	assert.Nil(invokeTestFilter(filter, newPermissionContext("alice")))
+	assert.Nil(invokeTestFilter(filter, newPermissionContext("alice")))
+	assert.Equal(int32(1), atomic.LoadInt32(&calls))
+	// 拒绝结果按cache_deny_expiration缓存
+	assert.NotNil(invokeTestFilter(filter, newPermissionContext("bob")))
+	assert.NotNil(invokeTestFilter(filter, newPermissionContext("bob")))
+	assert.Equal(int32(2), atomic.LoadInt32(&calls))
+	time.Sleep(60 * time.Millisecond)
+	assert.NotNil(invokeTestFilter(filter, newPermissionContext("bob")))
+	assert.Nil(invokeTestFilter(filter, newPermissionContext("alice")))
+	assert.Equal(int32(3), atomic.LoadInt32(&calls))
+	// 没有主体标识时不缓存
+	assert.NotNil(invokeTestFilter(filter, newPermissionContext("")))
+	assert.NotNil(invokeTestFilter(filter, newPermissionContext("")))
+	assert.Equal(int32(5), atomic.LoadInt32(&calls))
+}
+
+func TestPermissionCacheArgLookupFailed(t *testing.T) {
+	assert := assert2.New(t)
+	var calls int32
+	filter := NewPermissionFilter(PermissionConfig{SkipFunc: testPermissionNoSkip, VerifyFunc: func(_ []flux.Service, _ *flux.Context) (PermissionReport, error) {
+		atomic.AddInt32(&calls, 1)
+		return NewPermissionVerifyReport(true, "", ""), nil
+	}})
+	initTestFilter(t, filter, "permission.args", map[string]interface{}{
+		ConfigKeyCacheDisabled: false,
+		ConfigKeyCacheArgs:     []string{"header:X-Tenant"},
+	})
+	verify := func(tenant string) {
+		ctx := newPermissionContext("alice")
+		if tenant != "" {
+			ctx.Request().Header.Set("X-Tenant", tenant)
+		}
+		_, err := filter.verify([]flux.Service{testPermissionService}, ctx)
+		assert.NoError(err)
+	}
+	// 请求参数不存在时，不缓存
+	verify("")
+	verify("")
+	assert.Equal(int32(2), atomic.LoadInt32(&calls))
+	verify("acme")
+	verify("acme")
+	assert.Equal(int32(3), atomic.LoadInt32(&calls))
+	verify("other")
+	assert.Equal(int32(4), atomic.LoadInt32(&calls))
+}
+
+func TestPermissionCacheSingleflight(t *testing.T) {
+	assert := assert2.New(t)
+	var calls int32
+	release := make(chan struct{})
+	filter := NewPermissionFilter(PermissionConfig{SkipFunc: testPermissionNoSkip, VerifyFunc: func(_ []flux.Service, _ *flux.Context) (PermissionReport, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return NewPermissionVerifyReport(true, "", ""), nil
+	}})
+	initTestFilter(t, filter, "permission.flight", map[string]interface{}{
+		ConfigKeyCacheDisabled: false,
+	})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report, err := filter.verify([]flux.Service{testPermissionService}, newPermissionContext("alice"))
+			assert.NoError(err)
+			assert.True(report.Success)
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestPermissionCacheServiceEvent(t *testing.T) {
+	assert := assert2.New(t)
+	var calls int32
+	var filter *PermissionFilter
+	invalidate := false
+	filter = NewPermissionFilter(PermissionConfig{SkipFunc: testPermissionNoSkip, VerifyFunc: func(_ []flux.Service, _ *flux.Context) (PermissionReport, error) {
+		atomic.AddInt32(&calls, 1)
+		if invalidate {
+			// 验证期间权限服务变更
+			filter.onServiceEvent(flux.ServiceEvent{EventType: flux.EventTypeUpdated, Service: testPermissionService})
+		}
+		return NewPermissionVerifyReport(true, "", ""), nil
+	}})
+	initTestFilter(t, filter, "permission.event", map[string]interface{}{
+		ConfigKeyCacheDisabled: false,
+	})
+	verify := func() {
+		_, err := filter.verify([]flux.Service{testPermissionService}, newPermissionContext("alice"))
+		assert.NoError(err)
+	}
+	verify()
+	verify()
+	assert.Equal(int32(1), atomic.LoadInt32(&calls))
+	// 其它服务变更，不影响缓存
+	filter.onServiceEvent(flux.ServiceEvent{EventType: flux.EventTypeUpdated, Service: flux.Service{ServiceId: "other"}})
+	verify()
+	assert.Equal(int32(1), atomic.LoadInt32(&calls))
+	// 权限服务变更，清除缓存
+	filter.onServiceEvent(flux.ServiceEvent{EventType: flux.EventTypeUpdated, Service: testPermissionService})
+	verify()
+	assert.Equal(int32(2), atomic.LoadInt32(&calls))
+	// 验证期间变更的结果不缓存
+	filter.onServiceEvent(flux.ServiceEvent{EventType: flux.EventTypeUpdated, Service: testPermissionService})
+	invalidate = true
+	verify()
+	invalidate = false
+	verify()
+	verify()
+	assert.Equal(int32(4), atomic.LoadInt32(&calls))
+}
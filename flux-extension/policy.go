@@ -0,0 +1,209 @@
+package fluxext
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/spf13/cast"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	TypeIdPolicyFilter = "policy_filter"
+)
+
+const (
+	ConfigKeyPolicyDryRun    = "dry_run"
+	ConfigKeyPolicyScopesKey = "scopes_key"
+	ConfigKeyPolicyRolesKey  = "roles_key"
+)
+
+// 本地授权策略的Endpoint属性
+const (
+	// 必需的Scope列表，需全部满足
+	EndpointAttrTagPolicyScopes = "policyScopes"
+	// 允许的Role列表，满足任一即可
+	EndpointAttrTagPolicyRoles = "policyRoles"
+	// 策略表达式列表，需全部为true；语法见 PolicyExpr
+	EndpointAttrTagPolicyExpr = "policyExpr"
+	// 只记录决策结果，不拒绝请求
+	EndpointAttrTagPolicyDryRun = "policyDryRun"
+)
+
+var _ flux.Filter = new(PolicyFilter)
+
+// PolicyDecision 策略决策结果
+type PolicyDecision struct {
+	Allowed bool
+	DryRun  bool
+	// 拒绝的原因：scopes, roles, expr
+	Reason string
+	// 拒绝时的策略定义
+	Policy string
+}
+
+// PolicyConfig 本地授权策略配置
+type PolicyConfig struct {
+	SkipFunc flux.FilterSkipper
+	// 读取Scope/Role的Context属性名；属性值为列表，或者以空格分隔的字符串
+	ScopesKey string
+	RolesKey  string
+}
+
+func NewPolicyFilter(c PolicyConfig) *PolicyFilter {
+	return &PolicyFilter{
+		Config: c,
+	}
+}
+
+// PolicyFilter 按Endpoint属性声明的Scope、Role和策略表达式，使用Context属性（例如JWT声明）在本地完成授权，
+// 不需要请求后端权限服务；支持DryRun模式，只记录决策日志而不拒绝请求。
+type PolicyFilter struct {
+	Config PolicyConfig
+	dryRun bool
+	exprs  sync.Map
+}
+
+func (p *PolicyFilter) FilterId() string {
+	return TypeIdPolicyFilter
+}
+
+func (p *PolicyFilter) Init(config *flux.Configuration) error {
+	logger.Info("Policy filter initializing")
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyPolicyDryRun:    false,
+		ConfigKeyPolicyScopesKey: "jwt.scope",
+		ConfigKeyPolicyRolesKey:  "jwt.roles",
+	})
+	p.dryRun = config.GetBool(ConfigKeyPolicyDryRun)
+	if "" == p.Config.ScopesKey {
+		p.Config.ScopesKey = config.GetString(ConfigKeyPolicyScopesKey)
+	}
+	if "" == p.Config.RolesKey {
+		p.Config.RolesKey = config.GetString(ConfigKeyPolicyRolesKey)
+	}
+	if p.Config.SkipFunc == nil {
+		p.Config.SkipFunc = func(_ *flux.Context) bool {
+			return false
+		}
+	}
+	logger.Infow("Policy config", "dry-run", p.dryRun, "scopes-key", p.Config.ScopesKey, "roles-key", p.Config.RolesKey)
+	return nil
+}
+
+func (p *PolicyFilter) DoFilter(next flux.FilterInvoker) flux.FilterInvoker {
+	return func(ctx *flux.Context) *flux.ServeError {
+		if p.Config.SkipFunc(ctx) {
+			return next(ctx)
+		}
+		endpoint := ctx.Endpoint()
+		scopes := endpoint.GetAttr(EndpointAttrTagPolicyScopes).GetStringSlice()
+		roles := endpoint.GetAttr(EndpointAttrTagPolicyRoles).GetStringSlice()
+		exprs := policyExprs(endpoint.GetAttr(EndpointAttrTagPolicyExpr))
+		// 没有任何策略定义
+		if len(scopes) == 0 && len(roles) == 0 && len(exprs) == 0 {
+			return next(ctx)
+		}
+		decision, err := p.Decide(ctx, scopes, roles, exprs)
+		decision.DryRun = p.dryRun || endpoint.GetAttr(EndpointAttrTagPolicyDryRun).GetBool()
+		ctx.AddMetric(p.FilterId(), time.Since(ctx.StartAt()))
+		fields := []interface{}{"allowed", decision.Allowed, "dry-run", decision.DryRun,
+			"reason", decision.Reason, "policy", decision.Policy,
+			"method", endpoint.HttpMethod, "pattern", endpoint.HttpPattern}
+		if nil != err {
+			ctx.Logger().Warnw("POLICY:DECISION:ERROR", append(fields, "error", err)...)
+		} else {
+			ctx.Logger().Infow("POLICY:DECISION", fields...)
+		}
+		if decision.Allowed || decision.DryRun {
+			return next(ctx)
+		}
+		if nil != err {
+			return &flux.ServeError{
+				StatusCode: http.StatusForbidden,
+				ErrorCode:  flux.ErrorCodeGatewayInternal,
+				Message:    flux.ErrorMessagePermissionVerifyError,
+				CauseError: err,
+			}
+		}
+		return &flux.ServeError{
+			StatusCode: http.StatusForbidden,
+			ErrorCode:  flux.ErrorCodePermissionDenied,
+			Message:    flux.ErrorMessagePermissionAccessDenied,
+		}
+	}
+}
+
+// Decide 计算策略决策；策略表达式无效或计算错误时，返回拒绝的决策和错误
+func (p *PolicyFilter) Decide(ctx *flux.Context, scopes, roles, exprs []string) (PolicyDecision, error) {
+	if len(scopes) > 0 {
+		granted := policyValues(ctx, p.Config.ScopesKey)
+		for _, scope := range scopes {
+			if !containsString(granted, scope) {
+				return PolicyDecision{Reason: "scopes", Policy: scope}, nil
+			}
+		}
+	}
+	if len(roles) > 0 {
+		granted := policyValues(ctx, p.Config.RolesKey)
+		matched := false
+		for _, role := range roles {
+			if containsString(granted, role) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return PolicyDecision{Reason: "roles", Policy: strings.Join(roles, ",")}, nil
+		}
+	}
+	for _, source := range exprs {
+		expr, err := p.compile(source)
+		if nil != err {
+			return PolicyDecision{Reason: "expr", Policy: source}, err
+		}
+		ok, err := expr.Eval(ctx)
+		if nil != err || !ok {
+			return PolicyDecision{Reason: "expr", Policy: source}, err
+		}
+	}
+	return PolicyDecision{Allowed: true}, nil
+}
+
+// compile 解析并缓存策略表达式
+func (p *PolicyFilter) compile(source string) (*PolicyExpr, error) {
+	if cached, ok := p.exprs.Load(source); ok {
+		return cached.(*PolicyExpr), nil
+	}
+	expr, err := ParsePolicyExpr(source)
+	if nil != err {
+		return nil, err
+	}
+	p.exprs.Store(source, expr)
+	return expr, nil
+}
+
+// policyExprs 读取策略表达式列表；单个字符串作为一个表达式，不按空格分隔
+func policyExprs(attr flux.Attribute) []string {
+	if s, ok := attr.Value.(string); ok {
+		if strings.TrimSpace(s) == "" {
+			return nil
+		}
+		return []string{s}
+	}
+	return attr.GetStringSlice()
+}
+
+// policyValues 读取列表类型的Context属性；字符串按空格分隔
+func policyValues(ctx *flux.Context, key string) []string {
+	v, ok := ctx.GetAttribute(key)
+	if !ok || v == nil {
+		return nil
+	}
+	if s, ok := v.(string); ok {
+		return strings.Fields(s)
+	}
+	return cast.ToStringSlice(v)
+}
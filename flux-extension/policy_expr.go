@@ -0,0 +1,504 @@
+package fluxext
+
+import (
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/spf13/cast"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// PolicyExpr 策略表达式；支持的语法：
+// 字面量：'text', "text", 123, 1.5, true, false, null, ['a', 'b']
+// 属性：jwt.sub, consumer.metadata.tier（读取Context属性）
+// 运算：!, &&, ||, ==, !=, <, <=, >, >=, in, (...)
+// 函数：has(jwt.sub) 属性是否存在；lookup('header:X-Tenant') 查找请求数据
+// 示例：'admin' in jwt.roles || (jwt.tenant == lookup('header:X-Tenant') && has(jwt.sub))
+type PolicyExpr struct {
+	source string
+	root   policyNode
+}
+
+// ParsePolicyExpr 解析策略表达式
+func ParsePolicyExpr(source string) (*PolicyExpr, error) {
+	tokens, err := lexPolicyExpr(source)
+	if nil != err {
+		return nil, err
+	}
+	p := &policyParser{tokens: tokens}
+	root, err := p.parseOr()
+	if nil != err {
+		return nil, err
+	}
+	if p.peek().kind != policyTokenEOF {
+		return nil, fmt.Errorf("unexpected token: %s, at: %d", p.peek().text, p.peek().pos)
+	}
+	return &PolicyExpr{source: source, root: root}, nil
+}
+
+func (e *PolicyExpr) String() string {
+	return e.source
+}
+
+// Eval 使用Context属性计算表达式，返回布尔结果
+func (e *PolicyExpr) Eval(ctx *flux.Context) (bool, error) {
+	value, err := e.root.eval(ctx)
+	if nil != err {
+		return false, err
+	}
+	return policyTruthy(value), nil
+}
+
+////
+
+type policyTokenKind int
+
+const (
+	policyTokenEOF policyTokenKind = iota
+	policyTokenIdent
+	policyTokenString
+	policyTokenNumber
+	policyTokenOp
+)
+
+type policyToken struct {
+	kind policyTokenKind
+	text string
+	pos  int
+}
+
+func lexPolicyExpr(source string) ([]policyToken, error) {
+	tokens := make([]policyToken, 0, 16)
+	runes := []rune(source)
+	for i := 0; i < len(runes); {
+		c := runes[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '\'' || c == '"':
+			start := i
+			i++
+			var sb strings.Builder
+			for i < len(runes) && runes[i] != c {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				sb.WriteRune(runes[i])
+				i++
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("unterminated string, at: %d", start)
+			}
+			i++
+			tokens = append(tokens, policyToken{kind: policyTokenString, text: sb.String(), pos: start})
+		case unicode.IsDigit(c) || (c == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			start := i
+			i++
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, policyToken{kind: policyTokenNumber, text: string(runes[start:i]), pos: start})
+		case unicode.IsLetter(c) || c == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_' || runes[i] == '.' || runes[i] == '-') {
+				i++
+			}
+			tokens = append(tokens, policyToken{kind: policyTokenIdent, text: string(runes[start:i]), pos: start})
+		default:
+			start := i
+			two := ""
+			if i+1 < len(runes) {
+				two = string(runes[i : i+2])
+			}
+			switch two {
+			case "&&", "||", "==", "!=", "<=", ">=":
+				tokens = append(tokens, policyToken{kind: policyTokenOp, text: two, pos: start})
+				i += 2
+				continue
+			}
+			switch c {
+			case '!', '<', '>', '(', ')', '[', ']', ',':
+				tokens = append(tokens, policyToken{kind: policyTokenOp, text: string(c), pos: start})
+				i++
+			default:
+				return nil, fmt.Errorf("illegal character: %c, at: %d", c, start)
+			}
+		}
+	}
+	return append(tokens, policyToken{kind: policyTokenEOF, pos: len(runes)}), nil
+}
+
+type policyParser struct {
+	tokens []policyToken
+	pos    int
+}
+
+func (p *policyParser) peek() policyToken {
+	return p.tokens[p.pos]
+}
+
+func (p *policyParser) next() policyToken {
+	t := p.tokens[p.pos]
+	if t.kind != policyTokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *policyParser) accept(op string) bool {
+	if t := p.peek(); t.kind == policyTokenOp && t.text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *policyParser) expect(op string) error {
+	if !p.accept(op) {
+		return fmt.Errorf("expected: %s, got: %s, at: %d", op, p.peek().text, p.peek().pos)
+	}
+	return nil
+}
+
+func (p *policyParser) parseOr() (policyNode, error) {
+	left, err := p.parseAnd()
+	if nil != err {
+		return nil, err
+	}
+	for p.accept("||") {
+		right, err := p.parseAnd()
+		if nil != err {
+			return nil, err
+		}
+		left = &policyLogical{op: "||", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *policyParser) parseAnd() (policyNode, error) {
+	left, err := p.parseNot()
+	if nil != err {
+		return nil, err
+	}
+	for p.accept("&&") {
+		right, err := p.parseNot()
+		if nil != err {
+			return nil, err
+		}
+		left = &policyLogical{op: "&&", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *policyParser) parseNot() (policyNode, error) {
+	if p.accept("!") {
+		node, err := p.parseNot()
+		if nil != err {
+			return nil, err
+		}
+		return &policyNot{node: node}, nil
+	}
+	return p.parseCompare()
+}
+
+func (p *policyParser) parseCompare() (policyNode, error) {
+	left, err := p.parsePrimary()
+	if nil != err {
+		return nil, err
+	}
+	t := p.peek()
+	switch {
+	case t.kind == policyTokenOp:
+		switch t.text {
+		case "==", "!=", "<", "<=", ">", ">=":
+		default:
+			return left, nil
+		}
+	case t.kind == policyTokenIdent && t.text == "in":
+	default:
+		return left, nil
+	}
+	op := p.next().text
+	right, err := p.parsePrimary()
+	if nil != err {
+		return nil, err
+	}
+	return &policyCompare{op: op, left: left, right: right}, nil
+}
+
+func (p *policyParser) parsePrimary() (policyNode, error) {
+	t := p.next()
+	switch t.kind {
+	case policyTokenString:
+		return &policyLiteral{value: t.text}, nil
+	case policyTokenNumber:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if nil != err {
+			return nil, fmt.Errorf("illegal number: %s, at: %d", t.text, t.pos)
+		}
+		return &policyLiteral{value: f}, nil
+	case policyTokenIdent:
+		switch t.text {
+		case "true":
+			return &policyLiteral{value: true}, nil
+		case "false":
+			return &policyLiteral{value: false}, nil
+		case "null":
+			return &policyLiteral{value: nil}, nil
+		}
+		if p.accept("(") {
+			return p.parseCall(t)
+		}
+		return &policyAttr{name: t.text}, nil
+	case policyTokenOp:
+		switch t.text {
+		case "(":
+			node, err := p.parseOr()
+			if nil != err {
+				return nil, err
+			}
+			return node, p.expect(")")
+		case "[":
+			list := &policyList{}
+			for !p.accept("]") {
+				if len(list.items) > 0 {
+					if err := p.expect(","); nil != err {
+						return nil, err
+					}
+				}
+				item, err := p.parsePrimary()
+				if nil != err {
+					return nil, err
+				}
+				list.items = append(list.items, item)
+			}
+			return list, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected token: %s, at: %d", t.text, t.pos)
+}
+
+func (p *policyParser) parseCall(fn policyToken) (policyNode, error) {
+	arg, err := p.parsePrimary()
+	if nil != err {
+		return nil, err
+	}
+	if err := p.expect(")"); nil != err {
+		return nil, err
+	}
+	switch fn.text {
+	case "has":
+		attr, ok := arg.(*policyAttr)
+		if !ok {
+			return nil, fmt.Errorf("has() requires an attribute name, at: %d", fn.pos)
+		}
+		return &policyHas{name: attr.name}, nil
+	case "lookup":
+		if lit, ok := arg.(*policyLiteral); ok {
+			if expr, ok := lit.value.(string); ok && expr != "" {
+				return &policyLookup{expr: expr}, nil
+			}
+		}
+		return nil, fmt.Errorf("lookup() requires a lookup expression string, at: %d", fn.pos)
+	default:
+		return nil, fmt.Errorf("unknown function: %s, at: %d", fn.text, fn.pos)
+	}
+}
+
+////
+
+type policyNode interface {
+	eval(ctx *flux.Context) (interface{}, error)
+}
+
+type (
+	policyLiteral struct {
+		value interface{}
+	}
+	policyAttr struct {
+		name string
+	}
+	policyList struct {
+		items []policyNode
+	}
+	policyHas struct {
+		name string
+	}
+	policyLookup struct {
+		expr string
+	}
+	policyNot struct {
+		node policyNode
+	}
+	policyLogical struct {
+		op          string
+		left, right policyNode
+	}
+	policyCompare struct {
+		op          string
+		left, right policyNode
+	}
+)
+
+func (n *policyLiteral) eval(_ *flux.Context) (interface{}, error) {
+	return n.value, nil
+}
+
+func (n *policyAttr) eval(ctx *flux.Context) (interface{}, error) {
+	v, _ := ctx.GetAttribute(n.name)
+	return v, nil
+}
+
+func (n *policyList) eval(ctx *flux.Context) (interface{}, error) {
+	out := make([]interface{}, len(n.items))
+	for i, item := range n.items {
+		v, err := item.eval(ctx)
+		if nil != err {
+			return nil, err
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+func (n *policyHas) eval(ctx *flux.Context) (interface{}, error) {
+	v, ok := ctx.GetAttribute(n.name)
+	return ok && v != nil, nil
+}
+
+func (n *policyLookup) eval(ctx *flux.Context) (interface{}, error) {
+	return common.LookupMTValueByExpr(n.expr, ctx)
+}
+
+func (n *policyNot) eval(ctx *flux.Context) (interface{}, error) {
+	v, err := n.node.eval(ctx)
+	if nil != err {
+		return nil, err
+	}
+	return !policyTruthy(v), nil
+}
+
+func (n *policyLogical) eval(ctx *flux.Context) (interface{}, error) {
+	l, err := n.left.eval(ctx)
+	if nil != err {
+		return nil, err
+	}
+	// 短路计算
+	if lt := policyTruthy(l); (n.op == "||" && lt) || (n.op == "&&" && !lt) {
+		return lt, nil
+	}
+	r, err := n.right.eval(ctx)
+	if nil != err {
+		return nil, err
+	}
+	return policyTruthy(r), nil
+}
+
+func (n *policyCompare) eval(ctx *flux.Context) (interface{}, error) {
+	l, err := n.left.eval(ctx)
+	if nil != err {
+		return nil, err
+	}
+	r, err := n.right.eval(ctx)
+	if nil != err {
+		return nil, err
+	}
+	switch n.op {
+	case "==":
+		return policyEquals(l, r), nil
+	case "!=":
+		return !policyEquals(l, r), nil
+	case "in":
+		return policyContains(r, l), nil
+	}
+	var cmp int
+	lf, lerr := cast.ToFloat64E(l)
+	rf, rerr := cast.ToFloat64E(r)
+	if nil == lerr && nil == rerr && l != nil && r != nil {
+		switch {
+		case lf < rf:
+			cmp = -1
+		case lf > rf:
+			cmp = 1
+		}
+	} else {
+		cmp = strings.Compare(cast.ToString(l), cast.ToString(r))
+	}
+	switch n.op {
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	default:
+		return cmp >= 0, nil
+	}
+}
+
+func policyTruthy(v interface{}) bool {
+	switch tv := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return tv
+	case string:
+		return tv != ""
+	case []interface{}:
+		return len(tv) > 0
+	case []string:
+		return len(tv) > 0
+	}
+	if f, err := cast.ToFloat64E(v); nil == err {
+		return f != 0
+	}
+	return true
+}
+
+func policyEquals(l, r interface{}) bool {
+	if l == nil || r == nil {
+		return l == nil && r == nil
+	}
+	if lb, ok := l.(bool); ok {
+		rb, err := cast.ToBoolE(r)
+		return nil == err && lb == rb
+	}
+	if rb, ok := r.(bool); ok {
+		lb, err := cast.ToBoolE(l)
+		return nil == err && lb == rb
+	}
+	lf, lerr := cast.ToFloat64E(l)
+	rf, rerr := cast.ToFloat64E(r)
+	if nil == lerr && nil == rerr {
+		return lf == rf
+	}
+	return cast.ToString(l) == cast.ToString(r)
+}
+
+// policyContains 判断集合是否包含元素；字符串集合按空格分隔，例如OAuth2的scope声明
+func policyContains(collection, item interface{}) bool {
+	if collection == nil {
+		return false
+	}
+	if s, ok := collection.(string); ok {
+		for _, v := range strings.Fields(s) {
+			if policyEquals(v, item) {
+				return true
+			}
+		}
+		return false
+	}
+	rv := reflect.ValueOf(collection)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return policyEquals(collection, item)
+	}
+	for i := 0; i < rv.Len(); i++ {
+		if policyEquals(rv.Index(i).Interface(), item) {
+			return true
+		}
+	}
+	return false
+}
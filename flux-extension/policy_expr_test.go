@@ -0,0 +1,120 @@
+package fluxext
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	assert2 "github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+// newPolicyContext 创建声明策略属性的Context，并设置授权声明
+func newPolicyContext(attrs []flux.Attribute, claims map[string]interface{}, headers map[string]string) *flux.Context {
+	ctx := newTestContext(flux.Endpoint{
+		HttpMethod: http.MethodGet, HttpPattern: "/orders",
+		EmbeddedAttributes: flux.EmbeddedAttributes{Attributes: attrs},
+	}, headers)
+	for k, v := range claims {
+		ctx.SetAttribute(k, v)
+	}
+	return ctx
+}
+
+func TestPolicyExprEval(t *testing.T) {
+	ctx := newPolicyContext(nil, map[string]interface{}{
+		"jwt.sub":    "alice",
+		"jwt.tenant": "acme",
+		"jwt.scope":  "orders:read orders:write",
+		"jwt.roles":  []string{"admin", "dev"},
+		"jwt.level":  5,
+		"jwt.empty":  nil,
+	}, map[string]string{"X-Tenant": "acme", "X-Other": "other"})
+	cases := []struct {
+		expr     string
+		expected bool
+	}{
+		// 运算优先级：! > 比较 > && > ||
+		{expr: "true || false && false", expected: true},
+		{expr: "(true || false) && false", expected: false},
+		{expr: "false && false || true", expected: true},
+		{expr: "!false && false", expected: false},
+		{expr: "!(false && false)", expected: true},
+		{expr: "!!true", expected: true},
+		{expr: "jwt.level > 3 && jwt.sub == 'alice'", expected: true},
+		{expr: "jwt.sub == 'bob' || jwt.level >= 5 && has(jwt.sub)", expected: true},
+		// 比较
+		{expr: "jwt.level == 5", expected: true},
+		{expr: "jwt.level != '5'", expected: false},
+		{expr: "jwt.level < 3.5", expected: false},
+		{expr: "jwt.level <= -1", expected: false},
+		{expr: "jwt.sub < 'bob'", expected: true},
+		{expr: "jwt.missing == null", expected: true},
+		{expr: "jwt.sub != null", expected: true},
+		// in：字符串集合按空格分隔
+		{expr: "'orders:read' in jwt.scope", expected: true},
+		{expr: "'orders' in jwt.scope", expected: false},
+		{expr: "'orders:delete' in jwt.scope", expected: false},
+		{expr: "'admin' in jwt.roles", expected: true},
+		{expr: "'ops' in jwt.roles", expected: false},
+		{expr: "jwt.tenant in ['acme', \"brand\"]", expected: true},
+		{expr: "jwt.sub in []", expected: false},
+		{expr: "'x' in jwt.missing", expected: false},
+		// has/lookup
+		{expr: "has(jwt.sub)", expected: true},
+		{expr: "has(jwt.missing)", expected: false},
+		{expr: "has(jwt.empty)", expected: false},
+		{expr: "jwt.tenant == lookup('header:X-Tenant')", expected: true},
+		{expr: "jwt.tenant == lookup('header:X-Other')", expected: false},
+		{expr: "lookup('header:x-tenant') in ['acme']", expected: true},
+		// 属性值的真值
+		{expr: "jwt.sub", expected: true},
+		{expr: "jwt.missing", expected: false},
+		{expr: "!jwt.missing", expected: true},
+	}
+	for _, c := range cases {
+		expr, err := ParsePolicyExpr(c.expr)
+		if !assert2.NoError(t, err, c.expr) {
+			continue
+		}
+		assert2.Equal(t, c.expr, expr.String())
+		ok, err := expr.Eval(ctx)
+		assert2.NoError(t, err, c.expr)
+		assert2.Equal(t, c.expected, ok, c.expr)
+	}
+}
+
+func TestPolicyExprParseError(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"jwt.sub ==",
+		"'unterminated",
+		"(true",
+		"true)",
+		"true false",
+		"jwt.sub # 'a'",
+		"jwt.sub & true",
+		"['a' 'b']",
+		"['a',",
+		"has('jwt.sub')",
+		"has(jwt.sub",
+		"lookup(jwt.sub)",
+		"lookup('')",
+		"unknown(jwt.sub)",
+		"1.2.3 > 1",
+	} {
+		_, err := ParsePolicyExpr(expr)
+		assert2.Error(t, err, expr)
+	}
+}
+
+func TestPolicyExprEvalError(t *testing.T) {
+	expr, err := ParsePolicyExpr("lookup('illegal') == 'x'")
+	assert2.NoError(t, err)
+	_, err = expr.Eval(newPolicyContext(nil, nil, nil))
+	assert2.Error(t, err)
+	// 短路计算，不执行右侧表达式
+	expr, err = ParsePolicyExpr("true || lookup('illegal') == 'x'")
+	assert2.NoError(t, err)
+	ok, err := expr.Eval(newPolicyContext(nil, nil, nil))
+	assert2.NoError(t, err)
+	assert2.True(t, ok)
+}
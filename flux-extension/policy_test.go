@@ -0,0 +1,79 @@
+package fluxext
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	assert2 "github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestPolicyFilterDecide(t *testing.T) {
+	filter := NewPolicyFilter(PolicyConfig{})
+	initTestFilter(t, filter, "policy.decide", nil)
+	claims := map[string]interface{}{
+		"jwt.sub":   "alice",
+		"jwt.scope": "orders:read orders:write",
+		"jwt.roles": []interface{}{"dev"},
+	}
+	cases := []struct {
+		name    string
+		scopes  []string
+		roles   []string
+		exprs   []string
+		allowed bool
+		reason  string
+		policy  string
+		err     bool
+	}{
+		{name: "scopes", scopes: []string{"orders:read", "orders:write"}, allowed: true},
+		{name: "scopes-all", scopes: []string{"orders:read", "orders:delete"}, reason: "scopes", policy: "orders:delete"},
+		{name: "roles-any", roles: []string{"admin", "dev"}, allowed: true},
+		{name: "roles-none", roles: []string{"admin", "ops"}, reason: "roles", policy: "admin,ops"},
+		{name: "exprs", exprs: []string{"has(jwt.sub)", "'orders:read' in jwt.scope"}, allowed: true},
+		{name: "exprs-all", exprs: []string{"has(jwt.sub)", "jwt.sub == 'bob'"}, reason: "expr", policy: "jwt.sub == 'bob'"},
+		{name: "expr-invalid", exprs: []string{"jwt.sub =="}, reason: "expr", policy: "jwt.sub ==", err: true},
+		{name: "expr-error", exprs: []string{"lookup('illegal')"}, reason: "expr", policy: "lookup('illegal')", err: true},
+		// 先检查Scope，再检查Role和表达式
+		{name: "order", scopes: []string{"admin:all"}, roles: []string{"ops"}, exprs: []string{"false"}, reason: "scopes", policy: "admin:all"},
+	}
+	for _, c := range cases {
+		decision, err := filter.Decide(newPolicyContext(nil, claims, nil), c.scopes, c.roles, c.exprs)
+		assert2.Equal(t, c.err, err != nil, c.name)
+		assert2.Equal(t, c.allowed, decision.Allowed, c.name)
+		assert2.Equal(t, c.reason, decision.Reason, c.name)
+		assert2.Equal(t, c.policy, decision.Policy, c.name)
+	}
+}
+
+func TestPolicyFilterDryRun(t *testing.T) {
+	assert := assert2.New(t)
+	claims := map[string]interface{}{"jwt.scope": "orders:read"}
+	denied := []flux.Attribute{{Name: EndpointAttrTagPolicyScopes, Value: []string{"orders:write"}}}
+	filter := NewPolicyFilter(PolicyConfig{})
+	initTestFilter(t, filter, "policy.enforce", nil)
+	serr := invokeTestFilter(filter, newPolicyContext(denied, claims, nil))
+	if assert.NotNil(serr) {
+		assert.Equal(http.StatusForbidden, serr.StatusCode)
+		assert.Equal(flux.ErrorCodePermissionDenied, serr.ErrorCode)
+	}
+	// 表达式无效时拒绝
+	serr = invokeTestFilter(filter, newPolicyContext([]flux.Attribute{
+		{Name: EndpointAttrTagPolicyExpr, Value: "jwt.sub =="},
+	}, claims, nil))
+	if assert.NotNil(serr) {
+		assert.Equal(flux.ErrorCodeGatewayInternal, serr.ErrorCode)
+	}
+	// 没有策略定义
+	assert.Nil(invokeTestFilter(filter, newPolicyContext(nil, claims, nil)))
+	// Endpoint声明DryRun，拒绝的请求继续处理
+	assert.Nil(invokeTestFilter(filter, newPolicyContext(append([]flux.Attribute{
+		{Name: EndpointAttrTagPolicyDryRun, Value: true},
+	}, denied...), claims, nil)))
+	// 全局DryRun
+	filter = NewPolicyFilter(PolicyConfig{})
+	initTestFilter(t, filter, "policy.dryrun", map[string]interface{}{ConfigKeyPolicyDryRun: true})
+	assert.Nil(invokeTestFilter(filter, newPolicyContext(denied, claims, nil)))
+	assert.Nil(invokeTestFilter(filter, newPolicyContext([]flux.Attribute{
+		{Name: EndpointAttrTagPolicyExpr, Value: "jwt.sub =="},
+	}, claims, nil)))
+}
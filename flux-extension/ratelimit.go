@@ -0,0 +1,420 @@
+package fluxext
+
+import (
+	"errors"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/bytepowered/flux/flux-pkg"
+	"github.com/spf13/cast"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	TypeIdRateLimitFilter = "ratelimit_filter"
+)
+
+const (
+	ConfigKeyRateAlgorithm = "algorithm"
+	ConfigKeyRateLimit     = "limit"
+	ConfigKeyRateBurst     = "burst"
+	ConfigKeyRateWindow    = "window"
+	ConfigKeyRateKeys      = "keys"
+)
+
+// 限流算法
+const (
+	RateAlgorithmTokenBucket   = "token_bucket"
+	RateAlgorithmSlidingWindow = "sliding_window"
+)
+
+const (
+	HeaderRateLimitLimit     = "X-RateLimit-Limit"
+	HeaderRateLimitRemaining = "X-RateLimit-Remaining"
+)
+
+const (
+	// 共享存储CompareAndSwap冲突时的重试次数
+	rateLimitSwapRetries = 8
+	// 本地限流状态的过期清理间隔
+	rateLimitSweepInterval = time.Minute
+	// 限流Key读取失败时，按客户端IP限流的Key前缀
+	rateLimitFallbackKeyPrefix = "@ip:"
+)
+
+var _ flux.Filter = new(RateLimitFilter)
+
+var (
+	ErrRateLimitContention = errors.New("ratelimit: store compare-and-swap contention")
+)
+
+// RateLimitRule 限流规则
+type RateLimitRule struct {
+	Name      string        // 规则名称，与限流Key组合为计数Key
+	Algorithm string        // 限流算法：token_bucket, sliding_window
+	Limit     int64         // 每个时间窗口允许的请求数；为0时不限流
+	Burst     int64         // 令牌桶容量，默认等于Limit
+	Window    time.Duration // 时间窗口
+	Keys      []string      // 限流Key的Lookup表达式列表，例如：request:ip, attr:jwt.sub, header:X-App-Id, request:endpoint；任一Key读取失败时，按客户端IP限流
+}
+
+// RateLimitState 限流计数状态
+type RateLimitState struct {
+	Value float64 // 令牌桶：剩余令牌数；滑动窗口：当前窗口的请求数
+	Prev  float64 // 滑动窗口：上一窗口的请求数
+	Stamp int64   // 令牌桶：上次填充时间；滑动窗口：当前窗口的开始时间；UnixNano
+}
+
+// RateLimitResult 限流检查结果
+type RateLimitResult struct {
+	Allowed    bool
+	Remaining  int64
+	RetryAfter time.Duration
+}
+
+// RateLimiter 按Key和规则获取请求许可
+type RateLimiter interface {
+	Take(key string, rule RateLimitRule, now time.Time) (RateLimitResult, error)
+}
+
+// RateLimitStore 共享限流状态存储，用于多个网关节点共享限流计数；
+// 实现需要保证CompareAndSwap的原子性，例如基于Redis的Lua脚本。
+type RateLimitStore interface {
+	// Load 加载Key的限流状态；Key不存在时，found返回false
+	Load(key string) (state RateLimitState, found bool, err error)
+	// CompareAndSwap 当Key的状态与old相同(found为false时要求Key不存在)时，更新为new并设置过期时间
+	CompareAndSwap(key string, old RateLimitState, found bool, new RateLimitState, ttl time.Duration) (swapped bool, err error)
+}
+
+// Take 根据限流状态计算一次请求许可，返回更新后的状态
+func (rule RateLimitRule) Take(state RateLimitState, found bool, now time.Time) (RateLimitState, RateLimitResult) {
+	if rule.Algorithm == RateAlgorithmSlidingWindow {
+		return rule.takeSlidingWindow(state, found, now)
+	}
+	return rule.takeTokenBucket(state, found, now)
+}
+
+// TTL 返回限流状态的有效期
+func (rule RateLimitRule) TTL() time.Duration {
+	return 2 * rule.Window
+}
+
+func (rule RateLimitRule) takeTokenBucket(state RateLimitState, found bool, now time.Time) (RateLimitState, RateLimitResult) {
+	capacity := float64(rule.Burst)
+	if capacity <= 0 {
+		capacity = float64(rule.Limit)
+	}
+	// 每纳秒填充的令牌数
+	rate := float64(rule.Limit) / float64(rule.Window)
+	stamp := now.UnixNano()
+	if !found {
+		state = RateLimitState{Value: capacity, Stamp: stamp}
+	} else if elapsed := stamp - state.Stamp; elapsed > 0 {
+		state.Value = math.Min(capacity, state.Value+float64(elapsed)*rate)
+		state.Stamp = stamp
+	}
+	if state.Value >= 1 {
+		state.Value--
+		return state, RateLimitResult{Allowed: true, Remaining: int64(state.Value)}
+	}
+	return state, RateLimitResult{RetryAfter: time.Duration((1 - state.Value) / rate)}
+}
+
+// takeSlidingWindow 滑动窗口：按上一窗口计数的剩余时间比例，加权估算当前滑动窗口内的请求数
+func (rule RateLimitRule) takeSlidingWindow(state RateLimitState, found bool, now time.Time) (RateLimitState, RateLimitResult) {
+	window := int64(rule.Window)
+	stamp := now.UnixNano()
+	start := stamp - stamp%window
+	if !found {
+		state = RateLimitState{Stamp: start}
+	} else if state.Stamp != start {
+		if start-state.Stamp == window {
+			state.Prev = state.Value
+		} else {
+			state.Prev = 0
+		}
+		state.Value, state.Stamp = 0, start
+	}
+	limit := float64(rule.Limit)
+	elapsed := float64(stamp-start) / float64(window)
+	estimate := state.Prev*(1-elapsed) + state.Value
+	if estimate+1 <= limit {
+		state.Value++
+		return state, RateLimitResult{Allowed: true, Remaining: int64(limit - estimate - 1)}
+	}
+	// 等待上一窗口的加权计数衰减，或者等待当前窗口结束
+	retry := time.Duration(start + window - stamp)
+	if state.Prev > 0 && limit-1-state.Value >= 0 {
+		at := 1 - (limit-1-state.Value)/state.Prev
+		retry = time.Duration(float64(start) + at*float64(window) - float64(stamp))
+	}
+	return state, RateLimitResult{RetryAfter: retry}
+}
+
+////
+
+// LocalRateLimiter 进程内限流，限流计数仅在当前网关节点有效
+type LocalRateLimiter struct {
+	mu        sync.Mutex
+	states    map[string]*localRateState
+	lastSweep time.Time
+}
+
+type localRateState struct {
+	state    RateLimitState
+	expireAt time.Time
+}
+
+func NewLocalRateLimiter() *LocalRateLimiter {
+	return &LocalRateLimiter{states: make(map[string]*localRateState, 64), lastSweep: time.Now()}
+}
+
+func (l *LocalRateLimiter) Take(key string, rule RateLimitRule, now time.Time) (RateLimitResult, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.lastSweep) > rateLimitSweepInterval {
+		for k, v := range l.states {
+			if now.After(v.expireAt) {
+				delete(l.states, k)
+			}
+		}
+		l.lastSweep = now
+	}
+	local, found := l.states[key]
+	if found && now.After(local.expireAt) {
+		found = false
+	}
+	if !found {
+		local = new(localRateState)
+		l.states[key] = local
+	}
+	state, result := rule.Take(local.state, found, now)
+	local.state, local.expireAt = state, now.Add(rule.TTL())
+	return result, nil
+}
+
+// SharedRateLimiter 基于共享存储的分布式限流
+type SharedRateLimiter struct {
+	store RateLimitStore
+}
+
+func NewSharedRateLimiter(store RateLimitStore) *SharedRateLimiter {
+	return &SharedRateLimiter{store: store}
+}
+
+func (l *SharedRateLimiter) Take(key string, rule RateLimitRule, now time.Time) (RateLimitResult, error) {
+	for i := 0; i < rateLimitSwapRetries; i++ {
+		old, found, err := l.store.Load(key)
+		if nil != err {
+			return RateLimitResult{}, err
+		}
+		state, result := rule.Take(old, found, now)
+		swapped, err := l.store.CompareAndSwap(key, old, found, state, rule.TTL())
+		if nil != err {
+			return RateLimitResult{}, err
+		}
+		if swapped {
+			return result, nil
+		}
+	}
+	return RateLimitResult{}, ErrRateLimitContention
+}
+
+// MemoryRateLimitStore 进程内的RateLimitStore实现，用于测试和单节点验证共享存储的限流逻辑
+type MemoryRateLimitStore struct {
+	mu    sync.Mutex
+	items map[string]memoryRateItem
+}
+
+type memoryRateItem struct {
+	state    RateLimitState
+	expireAt time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{items: make(map[string]memoryRateItem, 64)}
+}
+
+func (s *MemoryRateLimitStore) Load(key string) (RateLimitState, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.items[key]
+	if !ok || time.Now().After(item.expireAt) {
+		return RateLimitState{}, false, nil
+	}
+	return item.state, true, nil
+}
+
+func (s *MemoryRateLimitStore) CompareAndSwap(key string, old RateLimitState, found bool, new RateLimitState, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.items[key]
+	if ok && time.Now().After(item.expireAt) {
+		ok = false
+	}
+	if ok != found || (ok && item.state != old) {
+		return false, nil
+	}
+	s.items[key] = memoryRateItem{state: new, expireAt: time.Now().Add(ttl)}
+	return true, nil
+}
+
+////
+
+// RateLimitConfig 限流配置
+type RateLimitConfig struct {
+	SkipFunc flux.FilterSkipper
+	// 限流器，默认为进程内限流
+	Limiter RateLimiter
+}
+
+func NewRateLimitFilter(c RateLimitConfig) *RateLimitFilter {
+	return &RateLimitFilter{
+		Config: c,
+		rules:  sync.Map{},
+	}
+}
+
+// RateLimitFilter 按Key限流；支持全局、应用级别(applications)和单个服务(service)的限流规则
+type RateLimitFilter struct {
+	Config       RateLimitConfig
+	defaults     RateLimitRule
+	rules        sync.Map
+	services     *flux.Configuration
+	applications *flux.Configuration
+}
+
+func (r *RateLimitFilter) FilterId() string {
+	return TypeIdRateLimitFilter
+}
+
+func (r *RateLimitFilter) Init(c *flux.Configuration) error {
+	logger.Info("RateLimit filter initializing")
+	r.applications = c.Sub(ConfigApplication)
+	r.services = c.Sub(ConfigService)
+	c.SetDefaults(map[string]interface{}{
+		ConfigKeyRateAlgorithm: RateAlgorithmTokenBucket,
+		ConfigKeyRateLimit:     0,
+		ConfigKeyRateBurst:     0,
+		ConfigKeyRateWindow:    "1s",
+		ConfigKeyRateKeys:      []string{"request:ip"},
+	})
+	r.defaults = r.readRule("global", c, RateLimitRule{})
+	if r.Config.SkipFunc == nil {
+		r.Config.SkipFunc = func(c *flux.Context) bool {
+			return false
+		}
+	}
+	if r.Config.Limiter == nil {
+		r.Config.Limiter = NewLocalRateLimiter()
+	}
+	logger.Infow("RateLimit default config",
+		"algorithm", r.defaults.Algorithm,
+		"limit", r.defaults.Limit,
+		"burst", r.defaults.Burst,
+		"window", r.defaults.Window.String(),
+		"keys", r.defaults.Keys,
+	)
+	return nil
+}
+
+func (r *RateLimitFilter) DoFilter(next flux.FilterInvoker) flux.FilterInvoker {
+	return func(ctx *flux.Context) *flux.ServeError {
+		if r.Config.SkipFunc(ctx) {
+			return next(ctx)
+		}
+		rule := r.ruleOf(ctx)
+		if rule.Limit <= 0 || rule.Window <= 0 {
+			return next(ctx)
+		}
+		key := rule.Name + ":" + r.lookupKey(ctx, rule)
+		result, err := r.Config.Limiter.Take(key, rule, time.Now())
+		if nil != err {
+			// 限流存储异常时，不拦截请求
+			ctx.Logger().Warnw("RATELIMIT:TAKE:ERROR", "key", key, "error", err)
+			return next(ctx)
+		}
+		header := ctx.ResponseWriter().Header()
+		header.Set(HeaderRateLimitLimit, strconv.FormatInt(rule.Limit, 10))
+		header.Set(HeaderRateLimitRemaining, strconv.FormatInt(result.Remaining, 10))
+		if result.Allowed {
+			return next(ctx)
+		}
+		retry := int64(math.Ceil(result.RetryAfter.Seconds()))
+		if retry < 1 {
+			retry = 1
+		}
+		ctx.Logger().Infow("RATELIMIT:REJECTED", "key", key, "retry-after", retry)
+		return &flux.ServeError{
+			StatusCode: http.StatusTooManyRequests,
+			ErrorCode:  flux.ErrorCodeRequestRateLimited,
+			Message:    "RATELIMIT:TOO_MANY_REQUESTS",
+			Header:     http.Header{flux.HeaderRetryAfter: []string{strconv.FormatInt(retry, 10)}},
+		}
+	}
+}
+
+// ruleOf 按优先级选择限流规则：单个服务 > 应用级别 > 全局
+func (r *RateLimitFilter) ruleOf(ctx *flux.Context) RateLimitRule {
+	serviceId := ctx.ServiceID()
+	if rule, ok := r.rules.Load(serviceId); ok {
+		return rule.(RateLimitRule)
+	}
+	rule := r.defaults
+	if r.services.IsSet(serviceId) {
+		rule = r.readRule("service:"+serviceId, r.services.Sub(serviceId), r.defaults)
+	} else if app := ctx.Application(); r.applications.IsSet(app) {
+		rule = r.readRule("app:"+app, r.applications.Sub(app), r.defaults)
+	}
+	r.rules.Store(serviceId, rule)
+	return rule
+}
+
+// lookupKey 读取限流Key；任一Key读取失败或不存在时，按客户端IP限流，避免不同客户端共用同一个计数
+func (r *RateLimitFilter) lookupKey(ctx *flux.Context, rule RateLimitRule) string {
+	values := make([]string, len(rule.Keys))
+	for i, expr := range rule.Keys {
+		scope, key, ok := fluxpkg.LookupParseExpr(expr)
+		if !ok {
+			ctx.Logger().Warnw("RATELIMIT:LOOKUP_KEY", "expr", expr, "error", "illegal lookup expr")
+			return rateLimitFallbackKeyPrefix + common.RemoteIP(ctx)
+		}
+		value, err := common.LookupMTValue(scope, key, ctx)
+		if nil != err || !value.Valid {
+			ctx.Logger().Debugw("RATELIMIT:LOOKUP_KEY:FALLBACK", "expr", expr, "error", err)
+			return rateLimitFallbackKeyPrefix + common.RemoteIP(ctx)
+		}
+		values[i] = cast.ToString(value.Value)
+	}
+	return strings.Join(values, "|")
+}
+
+func (*RateLimitFilter) readRule(name string, conf *flux.Configuration, defaults RateLimitRule) RateLimitRule {
+	rule := defaults
+	rule.Name = name
+	if conf.IsSet(ConfigKeyRateAlgorithm) {
+		rule.Algorithm = strings.ToLower(conf.GetString(ConfigKeyRateAlgorithm))
+	}
+	if rule.Algorithm != RateAlgorithmSlidingWindow && rule.Algorithm != RateAlgorithmTokenBucket {
+		logger.Warnw("RATELIMIT:ALGORITHM:UNKNOWN", "rule", name, "algorithm", rule.Algorithm)
+		rule.Algorithm = RateAlgorithmTokenBucket
+	}
+	if conf.IsSet(ConfigKeyRateLimit) {
+		rule.Limit = conf.GetInt64(ConfigKeyRateLimit)
+	}
+	if conf.IsSet(ConfigKeyRateBurst) {
+		rule.Burst = conf.GetInt64(ConfigKeyRateBurst)
+	}
+	if conf.IsSet(ConfigKeyRateWindow) {
+		rule.Window = conf.GetDuration(ConfigKeyRateWindow)
+	}
+	if conf.IsSet(ConfigKeyRateKeys) {
+		rule.Keys = conf.GetStringSlice(ConfigKeyRateKeys)
+	}
+	return rule
+}
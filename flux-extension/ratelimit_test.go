@@ -0,0 +1,106 @@
+package fluxext
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	assert2 "github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRateLimitTokenBucket(t *testing.T) {
+	assert := assert2.New(t)
+	rule := RateLimitRule{Name: "tb", Algorithm: RateAlgorithmTokenBucket, Limit: 2, Burst: 3, Window: time.Second}
+	for name, limiter := range map[string]RateLimiter{
+		"local":  NewLocalRateLimiter(),
+		"shared": NewSharedRateLimiter(NewMemoryRateLimitStore()),
+	} {
+		now := time.Now()
+		// 桶容量为burst
+		for i := int64(2); i >= 0; i-- {
+			result, err := limiter.Take("k", rule, now)
+			assert.NoError(err, name)
+			assert.True(result.Allowed, name)
+			assert.Equal(i, result.Remaining, name)
+		}
+		result, _ := limiter.Take("k", rule, now)
+		assert.False(result.Allowed, name)
+		assert.InDelta(float64(500*time.Millisecond), float64(result.RetryAfter), float64(time.Microsecond), name)
+		// 按limit/window填充令牌
+		result, _ = limiter.Take("k", rule, now.Add(500*time.Millisecond))
+		assert.True(result.Allowed, name)
+		result, _ = limiter.Take("k", rule, now.Add(500*time.Millisecond))
+		assert.False(result.Allowed, name)
+		// 不同Key独立计数
+		result, _ = limiter.Take("other", rule, now)
+		assert.True(result.Allowed, name)
+	}
+}
+
+func TestRateLimitSlidingWindow(t *testing.T) {
+	assert := assert2.New(t)
+	rule := RateLimitRule{Name: "sw", Algorithm: RateAlgorithmSlidingWindow, Limit: 4, Window: time.Second}
+	for name, limiter := range map[string]RateLimiter{
+		"local":  NewLocalRateLimiter(),
+		"shared": NewSharedRateLimiter(NewMemoryRateLimitStore()),
+	} {
+		start := time.Now().Truncate(time.Second).Add(time.Second)
+		for i := int64(3); i >= 0; i-- {
+			result, err := limiter.Take("k", rule, start)
+			assert.NoError(err, name)
+			assert.True(result.Allowed, name)
+			assert.Equal(i, result.Remaining, name)
+		}
+		result, _ := limiter.Take("k", rule, start.Add(200*time.Millisecond))
+		assert.False(result.Allowed, name)
+		assert.Equal(800*time.Millisecond, result.RetryAfter, name)
+		// 下一窗口过半：上一窗口的计数按剩余比例加权，估算为2
+		mid := start.Add(1500 * time.Millisecond)
+		for i := 0; i < 2; i++ {
+			result, _ = limiter.Take("k", rule, mid)
+			assert.True(result.Allowed, name)
+		}
+		result, _ = limiter.Take("k", rule, mid)
+		assert.False(result.Allowed, name)
+		assert.True(result.RetryAfter > 0 && result.RetryAfter <= 500*time.Millisecond, name)
+	}
+}
+
+func newRateLimitContext(remote, appId string) *flux.Context {
+	headers := map[string]string{}
+	if appId != "" {
+		headers["X-App-Id"] = appId
+	}
+	return newRemoteContext(flux.Endpoint{
+		Application: "shop",
+		Service:     flux.Service{Interface: "com.foo.OrderService", Method: "list"},
+	}, remote, headers)
+}
+
+func TestRateLimitFilter(t *testing.T) {
+	assert := assert2.New(t)
+	filter := NewRateLimitFilter(RateLimitConfig{Limiter: NewSharedRateLimiter(NewMemoryRateLimitStore())})
+	initTestFilter(t, filter, "ratelimit.test", map[string]interface{}{
+		ConfigKeyRateLimit:  1,
+		ConfigKeyRateWindow: "1m",
+		ConfigKeyRateKeys:   []string{"header:X-App-Id"},
+	})
+	ctx := newRateLimitContext("1.1.1.1:80", "app1")
+	assert.Nil(invokeTestFilter(filter, ctx))
+	header := ctx.ResponseWriter().Header()
+	assert.Equal("1", header.Get(HeaderRateLimitLimit))
+	assert.Equal("0", header.Get(HeaderRateLimitRemaining))
+	// 相同Key超出限制
+	ctx = newRateLimitContext("2.2.2.2:80", "app1")
+	serr := invokeTestFilter(filter, ctx)
+	if assert.NotNil(serr) {
+		assert.Equal(http.StatusTooManyRequests, serr.StatusCode)
+		assert.Equal(flux.ErrorCodeRequestRateLimited, serr.ErrorCode)
+		assert.Equal("60", serr.Header.Get(flux.HeaderRetryAfter))
+	}
+	assert.Equal("0", ctx.ResponseWriter().Header().Get(HeaderRateLimitRemaining))
+	// 限流Key不存在时，按客户端IP限流，不共用计数
+	assert.Nil(invokeTestFilter(filter, newRateLimitContext("3.3.3.3:80", "")))
+	assert.Nil(invokeTestFilter(filter, newRateLimitContext("4.4.4.4:80", "")))
+	assert.NotNil(invokeTestFilter(filter, newRateLimitContext("4.4.4.4:80", "")))
+}
@@ -0,0 +1,313 @@
+package fluxext
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	TypeIdSignatureFilter = "signature_filter"
+)
+
+const (
+	ConfigKeySignKeyIdHeader     = "key_id_header"
+	ConfigKeySignTimestampHeader = "timestamp_header"
+	ConfigKeySignNonceHeader     = "nonce_header"
+	ConfigKeySignSignatureHeader = "signature_header"
+	ConfigKeySignAlgorithm       = "algorithm"
+	ConfigKeySignClockSkew       = "clock_skew"
+)
+
+// 签名算法
+const (
+	SignAlgorithmHmacSHA256 = "hmac-sha256"
+	SignAlgorithmHmacSHA512 = "hmac-sha512"
+)
+
+const (
+	// 内存NonceStore的过期清理间隔
+	nonceSweepInterval = time.Minute
+)
+
+var _ flux.Filter = new(SignatureFilter)
+
+// NonceStore 请求签名的Nonce存储，用于拒绝重放请求；
+// 多个网关节点部署时，需要使用共享存储，例如基于Redis的SET NX EX。
+type NonceStore interface {
+	// Claim 记录Nonce并设置有效期；Nonce在有效期内已存在时，返回false
+	Claim(key string, ttl time.Duration) (bool, error)
+}
+
+// SignatureSecretFunc 按签名KeyId查找Consumer；返回的Consumer.Secrets用于验证签名
+type SignatureSecretFunc func(ctx *flux.Context, keyId string) (flux.Consumer, bool)
+
+// MemoryNonceStore 进程内的NonceStore实现，仅在当前网关节点有效
+type MemoryNonceStore struct {
+	mu        sync.Mutex
+	items     map[string]time.Time
+	lastSweep time.Time
+}
+
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{items: make(map[string]time.Time, 1024), lastSweep: time.Now()}
+}
+
+func (s *MemoryNonceStore) Claim(key string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if now.Sub(s.lastSweep) > nonceSweepInterval {
+		for k, expireAt := range s.items {
+			if now.After(expireAt) {
+				delete(s.items, k)
+			}
+		}
+		s.lastSweep = now
+	}
+	if expireAt, ok := s.items[key]; ok && !now.After(expireAt) {
+		return false, nil
+	}
+	s.items[key] = now.Add(ttl)
+	return true, nil
+}
+
+// SignatureConfig 请求签名验证配置
+type SignatureConfig struct {
+	SkipFunc     flux.FilterSkipper
+	AttKeyPrefix string
+	// 查找签名Consumer的函数，默认按KeyId查找已注册的Consumer
+	SecretFunc SignatureSecretFunc
+	// Nonce存储，默认为进程内存储
+	NonceStore NonceStore
+}
+
+func NewSignatureFilter(c SignatureConfig) *SignatureFilter {
+	return &SignatureFilter{
+		Config: c,
+	}
+}
+
+// SignatureFilter 验证Endpoint属性signature=true的请求签名；签名为使用Consumer密钥计算的HMAC，签名内容为：
+// timestamp + "\n" + nonce + "\n" + METHOD + "\n" + path + "\n" + 排序的query + "\n" + hex(sha256(body))；
+// 时间戳(Unix秒)超出允许的时钟偏差，或者Nonce重复时，拒绝请求；
+// Consumer信息写入Context属性：<prefix>.id, <prefix>.name, <prefix>.metadata.<key>, <prefix>.quota.<key>
+type SignatureFilter struct {
+	Config          SignatureConfig
+	keyIdHeader     string
+	timestampHeader string
+	nonceHeader     string
+	signatureHeader string
+	algorithm       func() hash.Hash
+	clockSkew       time.Duration
+}
+
+func (f *SignatureFilter) FilterId() string {
+	return TypeIdSignatureFilter
+}
+
+func (f *SignatureFilter) Init(config *flux.Configuration) error {
+	logger.Info("Signature filter initializing")
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyAttachmentKey:       "consumer",
+		ConfigKeySignKeyIdHeader:     "X-Sign-Key",
+		ConfigKeySignTimestampHeader: "X-Sign-Timestamp",
+		ConfigKeySignNonceHeader:     "X-Sign-Nonce",
+		ConfigKeySignSignatureHeader: "X-Sign-Signature",
+		ConfigKeySignAlgorithm:       SignAlgorithmHmacSHA256,
+		ConfigKeySignClockSkew:       "5m",
+	})
+	if "" == f.Config.AttKeyPrefix {
+		f.Config.AttKeyPrefix = config.GetString(ConfigKeyAttachmentKey)
+	}
+	if f.Config.SkipFunc == nil {
+		f.Config.SkipFunc = func(_ *flux.Context) bool {
+			return false
+		}
+	}
+	if f.Config.SecretFunc == nil {
+		f.Config.SecretFunc = func(_ *flux.Context, keyId string) (flux.Consumer, bool) {
+			return ext.ConsumerByID(keyId)
+		}
+	}
+	if f.Config.NonceStore == nil {
+		f.Config.NonceStore = NewMemoryNonceStore()
+	}
+	f.keyIdHeader = config.GetString(ConfigKeySignKeyIdHeader)
+	f.timestampHeader = config.GetString(ConfigKeySignTimestampHeader)
+	f.nonceHeader = config.GetString(ConfigKeySignNonceHeader)
+	f.signatureHeader = config.GetString(ConfigKeySignSignatureHeader)
+	f.clockSkew = config.GetDuration(ConfigKeySignClockSkew)
+	algorithm := strings.ToLower(config.GetString(ConfigKeySignAlgorithm))
+	switch algorithm {
+	case SignAlgorithmHmacSHA256:
+		f.algorithm = sha256.New
+	case SignAlgorithmHmacSHA512:
+		f.algorithm = sha512.New
+	default:
+		return fmt.Errorf("signature: unsupported algorithm: %s", algorithm)
+	}
+	logger.Infow("Signature config", "algorithm", algorithm, "clock-skew", f.clockSkew.String(),
+		"key-id-header", f.keyIdHeader, "signature-header", f.signatureHeader)
+	return nil
+}
+
+func (f *SignatureFilter) DoFilter(next flux.FilterInvoker) flux.FilterInvoker {
+	return func(ctx *flux.Context) *flux.ServeError {
+		if !ctx.Endpoint().RequireSignature() || f.Config.SkipFunc(ctx) {
+			return next(ctx)
+		}
+		keyId := ctx.HeaderVar(f.keyIdHeader)
+		timestamp := ctx.HeaderVar(f.timestampHeader)
+		nonce := ctx.HeaderVar(f.nonceHeader)
+		signature := ctx.HeaderVar(f.signatureHeader)
+		if keyId == "" || timestamp == "" || nonce == "" || signature == "" {
+			return newSignatureError(flux.ErrorCodeSignatureNotFound, "SIGNATURE:VALIDATE: signature headers not found", nil)
+		}
+		signedAt, err := strconv.ParseInt(timestamp, 10, 64)
+		if nil != err {
+			return newSignatureError(flux.ErrorCodeSignatureInvalid, "SIGNATURE:VALIDATE: invalid timestamp", err)
+		}
+		if skew := time.Since(time.Unix(signedAt, 0)); skew > f.clockSkew || skew < -f.clockSkew {
+			ctx.Logger().Infow("SIGNATURE:VALIDATE:EXPIRED", "key-id", keyId, "timestamp", timestamp, "skew", skew.String())
+			return newSignatureError(flux.ErrorCodeSignatureExpired, "SIGNATURE:VALIDATE: timestamp out of clock skew", nil)
+		}
+		consumer, ok := f.Config.SecretFunc(ctx, keyId)
+		if !ok || consumer.Disabled || len(consumer.Secrets) == 0 {
+			ctx.Logger().Infow("SIGNATURE:VALIDATE:REJECTED", "key-id", keyId, "disabled", consumer.Disabled)
+			return newSignatureError(flux.ErrorCodeSignatureInvalid, "SIGNATURE:VALIDATE: signature is invalid", nil)
+		}
+		content, err := f.stringToSign(ctx, timestamp, nonce)
+		if nil != err {
+			return &flux.ServeError{
+				StatusCode: flux.StatusBadRequest,
+				ErrorCode:  flux.ErrorCodeRequestInvalid,
+				Message:    flux.ErrorMessageRequestPrepare,
+				CauseError: err,
+			}
+		}
+		if !f.verify(content, signature, consumer.Secrets) {
+			ctx.Logger().Infow("SIGNATURE:VALIDATE:MISMATCH", "key-id", keyId)
+			return newSignatureError(flux.ErrorCodeSignatureInvalid, "SIGNATURE:VALIDATE: signature is invalid", nil)
+		}
+		// 签名验证通过后记录Nonce，避免伪造的请求占用Nonce
+		claimed, err := f.Config.NonceStore.Claim(consumer.ConsumerId+":"+nonce, 2*f.clockSkew)
+		if nil != err {
+			ctx.Logger().Warnw("SIGNATURE:NONCE:ERROR", "key-id", keyId, "error", err)
+			return &flux.ServeError{
+				StatusCode: flux.StatusServerError,
+				ErrorCode:  flux.ErrorCodeGatewayInternal,
+				Message:    "SIGNATURE:NONCE: store error",
+				CauseError: err,
+			}
+		}
+		if !claimed {
+			ctx.Logger().Infow("SIGNATURE:VALIDATE:REPLAYED", "key-id", keyId, "nonce", nonce)
+			return newSignatureError(flux.ErrorCodeSignatureReplayed, "SIGNATURE:VALIDATE: nonce has been used", nil)
+		}
+		endpoint := ctx.Endpoint()
+		if !consumer.AllowApplication(ctx.Application()) || !consumer.AllowEndpoint(endpoint.HttpMethod, endpoint.HttpPattern) {
+			ctx.Logger().Infow("SIGNATURE:CONSUMER:DENIED", "consumer-id", consumer.ConsumerId)
+			return newAPIKeyError(http.StatusForbidden, flux.ErrorCodeConsumerDenied, "SIGNATURE:CONSUMER: access denied")
+		}
+		setConsumerAttributes(ctx, f.Config.AttKeyPrefix, consumer)
+		ctx.AddMetric(f.FilterId(), time.Since(ctx.StartAt()))
+		return next(ctx)
+	}
+}
+
+// stringToSign 生成请求的签名内容；使用客户端请求的原始方法、路径和Query，不受请求重写的影响；
+// Body通过可重复读取的BodyReader读取，不影响后续的参数解析
+func (f *SignatureFilter) stringToSign(ctx *flux.Context, timestamp, nonce string) (string, error) {
+	path, query := ctx.URL().EscapedPath(), ctx.URL().RawQuery
+	if uri, err := url.ParseRequestURI(ctx.URI()); nil == err {
+		path, query = uri.EscapedPath(), uri.RawQuery
+	}
+	values, err := url.ParseQuery(query)
+	if nil != err {
+		return "", fmt.Errorf("signature: parse query, error: %w", err)
+	}
+	reader, err := ctx.BodyReader()
+	if nil != err {
+		return "", fmt.Errorf("signature: read body, error: %w", err)
+	}
+	body, err := ioutil.ReadAll(reader)
+	_ = reader.Close()
+	if nil != err {
+		return "", fmt.Errorf("signature: read body, error: %w", err)
+	}
+	sum := sha256.Sum256(body)
+	return SignatureString(timestamp, nonce, ctx.OriginalMethod(), path, values, hex.EncodeToString(sum[:])), nil
+}
+
+// verify 使用Consumer的任一密钥验证签名；签名支持Hex或Base64编码
+func (f *SignatureFilter) verify(content, signature string, secrets []string) bool {
+	provided := decodeSignature(signature, f.algorithm().Size())
+	if provided == nil {
+		return false
+	}
+	for _, secret := range secrets {
+		mac := hmac.New(f.algorithm, []byte(secret))
+		mac.Write([]byte(content))
+		if hmac.Equal(mac.Sum(nil), provided) {
+			return true
+		}
+	}
+	return false
+}
+
+// SignatureString 生成请求签名内容；Query按Key排序，同名参数按值排序
+func SignatureString(timestamp, nonce, method, path string, query url.Values, bodyHash string) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			pairs = append(pairs, url.QueryEscape(k)+"="+url.QueryEscape(v))
+		}
+	}
+	return strings.Join([]string{timestamp, nonce, strings.ToUpper(method), path, strings.Join(pairs, "&"), bodyHash}, "\n")
+}
+
+func decodeSignature(signature string, size int) []byte {
+	if len(signature) == 2*size {
+		if data, err := hex.DecodeString(signature); nil == err {
+			return data
+		}
+	}
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.RawURLEncoding} {
+		if data, err := encoding.DecodeString(signature); nil == err && len(data) == size {
+			return data
+		}
+	}
+	return nil
+}
+
+func newSignatureError(code, message string, cause error) *flux.ServeError {
+	return &flux.ServeError{
+		StatusCode: http.StatusUnauthorized,
+		ErrorCode:  code,
+		Message:    message,
+		CauseError: cause,
+	}
+}
@@ -0,0 +1,130 @@
+package fluxext
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/bytepowered/flux/flux-node"
+	assert2 "github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const (
+	testSignSecret = "s3cr3t"
+	testSignURI    = "/api/orders?b=2&a=1&a=0&c=x+y"
+	testSignBody   = `{"id":1}`
+)
+
+func newSignatureContext(method string, headers map[string]string) *flux.Context {
+	request, _ := http.NewRequest(method, "http://gw.com"+testSignURI, bytes.NewBufferString(testSignBody))
+	request.RequestURI = testSignURI
+	for k, v := range headers {
+		request.Header.Set(k, v)
+	}
+	ctx := newTestContext(flux.Endpoint{
+		HttpMethod: method, HttpPattern: "/api/orders",
+		EmbeddedAttributes: flux.EmbeddedAttributes{
+			Attributes: []flux.Attribute{{Name: flux.EndpointAttrTagSignature, Value: true}},
+		},
+	}, nil)
+	*ctx.Request() = *request
+	return ctx
+}
+
+func signTestRequest(method string, timestamp int64, nonce string) map[string]string {
+	ts := strconv.FormatInt(timestamp, 10)
+	query, _ := url.ParseQuery("b=2&a=1&a=0&c=x+y")
+	sum := sha256.Sum256([]byte(testSignBody))
+	mac := hmac.New(sha256.New, []byte(testSignSecret))
+	mac.Write([]byte(SignatureString(ts, nonce, method, "/api/orders", query, hex.EncodeToString(sum[:]))))
+	return map[string]string{
+		"X-Sign-Key":       "app1",
+		"X-Sign-Timestamp": ts,
+		"X-Sign-Nonce":     nonce,
+		"X-Sign-Signature": hex.EncodeToString(mac.Sum(nil)),
+	}
+}
+
+func testSignatureSecret(_ *flux.Context, keyId string) (flux.Consumer, bool) {
+	return flux.Consumer{ConsumerId: keyId, Name: "App", Secrets: []string{"old", testSignSecret}}, keyId == "app1"
+}
+
+func TestSignatureString(t *testing.T) {
+	query, _ := url.ParseQuery("b=2&a=1&a=0&c=x+y&d=%2F")
+	content := SignatureString("1600000000", "n1", "post", "/api/orders", query, "hash")
+	assert2.Equal(t, "1600000000\nn1\nPOST\n/api/orders\na=0&a=1&b=2&c=x+y&d=%2F\nhash", content)
+	assert2.Equal(t, "1\nn\nGET\n/\n\n", SignatureString("1", "n", "get", "/", url.Values{}, ""))
+}
+
+func TestSignatureFilter(t *testing.T) {
+	assert := assert2.New(t)
+	filter := NewSignatureFilter(SignatureConfig{SecretFunc: testSignatureSecret})
+	initTestFilter(t, filter, "signature.test", map[string]interface{}{ConfigKeySignClockSkew: "1m"})
+	var body []byte
+	invoke := func(ctx *flux.Context) *flux.ServeError {
+		return filter.DoFilter(func(ctx *flux.Context) *flux.ServeError {
+			// 签名验证后，Body仍可读取
+			reader, err := ctx.BodyReader()
+			assert.NoError(err)
+			body, _ = ioutil.ReadAll(reader)
+			return nil
+		})(ctx)
+	}
+	now := time.Now().Unix()
+	ctx := newSignatureContext(http.MethodPost, signTestRequest(http.MethodPost, now, "n1"))
+	assert.Nil(invoke(ctx))
+	assert.Equal(testSignBody, string(body))
+	id, _ := ctx.GetAttribute("consumer.id")
+	assert.Equal("app1", id)
+	// Nonce重放
+	serr := invoke(newSignatureContext(http.MethodPost, signTestRequest(http.MethodPost, now, "n1")))
+	if assert.NotNil(serr) {
+		assert.Equal(flux.ErrorCodeSignatureReplayed, serr.ErrorCode)
+	}
+	// 超出时钟偏差
+	for _, ts := range []int64{now - 120, now + 120} {
+		serr = invoke(newSignatureContext(http.MethodPost, signTestRequest(http.MethodPost, ts, "n-"+strconv.FormatInt(ts, 10))))
+		if assert.NotNil(serr) {
+			assert.Equal(flux.ErrorCodeSignatureExpired, serr.ErrorCode)
+		}
+	}
+	// 签名不匹配时，不占用Nonce
+	headers := signTestRequest(http.MethodPost, now, "n2")
+	headers["X-Sign-Signature"] = hex.EncodeToString(make([]byte, sha256.Size))
+	serr = invoke(newSignatureContext(http.MethodPost, headers))
+	if assert.NotNil(serr) {
+		assert.Equal(flux.ErrorCodeSignatureInvalid, serr.ErrorCode)
+	}
+	assert.Nil(invoke(newSignatureContext(http.MethodPost, signTestRequest(http.MethodPost, now, "n2"))))
+	// 缺少签名Header
+	serr = invoke(newSignatureContext(http.MethodPost, map[string]string{}))
+	if assert.NotNil(serr) {
+		assert.Equal(flux.ErrorCodeSignatureNotFound, serr.ErrorCode)
+	}
+}
+
+func TestSignatureFilterRewrittenMethod(t *testing.T) {
+	assert := assert2.New(t)
+	filter := NewSignatureFilter(SignatureConfig{SecretFunc: testSignatureSecret})
+	initTestFilter(t, filter, "signature.rewrite", map[string]interface{}{ConfigKeySignClockSkew: "1m"})
+	// 客户端按原始方法签名，请求方法被重写后仍验证通过
+	ctx := newSignatureContext(http.MethodPost, signTestRequest(http.MethodPost, time.Now().Unix(), "rw1"))
+	ctx.Rewrite(http.MethodPut, "")
+	ctx.Rewrite(http.MethodPatch, "")
+	assert.Equal(http.MethodPatch, ctx.Method())
+	assert.Equal(http.MethodPost, ctx.OriginalMethod())
+	assert.Nil(invokeTestFilter(filter, ctx))
+	// 按重写后的方法签名，验证失败
+	ctx = newSignatureContext(http.MethodPost, signTestRequest(http.MethodPut, time.Now().Unix(), "rw2"))
+	ctx.Rewrite(http.MethodPut, "")
+	serr := invokeTestFilter(filter, ctx)
+	if assert.NotNil(serr) {
+		assert.Equal(flux.ErrorCodeSignatureInvalid, serr.ErrorCode)
+	}
+}
@@ -0,0 +1,32 @@
+package fluxinspect
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+)
+
+const (
+	cftQueryKeyKind   = "kind"
+	cftQueryKeyKey    = "key"
+	cftQueryKeySource = "source"
+)
+
+func DoQueryConflicts(args func(key string) string) []flux.DiscoveryConflict {
+	kind, key, source := args(cftQueryKeyKind), args(cftQueryKeyKey), args(cftQueryKeySource)
+	out := make([]flux.DiscoveryConflict, 0, 8)
+	for _, c := range ext.DiscoveryConflicts() {
+		if (kind == "" || queryMatch(kind, c.Kind)) &&
+			(key == "" || queryMatch(key, c.Key)) &&
+			(source == "" || queryMatch(source, c.Source) || queryMatch(source, c.Owner)) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func ConflictsHandler(webex flux.ServerWebContext) error {
+	conflicts := DoQueryConflicts(func(key string) string {
+		return webex.QueryVar(key)
+	})
+	return send(webex, flux.StatusOK, conflicts)
+}
@@ -0,0 +1,79 @@
+package common
+
+import (
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"net"
+	"strings"
+)
+
+// ParseIPNets 解析IP或CIDR列表；单个IP地址转换为单主机网段
+func ParseIPNets(values []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(values))
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value == "" {
+			continue
+		}
+		if strings.Contains(value, "/") {
+			_, ipnet, err := net.ParseCIDR(value)
+			if nil != err {
+				return nil, fmt.Errorf("invalid cidr: %s, error: %w", value, err)
+			}
+			nets = append(nets, ipnet)
+			continue
+		}
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid ip: %s", value)
+		}
+		if v4 := ip.To4(); v4 != nil {
+			nets = append(nets, &net.IPNet{IP: v4, Mask: net.CIDRMask(32, 32)})
+		} else {
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)})
+		}
+	}
+	return nets, nil
+}
+
+// ContainsIP 判断IP地址是否属于网段列表
+func ContainsIP(nets []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, ipnet := range nets {
+		if ipnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP 返回请求客户端的IP地址；仅当直连地址属于可信代理网段时，才读取X-Forwarded-For和X-Real-IP：
+// 从右向左跳过可信代理地址，返回第一个不可信的地址；全部为可信代理时，返回最左侧的地址；
+// 遇到无法解析的地址时，不再信任左侧的地址，返回最后一个可信代理地址(没有时返回直连地址)。
+func ClientIP(webex flux.ServerWebContext, trusted []*net.IPNet) string {
+	remote := RemoteIP(webex)
+	if len(trusted) == 0 || !ContainsIP(trusted, net.ParseIP(remote)) {
+		return remote
+	}
+	if xff := webex.HeaderVars().Values(flux.HeaderXForwardedFor); len(xff) > 0 {
+		hops := strings.Split(strings.Join(xff, ","), ",")
+		client := remote
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			ip := net.ParseIP(hop)
+			if ip == nil {
+				break
+			}
+			client = hop
+			if !ContainsIP(trusted, ip) {
+				return hop
+			}
+		}
+		return client
+	}
+	if realIP := strings.TrimSpace(webex.HeaderVar(flux.HeaderXRealIP)); net.ParseIP(realIP) != nil {
+		return realIP
+	}
+	return remote
+}
@@ -0,0 +1,42 @@
+package common
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	assert := assert.New(t)
+	trusted, err := ParseIPNets([]string{"10.0.0.0/8", "192.168.1.1", "fd00::/8"})
+	assert.NoError(err)
+	cases := []struct {
+		remote, xff, realIP, expected string
+	}{
+		// 直连地址不可信，忽略转发Header
+		{"1.2.3.4:5000", "9.9.9.9", "", "1.2.3.4"},
+		{"10.1.1.1:5000", "", "", "10.1.1.1"},
+		{"10.1.1.1:5000", "9.9.9.9, 10.2.2.2", "", "9.9.9.9"},
+		// 客户端伪造的最左侧地址被跳过
+		{"10.1.1.1:5000", "6.6.6.6, 9.9.9.9, 192.168.1.1", "", "9.9.9.9"},
+		{"10.1.1.1:5000", "10.3.3.3, 10.2.2.2", "", "10.3.3.3"},
+		{"10.1.1.1:5000", "", "8.8.8.8", "8.8.8.8"},
+		{"[fd00::1]:5000", "2001:db8::1", "", "2001:db8::1"},
+		// 无法解析的地址：返回最后一个可信代理地址，不读取X-Real-IP
+		{"10.1.1.1:5000", "9.9.9.9:8080", "6.6.6.6", "10.1.1.1"},
+		{"10.1.1.1:5000", "unknown, 10.2.2.2", "6.6.6.6", "10.2.2.2"},
+	}
+	for _, c := range cases {
+		webex := MockWebContext("ip")
+		webex.Request().RemoteAddr = c.remote
+		if c.xff != "" {
+			webex.Request().Header.Set(flux.HeaderXForwardedFor, c.xff)
+		}
+		if c.realIP != "" {
+			webex.Request().Header.Set(flux.HeaderXRealIP, c.realIP)
+		}
+		assert.Equal(c.expected, ClientIP(webex, trusted), c.xff)
+	}
+	_, err = ParseIPNets([]string{"10.0.0.0/33"})
+	assert.Error(err)
+}
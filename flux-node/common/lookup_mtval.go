@@ -3,6 +3,7 @@ package common
 import (
 	"errors"
 	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
 	"github.com/bytepowered/flux/flux-pkg"
 	"net/http"
 	"net/textproto"
@@ -55,6 +56,11 @@ func LookupMTValue(scope, key string, ctx *flux.Context) (value flux.MTValue, er
 		return lookupValues(ctx.HeaderVars(), key), nil
 	case flux.ScopeHeaderMap:
 		return flux.WrapStrValuesMapMTValue(ctx.HeaderVars()), nil
+	case flux.ScopeCookie:
+		if cookie, err := ctx.CookieVar(key); nil == err {
+			return flux.WrapStringMTValue(cookie.Value), nil
+		}
+		return flux.NewInvalidMTValue(), nil
 	case flux.ScopeAttr:
 		v, _ := ctx.GetAttribute(key)
 		return flux.WrapObjectMTValue(v), nil
@@ -72,9 +78,30 @@ func LookupMTValue(scope, key string, ctx *flux.Context) (value flux.MTValue, er
 			return flux.WrapStringMTValue(ctx.Method()), nil
 		case "uri":
 			return flux.WrapStringMTValue(ctx.URI()), nil
+		case "ip":
+			return flux.WrapStringMTValue(RemoteIP(ctx)), nil
+		case "endpoint":
+			return flux.WrapStringMTValue(ext.MakeEndpointKey(ctx.Endpoint().HttpMethod, ctx.Endpoint().HttpPattern)), nil
 		default:
 			return flux.NewInvalidMTValue(), nil
 		}
+	case flux.ScopeTLS:
+		peer, ok := ctx.TLSPeer()
+		if !ok {
+			return flux.NewInvalidMTValue(), nil
+		}
+		value, ok := peer.Lookup(strings.ToLower(key))
+		if !ok {
+			return flux.NewInvalidMTValue(), nil
+		}
+		switch v := value.(type) {
+		case string:
+			return flux.WrapStringMTValue(v), nil
+		case []string:
+			return flux.WrapStrListMTValue(v), nil
+		default:
+			return flux.WrapObjectMTValue(v), nil
+		}
 	case flux.ScopeAuto:
 		fallthrough
 	default:
@@ -4,6 +4,7 @@ import (
 	"github.com/bytepowered/flux/flux-node"
 	"github.com/bytepowered/flux/flux-pkg"
 	"github.com/spf13/cast"
+	"net"
 	"strings"
 )
 
@@ -29,17 +30,34 @@ func LookupWebValue(webex flux.ServerWebContext, scope, key string) string {
 		return webex.FormVar(key)
 	case flux.ScopeHeader:
 		return webex.HeaderVar(key)
+	case flux.ScopeCookie:
+		if cookie, err := webex.CookieVar(key); nil == err {
+			return cookie.Value
+		}
+		return ""
 	case flux.ScopeRequest:
 		switch strings.ToLower(key) {
 		case "method":
 			return webex.Method()
 		case "uri":
 			return webex.URI()
+		case "ip":
+			return RemoteIP(webex)
 		}
 		return webex.Method()
 	case flux.ScopeParam:
 		v, _ := fluxpkg.LookupByProviders(key, webex.QueryVars, webex.FormVars)
 		return v
+	case flux.ScopeTLS:
+		peer, ok := flux.NewTLSPeer(webex.Request().TLS)
+		if !ok {
+			return ""
+		}
+		value, _ := peer.Lookup(strings.ToLower(key))
+		if values, ok := value.([]string); ok {
+			return strings.Join(values, ",")
+		}
+		return cast.ToString(value)
 	case flux.ScopeAuto:
 		// Post args
 		if v, ok := fluxpkg.LookupByProviders(key, webex.PathVars, webex.QueryVars, webex.FormVars); ok {
@@ -55,3 +73,12 @@ func LookupWebValue(webex flux.ServerWebContext, scope, key string) string {
 		return ""
 	}
 }
+
+// RemoteIP 返回请求客户端的IP地址，不包含端口
+func RemoteIP(webex flux.ServerWebContext) string {
+	addr := webex.RemoteAddr()
+	if host, _, err := net.SplitHostPort(addr); nil == err {
+		return host
+	}
+	return addr
+}
@@ -14,6 +14,7 @@ const (
 	NamespaceWebListeners = "listeners"
 	NamespaceTransporters = "transporters"
 	NamespaceDiscoveries  = "discoveries"
+	NamespaceShutdown     = "shutdown"
 )
 
 func MakeConfigurationKey(keys ...string) string {
@@ -10,6 +10,8 @@ const (
 	XRequestTime  = "X-Request-Time"
 	XRequestHost  = "X-Request-Host"
 	XRequestAgent = "X-Request-Agent"
+	// 请求重写前的原始请求方法；请求方法被重写时记录
+	XOriginalMethod = "X-Original-Method"
 )
 
 // Context 定义每个请求的上下文环境
@@ -20,6 +22,7 @@ type Context struct {
 	metrics    []Metric
 	startTime  time.Time
 	ctxLogger  Logger
+	response   *ResponseBody
 }
 
 func NewContext() *Context {
@@ -35,6 +38,7 @@ func (c *Context) Reset(webex ServerWebContext, endpoint *Endpoint) {
 	c.ctxLogger = zap.S()
 	c.startTime = time.Now()
 	c.metrics = c.metrics[:0]
+	c.response = nil
 	for k := range c.attributes {
 		delete(c.attributes, k)
 	}
@@ -50,6 +54,21 @@ func (c *Context) Endpoint() *Endpoint {
 	return c.endpoint
 }
 
+// OriginalMethod 返回客户端请求的原始方法，不受请求重写的影响
+func (c *Context) OriginalMethod() string {
+	if v, ok := c.GetVariable(XOriginalMethod); ok {
+		if method, ok := v.(string); ok {
+			return method
+		}
+	}
+	return c.Method()
+}
+
+// TLSPeer 返回客户端的TLS证书信息；非TLS请求或客户端未提供证书时返回false
+func (c *Context) TLSPeer() (TLSPeer, bool) {
+	return NewTLSPeer(c.Request().TLS)
+}
+
 // Service 返回Service信息
 func (c *Context) Service() Service {
 	return c.endpoint.Service
@@ -103,6 +122,16 @@ func (c *Context) SetAttribute(key string, value interface{}) {
 	c.attributes[key] = value
 }
 
+// SetResponse 设置后端服务的响应结果
+func (c *Context) SetResponse(response *ResponseBody) {
+	c.response = response
+}
+
+// Response 返回后端服务的响应结果；请求未完成或后端服务返回错误时，返回nil
+func (c *Context) Response() *ResponseBody {
+	return c.response
+}
+
 // StartAt 返回Http请求起始的服务器时间
 func (c *Context) StartAt() time.Time {
 	return c.startTime
@@ -14,3 +14,32 @@ type EndpointDiscovery interface {
 	// WatchServices 监听TransporterService注册事件
 	WatchServices(ctx context.Context, events chan<- ServiceEvent) error
 }
+
+// 多注册中心数据冲突时的合并策略
+const (
+	// MergePolicyOverride 优先级不低于当前数据时覆盖(相同优先级时最后写入者生效)，否则暂不生效；高优先级数据删除后恢复
+	MergePolicyOverride = "override"
+	// MergePolicyReject 数据已由其它注册中心先定义时，拒绝；先定义的数据全部删除后生效
+	MergePolicyReject = "reject"
+	// MergePolicyShadow 按优先级遮蔽其它注册中心的数据；高优先级数据删除后，恢复低优先级数据
+	MergePolicyShadow = "shadow"
+)
+
+// DiscoveryConflict 多注册中心定义相同Endpoint/Service时的冲突记录
+type DiscoveryConflict struct {
+	Kind           string `json:"kind"`           // 冲突数据类型：endpoint/service
+	Key            string `json:"key"`            // 冲突数据的标识
+	Source         string `json:"source"`         // 发送事件的注册中心
+	SourcePriority int    `json:"sourcePriority"` // 发送事件的注册中心优先级
+	Owner          string `json:"owner"`          // 当前生效数据所属的注册中心
+	OwnerPriority  int    `json:"ownerPriority"`  // 当前生效数据所属的注册中心优先级
+	Policy         string `json:"policy"`         // 发送事件的注册中心的合并策略
+	Resolution     string `json:"resolution"`     // 冲突处理结果
+	Timestamp      int64  `json:"timestamp"`      // 冲突发生时间，Unix毫秒
+}
+
+// ConsumerDiscovery 可选实现的Consumer注册元数据事件监听
+type ConsumerDiscovery interface {
+	// WatchConsumers 监听Consumer注册事件
+	WatchConsumers(ctx context.Context, events chan<- ConsumerEvent) error
+}
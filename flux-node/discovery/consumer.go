@@ -0,0 +1,44 @@
+package discovery
+
+import (
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/remoting"
+	"strings"
+)
+
+var (
+	emptyConsumerEvent = flux.ConsumerEvent{}
+)
+
+func NewConsumerEvent(bytes []byte, etype remoting.EventType) (fxEvt flux.ConsumerEvent, err error) {
+	// Check json text
+	size := len(bytes)
+	if size < len("{\"k\":0}") {
+		return emptyConsumerEvent, fmt.Errorf("ILLEGAL_JSONSIZE: %d", size)
+	}
+	if prefix := strings.TrimSpace(string(bytes[:5])); prefix[0] != '{' {
+		return emptyConsumerEvent, fmt.Errorf("ILLEGAL_JSONDATA: %s", string(bytes))
+	}
+	consumer := flux.Consumer{}
+	if err := ext.JSONUnmarshal(bytes, &consumer); nil != err {
+		return emptyConsumerEvent, fmt.Errorf("ILLEGAL_JSONFORMAT: err: %w", err)
+	}
+	// 检查有效性
+	if !consumer.IsValid() {
+		return emptyConsumerEvent, fmt.Errorf("INVALID_VALUES: consumer-id=%s", consumer.ConsumerId)
+	}
+	event := flux.ConsumerEvent{Consumer: consumer}
+	switch etype {
+	case remoting.EventTypeNodeAdd:
+		event.EventType = flux.EventTypeAdded
+	case remoting.EventTypeNodeDelete:
+		event.EventType = flux.EventTypeRemoved
+	case remoting.EventTypeNodeUpdate:
+		event.EventType = flux.EventTypeUpdated
+	default:
+		return emptyConsumerEvent, fmt.Errorf("UNKNOWN_EVT_TYPE: type=%d", etype)
+	}
+	return event, nil
+}
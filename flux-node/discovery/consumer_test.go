@@ -0,0 +1,33 @@
+package discovery
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/remoting"
+	assert2 "github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNewConsumerEvent(t *testing.T) {
+	ext.RegisterSerializer(ext.TypeNameSerializerJson, flux.NewJsonSerializer())
+	text := `{
+    "consumerId": "partner-a",
+    "name": "Partner A",
+    "keyHashes": ["` + ext.HashConsumerKey("secret-key") + `"],
+    "applications": ["myapp"],
+    "endpoints": ["GET#/api/orders"],
+    "metadata": {"tier": "gold"}
+}`
+	evt, err := NewConsumerEvent([]byte(text), remoting.EventTypeNodeAdd)
+	assert := assert2.New(t)
+	assert.NoError(err)
+	assert.Equal(flux.EventType(flux.EventTypeAdded), evt.EventType)
+	assert.Equal("partner-a", evt.Consumer.ConsumerId)
+	assert.Equal("gold", evt.Consumer.Metadata["tier"])
+	assert.True(evt.Consumer.AllowApplication("myapp"))
+	assert.True(evt.Consumer.AllowEndpoint("get", "/api/orders"))
+	assert.False(evt.Consumer.AllowEndpoint("POST", "/api/orders"))
+	// 缺少Key哈希值
+	_, err = NewConsumerEvent([]byte(`{"consumerId": "partner-b"}`), remoting.EventTypeNodeAdd)
+	assert.Error(err)
+}
@@ -0,0 +1,554 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/bytepowered/flux/flux-node/remoting/zk"
+	"github.com/spf13/cast"
+	"net/http"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DubboMetadataId = "dubbo-metadata"
+)
+
+const (
+	// Dubbo元数据中心在ZK注册的根节点
+	dubboMetadataRootpath = "/dubbo/metadata"
+	// 默认的Endpoint路由规则
+	dubboMetadataPattern = "/{application}/{interface}/{method}"
+)
+
+const (
+	dubboConfigRootpath        = "rootpath"
+	dubboConfigRefreshInterval = "refresh_interval"
+	dubboConfigHttpPattern     = "http_pattern"
+	dubboConfigHttpMethod      = "http_method"
+	dubboConfigIncludes        = "includes"
+	dubboConfigExcludes        = "excludes"
+	dubboConfigAttributes      = "attributes"
+)
+
+var _ flux.EndpointDiscovery = new(DubboMetadataDiscoveryService)
+
+type (
+	// DubboMetadataOption 配置函数
+	DubboMetadataOption func(discovery *DubboMetadataDiscoveryService)
+)
+
+// DubboServiceDefinition Dubbo元数据中心发布的服务定义
+type DubboServiceDefinition struct {
+	CanonicalName string                  `json:"canonicalName"`
+	CodeSource    string                  `json:"codeSource"`
+	Methods       []DubboMethodDefinition `json:"methods"`
+	Types         []DubboTypeDefinition   `json:"types"`
+	Parameters    map[string]string       `json:"parameters"`
+}
+
+// DubboMethodDefinition Dubbo服务方法定义
+type DubboMethodDefinition struct {
+	Name           string   `json:"name"`
+	ParameterTypes []string `json:"parameterTypes"`
+	ReturnType     string   `json:"returnType"`
+}
+
+// DubboTypeDefinition Dubbo参数类型定义；Properties的值为类型名，或者类型定义对象
+type DubboTypeDefinition struct {
+	Type       string                 `json:"type"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// DubboMetadataDiscoveryService 读取Dubbo元数据中心的服务定义，按路由规则自动生成Endpoint
+type DubboMetadataDiscoveryService struct {
+	id          string
+	globalAlias map[string]string
+	disabled    bool
+	rootpath    string
+	refresh     time.Duration
+	pattern     string
+	httpMethod  string
+	includes    []string
+	excludes    []string
+	attributes  []flux.Attribute
+	retrievers  []*zk.ZookeeperRetriever
+	// Endpoint与Service事件由同一个刷新循环发送
+	watchMu        sync.Mutex
+	watching       bool
+	endpointEvents chan<- flux.EndpointEvent
+	serviceEvents  chan<- flux.ServiceEvent
+}
+
+// WithDubboMetadataAlias 配置注册中心的配置别名
+func WithDubboMetadataAlias(alias map[string]string) DubboMetadataOption {
+	return func(discovery *DubboMetadataDiscoveryService) {
+		discovery.globalAlias = alias
+	}
+}
+
+// NewDubboMetadataServiceWith returns new a dubbo metadata discovery service
+func NewDubboMetadataServiceWith(id string, opts ...DubboMetadataOption) *DubboMetadataDiscoveryService {
+	r := &DubboMetadataDiscoveryService{
+		id: id,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+func (r *DubboMetadataDiscoveryService) Id() string {
+	return r.id
+}
+
+// Init init discovery；未配置注册中心时，不启用。
+func (r *DubboMetadataDiscoveryService) Init(config *flux.Configuration) error {
+	config.SetDefaults(map[string]interface{}{
+		dubboConfigRootpath:        dubboMetadataRootpath,
+		dubboConfigRefreshInterval: 30 * time.Second,
+		dubboConfigHttpPattern:     dubboMetadataPattern,
+		dubboConfigHttpMethod:      http.MethodPost,
+	})
+	if config.GetBool("disable") || !config.IsSet("registry_centers") {
+		logger.Infow("DubboMetadataDiscovery disabled", "discovery-id", r.id)
+		r.disabled = true
+		return nil
+	}
+	r.rootpath = config.GetString(dubboConfigRootpath)
+	r.refresh = config.GetDuration(dubboConfigRefreshInterval)
+	r.pattern = config.GetString(dubboConfigHttpPattern)
+	r.httpMethod = strings.ToUpper(config.GetString(dubboConfigHttpMethod))
+	r.includes = config.GetStringSlice(dubboConfigIncludes)
+	r.excludes = config.GetStringSlice(dubboConfigExcludes)
+	attrs := config.GetStringMap(dubboConfigAttributes)
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		r.attributes = append(r.attributes, flux.Attribute{Name: name, Value: attrs[name]})
+	}
+	if r.rootpath == "" || r.refresh <= 0 {
+		return fmt.Errorf("config(rootpath, refresh_interval) is invalid, rootpath: %s, refresh: %s", r.rootpath, r.refresh)
+	}
+	retrievers, err := newZookeeperRetrievers(config, r.globalAlias)
+	if nil != err {
+		return err
+	}
+	r.retrievers = retrievers
+	logger.Infow("DubboMetadataDiscovery init", "discovery-id", r.id, "rootpath", r.rootpath, "http-pattern", r.pattern,
+		"includes", r.includes, "excludes", r.excludes)
+	return nil
+}
+
+// WatchEndpoints 周期性读取元数据，发送生成的Endpoint变更事件；
+// 与WatchServices共用刷新循环，两者均开始监听后启动。
+func (r *DubboMetadataDiscoveryService) WatchEndpoints(ctx context.Context, events chan<- flux.EndpointEvent) error {
+	if r.disabled {
+		return nil
+	}
+	r.watchMu.Lock()
+	defer r.watchMu.Unlock()
+	r.endpointEvents = events
+	r.startWatch(ctx)
+	return nil
+}
+
+// WatchServices 周期性读取元数据，发送生成的Service变更事件；
+// 与WatchEndpoints共用刷新循环，两者均开始监听后启动。
+func (r *DubboMetadataDiscoveryService) WatchServices(ctx context.Context, events chan<- flux.ServiceEvent) error {
+	if r.disabled {
+		return nil
+	}
+	r.watchMu.Lock()
+	defer r.watchMu.Unlock()
+	r.serviceEvents = events
+	r.startWatch(ctx)
+	return nil
+}
+
+func (r *DubboMetadataDiscoveryService) startWatch(ctx context.Context) {
+	if r.watching || r.endpointEvents == nil || r.serviceEvents == nil {
+		return
+	}
+	r.watching = true
+	go r.refreshLoop(ctx, r.emit)
+}
+
+// emit 发送Endpoint及其Service的变更事件：新增和更新时先发送Service事件，删除时先发送Endpoint事件
+func (r *DubboMetadataDiscoveryService) emit(evtType flux.EventType, ep flux.Endpoint) {
+	if evtType == flux.EventTypeRemoved {
+		r.endpointEvents <- flux.EndpointEvent{EventType: evtType, Endpoint: ep}
+		r.serviceEvents <- flux.ServiceEvent{EventType: evtType, Service: ep.Service}
+	} else {
+		r.serviceEvents <- flux.ServiceEvent{EventType: evtType, Service: ep.Service}
+		r.endpointEvents <- flux.EndpointEvent{EventType: evtType, Endpoint: ep}
+	}
+}
+
+func (r *DubboMetadataDiscoveryService) refreshLoop(ctx context.Context, emit func(flux.EventType, flux.Endpoint)) {
+	ticker := time.NewTicker(r.refresh)
+	defer ticker.Stop()
+	loaded := make(map[string]flux.Endpoint, 16)
+	for {
+		loaded = r.doRefresh(loaded, emit)
+		select {
+		case <-ctx.Done():
+			logger.Infow("DISCOVERY:DUBBO_METADATA:REFRESH:STOP", "rootpath", r.rootpath)
+			return
+		case <-ticker.C:
+			continue
+		}
+	}
+}
+
+// doRefresh 全量读取元数据，与上次生成的Endpoint比对并发送变更事件；读取失败时保留上次结果。
+func (r *DubboMetadataDiscoveryService) doRefresh(loaded map[string]flux.Endpoint, emit func(flux.EventType, flux.Endpoint)) map[string]flux.Endpoint {
+	const msg = "DISCOVERY:DUBBO_METADATA:REFRESH"
+	defer func() {
+		if rvr := recover(); nil != rvr {
+			logger.Errorw(msg+"/PANIC", "rootpath", r.rootpath, "error", rvr)
+		}
+	}()
+	latest := make(map[string]flux.Endpoint, len(loaded))
+	for _, retriever := range r.retrievers {
+		defines, err := r.scan(retriever)
+		if nil != err {
+			logger.Warnw(msg+"/ERROR", "retriever-id", retriever.Id, "rootpath", r.rootpath, "error", err)
+			return loaded
+		}
+		for _, def := range defines {
+			for _, ep := range r.NewEndpoints(def) {
+				key := ext.MakeEndpointKey(ep.HttpMethod, ep.HttpPattern) + "#" + ep.Version
+				if _, ok := latest[key]; ok {
+					logger.Warnw(msg+"/DUPLICATED", "endpoint-key", key, "interface", ep.Service.Interface)
+					continue
+				}
+				latest[key] = ep
+			}
+		}
+	}
+	for key, ep := range latest {
+		if old, ok := loaded[key]; !ok {
+			emit(flux.EventTypeAdded, ep)
+		} else if !reflect.DeepEqual(old, ep) {
+			emit(flux.EventTypeUpdated, ep)
+		}
+	}
+	for key, ep := range loaded {
+		if _, ok := latest[key]; !ok {
+			emit(flux.EventTypeRemoved, ep)
+		}
+	}
+	return latest
+}
+
+// scan 遍历元数据节点：{rootpath}/{interface}[/{version}][/{group}]/provider[/{application}]
+func (r *DubboMetadataDiscoveryService) scan(retriever *zk.ZookeeperRetriever) ([]DubboServiceDefinition, error) {
+	if ok, err := retriever.Exists(r.rootpath); nil != err || !ok {
+		return nil, err
+	}
+	nodes, err := retriever.Children(r.rootpath)
+	if nil != err {
+		return nil, err
+	}
+	out := make([]DubboServiceDefinition, 0, len(nodes))
+	for _, node := range nodes {
+		if !r.IsAllowed(path.Base(node), "") {
+			continue
+		}
+		if err := r.walk(retriever, node, 0, &out); nil != err {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func (r *DubboMetadataDiscoveryService) walk(retriever *zk.ZookeeperRetriever, node string, depth int, out *[]DubboServiceDefinition) error {
+	children, err := retriever.Children(node)
+	if nil != err {
+		return err
+	}
+	for _, child := range children {
+		switch name := path.Base(child); {
+		case name == "provider":
+			apps, err := retriever.Children(child)
+			if nil != err {
+				return err
+			}
+			if len(apps) == 0 {
+				apps = []string{child}
+			}
+			for _, app := range apps {
+				data, err := retriever.GetData(app)
+				if nil != err {
+					return err
+				}
+				if def, ok := r.decode(app, data); ok {
+					*out = append(*out, def)
+				}
+			}
+		case name == "consumer" || depth >= 2:
+			continue
+		default:
+			if err := r.walk(retriever, child, depth+1, out); nil != err {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *DubboMetadataDiscoveryService) decode(node string, data []byte) (DubboServiceDefinition, bool) {
+	def := DubboServiceDefinition{}
+	if len(data) == 0 {
+		return def, false
+	}
+	if err := ext.JSONUnmarshal(data, &def); nil != err {
+		logger.Warnw("DISCOVERY:DUBBO_METADATA:ILLEGAL_JSONFORMAT", "node", node, "data", string(data), "error", err)
+		return def, false
+	}
+	if def.Parameters == nil {
+		def.Parameters = make(map[string]string, 4)
+	}
+	if def.CanonicalName == "" {
+		def.CanonicalName = def.Parameters["interface"]
+	}
+	// 节点路径：.../provider/{application}
+	if def.Parameters["application"] == "" && path.Base(node) != "provider" {
+		def.Parameters["application"] = path.Base(node)
+	}
+	return def, def.CanonicalName != ""
+}
+
+// IsAllowed 判断接口/方法是否允许生成Endpoint；规则格式为：interface 或者 interface#method，支持通配符。
+// 黑名单优先；白名单为空时允许全部。检查接口时(method为空)，只匹配不限定方法的规则。
+func (r *DubboMetadataDiscoveryService) IsAllowed(iface, method string) bool {
+	match := func(rules []string, skipMethodRules bool) bool {
+		for _, rule := range rules {
+			target := iface
+			if strings.Contains(rule, "#") {
+				if skipMethodRules {
+					continue
+				}
+				target = iface + "#" + method
+			}
+			if ok, _ := path.Match(rule, target); ok {
+				return true
+			}
+		}
+		return false
+	}
+	if match(r.excludes, method == "") {
+		return false
+	}
+	if len(r.includes) == 0 {
+		return true
+	}
+	if method == "" {
+		// 接口检查阶段：存在方法规则时，由方法检查阶段判定
+		for _, rule := range r.includes {
+			if idx := strings.Index(rule, "#"); idx > 0 {
+				if ok, _ := path.Match(rule[:idx], iface); ok {
+					return true
+				}
+			}
+		}
+	}
+	return match(r.includes, false)
+}
+
+// NewEndpoints 根据服务定义生成Endpoint列表；重载方法只生成第一个。
+func (r *DubboMetadataDiscoveryService) NewEndpoints(def DubboServiceDefinition) []flux.Endpoint {
+	iface := def.CanonicalName
+	app := def.Parameters["application"]
+	version, group := def.Parameters["version"], def.Parameters["group"]
+	types := make(map[string]DubboTypeDefinition, len(def.Types))
+	for _, t := range def.Types {
+		types[t.Type] = t
+	}
+	out := make([]flux.Endpoint, 0, len(def.Methods))
+	generated := make(map[string]struct{}, len(def.Methods))
+	for _, method := range def.Methods {
+		if _, ok := generated[method.Name]; ok {
+			logger.Warnw("DISCOVERY:DUBBO_METADATA:OVERLOAD_METHOD", "interface", iface, "method", method.Name)
+			continue
+		}
+		if !r.IsAllowed(iface, method.Name) {
+			continue
+		}
+		generated[method.Name] = struct{}{}
+		service := flux.Service{
+			ServiceId: iface + ":" + method.Name,
+			Interface: iface,
+			Method:    method.Name,
+			Arguments: make([]flux.Argument, 0, len(method.ParameterTypes)),
+			EmbeddedAttributes: flux.EmbeddedAttributes{
+				Attributes: []flux.Attribute{
+					{Name: flux.ServiceAttrTagRpcProto, Value: flux.ProtoDubbo},
+					{Name: flux.ServiceAttrTagRpcGroup, Value: group},
+					{Name: flux.ServiceAttrTagRpcVersion, Value: version},
+				},
+			},
+		}
+		if group != "" || version != "" {
+			service.ServiceId += ":" + group + ":" + version
+		}
+		if timeout := def.Parameters["timeout"]; timeout != "" {
+			service.Attributes = append(service.Attributes, flux.Attribute{Name: flux.ServiceAttrTagRpcTimeout, Value: timeout + "ms"})
+		}
+		if retries := def.Parameters["retries"]; retries != "" {
+			service.Attributes = append(service.Attributes, flux.Attribute{Name: flux.ServiceAttrTagRpcRetries, Value: retries})
+		}
+		for i, ptype := range method.ParameterTypes {
+			service.Arguments = append(service.Arguments, newDubboArgument(fmt.Sprintf("arg%d", i), ptype, types))
+		}
+		attrs := make([]flux.Attribute, len(r.attributes))
+		copy(attrs, r.attributes)
+		out = append(out, flux.Endpoint{
+			Application: app,
+			Version:     version,
+			HttpPattern: r.newPattern(app, iface, method.Name, version, group),
+			HttpMethod:  r.httpMethod,
+			Service:     service,
+			EmbeddedAttributes: flux.EmbeddedAttributes{
+				Attributes: attrs,
+			},
+		})
+	}
+	return out
+}
+
+func (r *DubboMetadataDiscoveryService) newPattern(app, iface, method, version, group string) string {
+	pattern := strings.NewReplacer(
+		"{app}", app, "{application}", app,
+		"{interface}", iface, "{method}", method,
+		"{version}", version, "{group}", group,
+	).Replace(r.pattern)
+	for strings.Contains(pattern, "//") {
+		pattern = strings.ReplaceAll(pattern, "//", "/")
+	}
+	return pattern
+}
+
+// newDubboArgument 按Java类型生成参数；泛型类型 List<T>/Map<K,V> 拆分为Class和Generic；
+// POJO类型按元数据中的属性定义生成字段。
+func newDubboArgument(name, javaType string, types map[string]DubboTypeDefinition) flux.Argument {
+	class, generic := splitJavaGenericType(javaType)
+	arg := flux.Argument{
+		Name:      name,
+		Type:      flux.ArgumentTypePrimitive,
+		Class:     class,
+		Generic:   generic,
+		HttpName:  name,
+		HttpScope: flux.ScopeAuto,
+	}
+	if isJavaValueType(class) {
+		return arg
+	}
+	arg.Type = flux.ArgumentTypeComplex
+	def, ok := types[javaType]
+	if !ok {
+		def = types[class]
+	}
+	fields := make([]string, 0, len(def.Properties))
+	for field := range def.Properties {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		ftype := cast.ToString(def.Properties[field])
+		if m, ok := def.Properties[field].(map[string]interface{}); ok {
+			ftype = cast.ToString(m["type"])
+		}
+		if ftype == "" {
+			continue
+		}
+		// 只展开一层字段
+		fclass, fgeneric := splitJavaGenericType(ftype)
+		ftype = flux.ArgumentTypePrimitive
+		if !isJavaValueType(fclass) {
+			ftype = flux.ArgumentTypeComplex
+		}
+		arg.Fields = append(arg.Fields, flux.Argument{
+			Name:      field,
+			Type:      ftype,
+			Class:     fclass,
+			Generic:   fgeneric,
+			HttpName:  field,
+			HttpScope: flux.ScopeAuto,
+		})
+	}
+	return arg
+}
+
+func splitJavaGenericType(javaType string) (string, []string) {
+	javaType = strings.TrimSpace(javaType)
+	idx := strings.Index(javaType, "<")
+	if idx < 0 || !strings.HasSuffix(javaType, ">") {
+		return javaType, nil
+	}
+	inner := javaType[idx+1 : len(javaType)-1]
+	generic := make([]string, 0, 2)
+	depth, start := 0, 0
+	for i, c := range inner {
+		switch c {
+		case '<':
+			depth++
+		case '>':
+			depth--
+		case ',':
+			if depth == 0 {
+				generic = append(generic, strings.TrimSpace(inner[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	generic = append(generic, strings.TrimSpace(inner[start:]))
+	return javaType[:idx], generic
+}
+
+func isJavaValueType(class string) bool {
+	switch class {
+	case "int", "long", "short", "byte", "char", "float", "double", "boolean":
+		return true
+	}
+	if strings.HasSuffix(class, "[]") {
+		return true
+	}
+	for _, pkg := range []string{"java.lang.", "java.util.", "java.math.", "java.time."} {
+		if strings.HasPrefix(class, pkg) {
+			return true
+		}
+	}
+	return false
+}
+
+// Startup startup discovery service
+func (r *DubboMetadataDiscoveryService) Startup() error {
+	for _, retriever := range r.retrievers {
+		if err := retriever.Startup(); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+// Shutdown shutdown discovery service
+func (r *DubboMetadataDiscoveryService) Shutdown(ctx context.Context) error {
+	for _, retriever := range r.retrievers {
+		if err := retriever.Shutdown(ctx); nil != err {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package discovery
+
+import (
+	"context"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	assert2 "github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestDubboMetadataNewEndpoints(t *testing.T) {
+	text := `{
+    "canonicalName": "com.foo.UserService",
+    "codeSource": "file:/app/user-api.jar",
+    "methods": [
+        {"name": "getUser", "parameterTypes": ["long"], "returnType": "com.foo.User"},
+        {"name": "findUsers", "parameterTypes": ["java.util.List<java.lang.Long>", "java.util.Map<java.lang.String,java.lang.Object>"], "returnType": "java.util.List"},
+        {"name": "saveUser", "parameterTypes": ["com.foo.User"], "returnType": "void"},
+        {"name": "saveUser", "parameterTypes": ["com.foo.User", "boolean"], "returnType": "void"},
+        {"name": "internalReset", "parameterTypes": [], "returnType": "void"}
+    ],
+    "types": [
+        {"type": "long"},
+        {"type": "com.foo.User", "properties": {"name": {"type": "java.lang.String"}, "tags": "java.util.List<java.lang.String>", "address": {"type": "com.foo.Address"}}}
+    ],
+    "parameters": {"application": "user-app", "version": "1.0.0", "group": "", "timeout": "3000", "side": "provider"}
+}`
+	assert := assert2.New(t)
+	ext.RegisterSerializer(ext.TypeNameSerializerJson, flux.NewJsonSerializer())
+	def := DubboServiceDefinition{}
+	assert.NoError(ext.JSONUnmarshal([]byte(text), &def))
+	r := &DubboMetadataDiscoveryService{
+		pattern:    dubboMetadataPattern,
+		httpMethod: "POST",
+		excludes:   []string{"com.foo.*#internal*"},
+		attributes: []flux.Attribute{{Name: flux.EndpointAttrTagAuthorize, Value: true}},
+	}
+	eps := r.NewEndpoints(def)
+	assert.Equal(3, len(eps))
+	get := eps[0]
+	assert.True(get.IsValid())
+	assert.Equal("user-app", get.Application)
+	assert.Equal("1.0.0", get.Version)
+	assert.Equal("/user-app/com.foo.UserService/getUser", get.HttpPattern)
+	assert.Equal("POST", get.HttpMethod)
+	assert.True(get.Authorize())
+	assert.Equal(flux.ProtoDubbo, get.Service.RpcProto())
+	assert.Equal("1.0.0", get.Service.RpcVersion())
+	assert.Equal("3000ms", get.Service.RpcTimeout())
+	assert.Equal("com.foo.UserService:getUser::1.0.0", get.Service.ServiceId)
+	assert.Equal(flux.Argument{Name: "arg0", Type: flux.ArgumentTypePrimitive, Class: "long",
+		HttpName: "arg0", HttpScope: flux.ScopeAuto}, get.Service.Arguments[0])
+	find := eps[1].Service.Arguments
+	assert.Equal(flux.JavaUtilListClassName, find[0].Class)
+	assert.Equal([]string{flux.JavaLangLongClassName}, find[0].Generic)
+	assert.Equal(flux.JavaUtilMapClassName, find[1].Class)
+	assert.Equal([]string{flux.JavaLangStringClassName, "java.lang.Object"}, find[1].Generic)
+	save := eps[2].Service
+	assert.Equal(1, len(save.Arguments))
+	user := save.Arguments[0]
+	assert.Equal(flux.ArgumentTypeComplex, user.Type)
+	assert.Equal("com.foo.User", user.Class)
+	assert.Equal(3, len(user.Fields))
+	assert.Equal("address", user.Fields[0].Name)
+	assert.Equal(flux.ArgumentTypeComplex, user.Fields[0].Type)
+	assert.Equal("name", user.Fields[1].Name)
+	assert.Equal(flux.JavaLangStringClassName, user.Fields[1].Class)
+	assert.Equal("tags", user.Fields[2].Name)
+	assert.Equal([]string{flux.JavaLangStringClassName}, user.Fields[2].Generic)
+}
+
+func TestDubboMetadataIsAllowed(t *testing.T) {
+	assert := assert2.New(t)
+	r := &DubboMetadataDiscoveryService{
+		includes: []string{"com.foo.*", "com.bar.OrderService#query*"},
+		excludes: []string{"com.foo.AdminService", "com.foo.*#delete*"},
+	}
+	assert.True(r.IsAllowed("com.foo.UserService", ""))
+	assert.True(r.IsAllowed("com.foo.UserService", "getUser"))
+	assert.False(r.IsAllowed("com.foo.UserService", "deleteUser"))
+	assert.False(r.IsAllowed("com.foo.AdminService", ""))
+	assert.True(r.IsAllowed("com.bar.OrderService", ""))
+	assert.True(r.IsAllowed("com.bar.OrderService", "queryOrders"))
+	assert.False(r.IsAllowed("com.bar.OrderService", "createOrder"))
+	assert.False(r.IsAllowed("com.baz.PayService", ""))
+}
+
+func TestDubboMetadataWatch(t *testing.T) {
+	assert := assert2.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := &DubboMetadataDiscoveryService{refresh: time.Hour}
+	endpoints := make(chan flux.EndpointEvent, 4)
+	services := make(chan flux.ServiceEvent, 4)
+	assert.NoError(r.WatchEndpoints(ctx, endpoints))
+	assert.False(r.watching)
+	// Endpoint与Service均开始监听后，启动一个刷新循环
+	assert.NoError(r.WatchServices(ctx, services))
+	assert.True(r.watching)
+	ep := flux.Endpoint{HttpMethod: "POST", HttpPattern: "/app/com.foo.UserService/getUser",
+		Service: flux.Service{Interface: "com.foo.UserService", Method: "getUser"}}
+	r.emit(flux.EventTypeAdded, ep)
+	// 已生成的Endpoint不再存在时，发送删除事件
+	assert.Empty(r.doRefresh(map[string]flux.Endpoint{"k": ep}, r.emit))
+	for _, etype := range []flux.EventType{flux.EventTypeAdded, flux.EventTypeRemoved} {
+		assert.Equal(flux.EndpointEvent{EventType: etype, Endpoint: ep}, <-endpoints)
+		assert.Equal(flux.ServiceEvent{EventType: etype, Service: ep.Service}, <-services)
+	}
+	assert.Empty(endpoints)
+	assert.Empty(services)
+}
@@ -0,0 +1,32 @@
+package discovery
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	metricNamespace = "flux"
+	metricSubsystem = "discovery"
+)
+
+const (
+	driftTypeAdded   = "added"
+	driftTypeUpdated = "updated"
+	driftTypeRemoved = "removed"
+)
+
+var (
+	resyncDriftCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricNamespace,
+		Subsystem: metricSubsystem,
+		Name:      "resync_drift_total",
+		Help:      "Number of nodes corrected by discovery resync",
+	}, []string{"DiscoveryId", "RetrieverId", "Path", "DriftType"})
+	resyncCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricNamespace,
+		Subsystem: metricSubsystem,
+		Name:      "resync_total",
+		Help:      "Number of discovery resync rounds",
+	}, []string{"DiscoveryId", "RetrieverId", "Path", "Result"})
+)
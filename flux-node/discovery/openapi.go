@@ -0,0 +1,482 @@
+package discovery
+
+import (
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/spf13/cast"
+	"gopkg.in/yaml.v2"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// OpenAPI扩展属性前缀；x-flux-<name> 映射为Endpoint的同名属性
+const (
+	OpenAPIExtensionPrefix = "x-flux-"
+)
+
+// 保留的OpenAPI扩展属性，用于填充Endpoint/Service字段
+const (
+	openAPIExtApplication = "application"
+	openAPIExtVersion     = "version"
+	openAPIExtPermissions = "permissions"
+	openAPIExtServiceId   = "serviceId"
+	openAPIExtRpcTimeout  = "rpcTimeout"
+	openAPIExtRpcRetries  = "rpcRetries"
+	openAPIExtClass       = "class"
+	openAPIExtName        = "name"
+)
+
+var openAPIMethods = []string{
+	http.MethodGet, http.MethodPut, http.MethodPost, http.MethodDelete,
+	http.MethodOptions, http.MethodHead, http.MethodPatch, http.MethodTrace,
+}
+
+type (
+	openAPIExtensions map[string]interface{}
+
+	openAPIDocument struct {
+		OpenAPI    string                     `yaml:"openapi"`
+		Info       openAPIInfo                `yaml:"info"`
+		Servers    []openAPIServer            `yaml:"servers"`
+		Paths      map[string]openAPIPathItem `yaml:"paths"`
+		Components openAPIComponents          `yaml:"components"`
+		Extensions openAPIExtensions          `yaml:",inline"`
+	}
+
+	openAPIInfo struct {
+		Title      string            `yaml:"title"`
+		Version    string            `yaml:"version"`
+		Extensions openAPIExtensions `yaml:",inline"`
+	}
+
+	openAPIServer struct {
+		Url       string `yaml:"url"`
+		Variables map[string]struct {
+			Default string `yaml:"default"`
+		} `yaml:"variables"`
+	}
+
+	openAPIComponents struct {
+		Parameters    map[string]openAPIParameter   `yaml:"parameters"`
+		RequestBodies map[string]openAPIRequestBody `yaml:"requestBodies"`
+		Schemas       map[string]openAPISchema      `yaml:"schemas"`
+	}
+
+	openAPIPathItem struct {
+		Get        *openAPIOperation  `yaml:"get"`
+		Put        *openAPIOperation  `yaml:"put"`
+		Post       *openAPIOperation  `yaml:"post"`
+		Delete     *openAPIOperation  `yaml:"delete"`
+		Options    *openAPIOperation  `yaml:"options"`
+		Head       *openAPIOperation  `yaml:"head"`
+		Patch      *openAPIOperation  `yaml:"patch"`
+		Trace      *openAPIOperation  `yaml:"trace"`
+		Parameters []openAPIParameter `yaml:"parameters"`
+		Servers    []openAPIServer    `yaml:"servers"`
+		Extensions openAPIExtensions  `yaml:",inline"`
+	}
+
+	openAPIOperation struct {
+		OperationId string              `yaml:"operationId"`
+		Parameters  []openAPIParameter  `yaml:"parameters"`
+		RequestBody *openAPIRequestBody `yaml:"requestBody"`
+		Servers     []openAPIServer     `yaml:"servers"`
+		Extensions  openAPIExtensions   `yaml:",inline"`
+	}
+
+	openAPIParameter struct {
+		Ref        string            `yaml:"$ref"`
+		Name       string            `yaml:"name"`
+		In         string            `yaml:"in"`
+		Schema     *openAPISchema    `yaml:"schema"`
+		Extensions openAPIExtensions `yaml:",inline"`
+	}
+
+	openAPIRequestBody struct {
+		Ref        string                      `yaml:"$ref"`
+		Content    map[string]openAPIMediaType `yaml:"content"`
+		Extensions openAPIExtensions           `yaml:",inline"`
+	}
+
+	openAPIMediaType struct {
+		Schema *openAPISchema `yaml:"schema"`
+	}
+
+	openAPISchema struct {
+		Ref        string                   `yaml:"$ref"`
+		Type       string                   `yaml:"type"`
+		Format     string                   `yaml:"format"`
+		Default    interface{}              `yaml:"default"`
+		Items      *openAPISchema           `yaml:"items"`
+		Properties map[string]openAPISchema `yaml:"properties"`
+		Extensions openAPIExtensions        `yaml:",inline"`
+	}
+)
+
+// Lookup 查找 x-flux-<name> 扩展属性
+func (e openAPIExtensions) Lookup(name string) (interface{}, bool) {
+	v, ok := e[OpenAPIExtensionPrefix+name]
+	return v, ok
+}
+
+// NewOpenAPIResources 解析OpenAPI 3文档(YAML/JSON)，生成Endpoint定义列表。
+// Paths/Operations映射为HttpPattern/HttpMethod；Parameters/RequestBody映射为Service参数；
+// Servers映射为Service的Scheme/Url；x-flux-*扩展属性映射为Endpoint属性。
+func NewOpenAPIResources(bytes []byte) (Resources, error) {
+	doc := openAPIDocument{}
+	if err := yaml.Unmarshal(bytes, &doc); nil != err {
+		return Resources{}, fmt.Errorf("openapi decode document, err: %w", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		return Resources{}, fmt.Errorf("openapi unsupported version: %s", doc.OpenAPI)
+	}
+	paths := make([]string, 0, len(doc.Paths))
+	for p := range doc.Paths {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	out := Resources{Endpoints: make([]flux.Endpoint, 0, len(paths))}
+	for _, path := range paths {
+		item := doc.Paths[path]
+		for _, method := range openAPIMethods {
+			op := item.operation(method)
+			if nil == op {
+				continue
+			}
+			ep, err := doc.newEndpoint(path, method, item, op)
+			if nil != err {
+				return Resources{}, fmt.Errorf("openapi path: %s, method: %s, err: %w", path, method, err)
+			}
+			out.Endpoints = append(out.Endpoints, ep)
+		}
+	}
+	return out, nil
+}
+
+func (d *openAPIDocument) newEndpoint(path, method string, item openAPIPathItem, op *openAPIOperation) (flux.Endpoint, error) {
+	// 扩展属性：文档 < Info < Path < Operation
+	exts := make(openAPIExtensions, 8)
+	for _, src := range []openAPIExtensions{d.Extensions, d.Info.Extensions, item.Extensions, op.Extensions} {
+		for k, v := range src {
+			if strings.HasPrefix(k, OpenAPIExtensionPrefix) {
+				exts[k] = normalizeYAMLValue(v)
+			}
+		}
+	}
+	servers := op.Servers
+	if len(servers) == 0 {
+		servers = item.Servers
+	}
+	if len(servers) == 0 {
+		servers = d.Servers
+	}
+	if len(servers) == 0 {
+		return flux.Endpoint{}, fmt.Errorf("servers is required")
+	}
+	scheme, host, basePath, err := servers[0].resolve()
+	if nil != err {
+		return flux.Endpoint{}, err
+	}
+	service := flux.Service{
+		ServiceId: op.OperationId,
+		Scheme:    scheme,
+		Url:       host,
+		Interface: basePath + path,
+		Method:    method,
+		EmbeddedAttributes: flux.EmbeddedAttributes{
+			Attributes: []flux.Attribute{
+				{Name: flux.ServiceAttrTagRpcProto, Value: flux.ProtoHttp},
+			},
+		},
+	}
+	if v, ok := exts.Lookup(openAPIExtServiceId); ok {
+		service.ServiceId = cast.ToString(v)
+	}
+	if service.ServiceId == "" {
+		service.ServiceId = method + ":" + path
+	}
+	for _, name := range []string{openAPIExtRpcTimeout, openAPIExtRpcRetries} {
+		if v, ok := exts.Lookup(name); ok {
+			service.Attributes = append(service.Attributes, flux.Attribute{Name: name, Value: v})
+		}
+	}
+	// Parameters：Operation定义覆盖Path定义的同名参数
+	params := make([]openAPIParameter, 0, len(item.Parameters)+len(op.Parameters))
+	for _, p := range append(append([]openAPIParameter{}, item.Parameters...), op.Parameters...) {
+		p, err := d.parameter(p)
+		if nil != err {
+			return flux.Endpoint{}, err
+		}
+		for i, exist := range params {
+			if exist.Name == p.Name && exist.In == p.In {
+				params = append(params[:i], params[i+1:]...)
+				break
+			}
+		}
+		params = append(params, p)
+	}
+	for _, p := range params {
+		if arg, ok := d.newParameterArgument(p); ok {
+			service.Arguments = append(service.Arguments, arg)
+		}
+	}
+	if nil != op.RequestBody {
+		args, err := d.newBodyArguments(*op.RequestBody)
+		if nil != err {
+			return flux.Endpoint{}, err
+		}
+		service.Arguments = append(service.Arguments, args...)
+	}
+	endpoint := flux.Endpoint{
+		Application: d.Info.Title,
+		Version:     d.Info.Version,
+		HttpPattern: path,
+		HttpMethod:  method,
+		Service:     service,
+	}
+	keys := make([]string, 0, len(exts))
+	for k := range exts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		name, value := strings.TrimPrefix(k, OpenAPIExtensionPrefix), exts[k]
+		switch name {
+		case openAPIExtApplication:
+			endpoint.Application = cast.ToString(value)
+		case openAPIExtVersion:
+			endpoint.Version = cast.ToString(value)
+		case openAPIExtPermissions:
+			endpoint.Permissions = cast.ToStringSlice(value)
+		case openAPIExtServiceId, openAPIExtRpcTimeout, openAPIExtRpcRetries:
+			break
+		default:
+			endpoint.Attributes = append(endpoint.Attributes, flux.Attribute{Name: name, Value: value})
+		}
+	}
+	return endpoint, nil
+}
+
+func (d *openAPIDocument) parameter(p openAPIParameter) (openAPIParameter, error) {
+	if p.Ref == "" {
+		return p, nil
+	}
+	name := strings.TrimPrefix(p.Ref, "#/components/parameters/")
+	if ref, ok := d.Components.Parameters[name]; ok && name != p.Ref {
+		return ref, nil
+	}
+	return p, fmt.Errorf("unresolved parameter ref: %s", p.Ref)
+}
+
+func (d *openAPIDocument) schema(s *openAPISchema) (*openAPISchema, string) {
+	if nil == s || s.Ref == "" {
+		return s, ""
+	}
+	name := strings.TrimPrefix(s.Ref, "#/components/schemas/")
+	if ref, ok := d.Components.Schemas[name]; ok && name != s.Ref {
+		if ref.Type == "" {
+			ref.Type = "object"
+		}
+		return &ref, name
+	}
+	logger.Warnw("DISCOVERY:OPENAPI:SCHEMA/UNRESOLVED", "ref", s.Ref)
+	return &openAPISchema{Type: "object"}, ""
+}
+
+func (d *openAPIDocument) newParameterArgument(p openAPIParameter) (flux.Argument, bool) {
+	var scope string
+	switch strings.ToLower(p.In) {
+	case "path":
+		scope = flux.ScopePath
+	case "query":
+		scope = flux.ScopeQuery
+	case "header":
+		scope = flux.ScopeHeader
+	default:
+		logger.Warnw("DISCOVERY:OPENAPI:PARAMETER/UNSUPPORTED", "name", p.Name, "in", p.In)
+		return flux.Argument{}, false
+	}
+	arg := d.newArgument(p.Name, scope, p.Schema)
+	if v, ok := p.Extensions.Lookup(openAPIExtName); ok {
+		arg.Name = cast.ToString(v)
+	}
+	return arg, true
+}
+
+func (d *openAPIDocument) newBodyArguments(body openAPIRequestBody) ([]flux.Argument, error) {
+	if body.Ref != "" {
+		name := strings.TrimPrefix(body.Ref, "#/components/requestBodies/")
+		ref, ok := d.Components.RequestBodies[name]
+		if !ok || name == body.Ref {
+			return nil, fmt.Errorf("unresolved requestBody ref: %s", body.Ref)
+		}
+		body = ref
+	}
+	// 表单数据：按字段映射为Form参数
+	for _, mime := range []string{"application/x-www-form-urlencoded", "multipart/form-data"} {
+		media, ok := body.Content[mime]
+		if !ok {
+			continue
+		}
+		schema, _ := d.schema(media.Schema)
+		if nil == schema || len(schema.Properties) == 0 {
+			break
+		}
+		names := make([]string, 0, len(schema.Properties))
+		for n := range schema.Properties {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		args := make([]flux.Argument, 0, len(names))
+		for _, n := range names {
+			prop := schema.Properties[n]
+			args = append(args, d.newArgument(n, flux.ScopeForm, &prop))
+		}
+		return args, nil
+	}
+	// 其它类型：整体映射为Body参数
+	mimes := make([]string, 0, len(body.Content))
+	for m := range body.Content {
+		mimes = append(mimes, m)
+	}
+	if len(mimes) == 0 {
+		return nil, nil
+	}
+	sort.Strings(mimes)
+	arg := d.newArgument("body", flux.ScopeBody, body.Content[mimes[0]].Schema)
+	if v, ok := body.Extensions.Lookup(openAPIExtName); ok {
+		arg.Name = cast.ToString(v)
+	}
+	arg.HttpName = arg.Name
+	return []flux.Argument{arg}, nil
+}
+
+func (d *openAPIDocument) newArgument(name, scope string, s *openAPISchema) flux.Argument {
+	schema, _ := d.schema(s)
+	arg := flux.Argument{
+		Name:      name,
+		Type:      flux.ArgumentTypePrimitive,
+		Class:     flux.JavaLangStringClassName,
+		HttpName:  name,
+		HttpScope: scope,
+	}
+	if nil == schema {
+		return arg
+	}
+	arg.Class = d.class(s)
+	switch schema.Type {
+	case "array":
+		arg.Generic = []string{d.class(schema.Items)}
+		switch scope {
+		case flux.ScopeQuery:
+			arg.HttpScope = flux.ScopeQueryMulti
+		case flux.ScopeForm:
+			arg.HttpScope = flux.ScopeFormMulti
+		}
+	case "object":
+		arg.Type = flux.ArgumentTypeComplex
+	}
+	if nil != schema.Default {
+		arg.Attributes = append(arg.Attributes, flux.Attribute{
+			Name: flux.ArgumentAttributeTagDefault, Value: cast.ToString(schema.Default),
+		})
+	}
+	return arg
+}
+
+// class 将Schema类型映射为Java类型
+func (d *openAPIDocument) class(s *openAPISchema) string {
+	schema, _ := d.schema(s)
+	if nil == schema {
+		return flux.JavaLangStringClassName
+	}
+	if v, ok := schema.Extensions.Lookup(openAPIExtClass); ok {
+		return cast.ToString(v)
+	}
+	switch schema.Type {
+	case "integer":
+		if schema.Format == "int64" {
+			return flux.JavaLangLongClassName
+		}
+		return flux.JavaLangIntegerClassName
+	case "number":
+		if schema.Format == "float" {
+			return flux.JavaLangFloatClassName
+		}
+		return flux.JavaLangDoubleClassName
+	case "boolean":
+		return flux.JavaLangBooleanClassName
+	case "array":
+		return flux.JavaUtilListClassName
+	case "object":
+		return flux.JavaUtilMapClassName
+	default:
+		return flux.JavaLangStringClassName
+	}
+}
+
+func (p openAPIPathItem) operation(method string) *openAPIOperation {
+	switch method {
+	case http.MethodGet:
+		return p.Get
+	case http.MethodPut:
+		return p.Put
+	case http.MethodPost:
+		return p.Post
+	case http.MethodDelete:
+		return p.Delete
+	case http.MethodOptions:
+		return p.Options
+	case http.MethodHead:
+		return p.Head
+	case http.MethodPatch:
+		return p.Patch
+	case http.MethodTrace:
+		return p.Trace
+	default:
+		return nil
+	}
+}
+
+// resolve 解析Server地址，返回Scheme、Host和基础路径；Server变量使用默认值替换。
+func (s openAPIServer) resolve() (scheme, host, basePath string, err error) {
+	raw := s.Url
+	for name, v := range s.Variables {
+		raw = strings.ReplaceAll(raw, "{"+name+"}", v.Default)
+	}
+	u, err := url.Parse(raw)
+	if nil != err {
+		return "", "", "", fmt.Errorf("illegal server url: %s, err: %w", s.Url, err)
+	}
+	if u.Host == "" {
+		return "", "", "", fmt.Errorf("server url requires host: %s", s.Url)
+	}
+	scheme = u.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+	return scheme, u.Host, strings.TrimSuffix(u.Path, "/"), nil
+}
+
+// normalizeYAMLValue 将YAML解析的map[interface{}]interface{}转换为map[string]interface{}
+func normalizeYAMLValue(v interface{}) interface{} {
+	switch tv := v.(type) {
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(tv))
+		for k, iv := range tv {
+			out[cast.ToString(k)] = normalizeYAMLValue(iv)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(tv))
+		for i, iv := range tv {
+			out[i] = normalizeYAMLValue(iv)
+		}
+		return out
+	default:
+		return v
+	}
+}
@@ -0,0 +1,143 @@
+package discovery
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	assert2 "github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNewOpenAPIResources(t *testing.T) {
+	text := `
+openapi: 3.0.1
+info:
+  title: petstore
+  version: "1.0"
+  x-flux-listenerId: "default"
+servers:
+  - url: "{scheme}://pets.internal:8080/api/"
+    variables:
+      scheme:
+        default: https
+components:
+  parameters:
+    TraceId:
+      name: X-Trace-Id
+      in: header
+      schema:
+        type: string
+  schemas:
+    Pet:
+      x-flux-class: com.foo.Pet
+      properties:
+        name:
+          type: string
+paths:
+  /pets/{id}:
+    parameters:
+      - name: id
+        in: path
+        schema:
+          type: integer
+          format: int64
+    get:
+      operationId: getPet
+      x-flux-authorize: true
+      x-flux-version: "2.0"
+      x-flux-rpcTimeout: 3s
+      parameters:
+        - $ref: "#/components/parameters/TraceId"
+        - name: tags
+          in: query
+          schema:
+            type: array
+            items:
+              type: string
+        - name: limit
+          in: query
+          schema:
+            type: integer
+            default: 10
+    put:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              $ref: "#/components/schemas/Pet"
+  /login:
+    post:
+      requestBody:
+        content:
+          application/x-www-form-urlencoded:
+            schema:
+              type: object
+              properties:
+                username:
+                  type: string
+                remember:
+                  type: boolean
+`
+	assert := assert2.New(t)
+	res, err := NewOpenAPIResources([]byte(text))
+	assert.NoError(err)
+	assert.Equal(3, len(res.Endpoints))
+	// POST /login
+	login := res.Endpoints[0]
+	assert.Equal("/login", login.HttpPattern)
+	assert.Equal("POST", login.HttpMethod)
+	assert.Equal("POST:/login", login.Service.ServiceId)
+	assert.Equal(2, len(login.Service.Arguments))
+	assert.Equal("remember", login.Service.Arguments[0].Name)
+	assert.Equal(flux.JavaLangBooleanClassName, login.Service.Arguments[0].Class)
+	assert.Equal(flux.ScopeForm, login.Service.Arguments[0].HttpScope)
+	// GET /pets/{id}
+	get := res.Endpoints[1]
+	assert.True(get.IsValid())
+	assert.Equal("petstore", get.Application)
+	assert.Equal("2.0", get.Version)
+	assert.Equal("GET", get.HttpMethod)
+	assert.True(get.Authorize())
+	assert.Equal("default", get.GetAttr(flux.EndpointAttrTagListenerId).GetString())
+	assert.Equal("getPet", get.Service.ServiceId)
+	assert.Equal("https", get.Service.Scheme)
+	assert.Equal("pets.internal:8080", get.Service.Url)
+	assert.Equal("/api/pets/{id}", get.Service.Interface)
+	assert.Equal("GET", get.Service.Method)
+	assert.Equal(flux.ProtoHttp, get.Service.RpcProto())
+	assert.Equal("3s", get.Service.RpcTimeout())
+	args := get.Service.Arguments
+	assert.Equal(4, len(args))
+	assert.Equal(flux.Argument{Name: "id", Type: flux.ArgumentTypePrimitive, Class: flux.JavaLangLongClassName,
+		HttpName: "id", HttpScope: flux.ScopePath}, args[0])
+	assert.Equal(flux.ScopeHeader, args[1].HttpScope)
+	assert.Equal("X-Trace-Id", args[1].HttpName)
+	assert.Equal(flux.ScopeQueryMulti, args[2].HttpScope)
+	assert.Equal(flux.JavaUtilListClassName, args[2].Class)
+	assert.Equal([]string{flux.JavaLangStringClassName}, args[2].Generic)
+	assert.Equal(flux.JavaLangIntegerClassName, args[3].Class)
+	assert.Equal("10", args[3].GetAttr(flux.ArgumentAttributeTagDefault).GetString())
+	// PUT /pets/{id}
+	put := res.Endpoints[2]
+	assert.Equal("1.0", put.Version)
+	assert.False(put.Authorize())
+	assert.Equal(2, len(put.Service.Arguments))
+	body := put.Service.Arguments[1]
+	assert.Equal("body", body.Name)
+	assert.Equal(flux.ScopeBody, body.HttpScope)
+	assert.Equal(flux.ArgumentTypeComplex, body.Type)
+	assert.Equal("com.foo.Pet", body.Class)
+}
+
+func TestNewOpenAPIResourcesRequiresServers(t *testing.T) {
+	text := `
+openapi: 3.0.0
+info:
+  title: demo
+paths:
+  /demo:
+    get: {}
+`
+	_, err := NewOpenAPIResources([]byte(text))
+	assert2.Error(t, err)
+	_, err = NewOpenAPIResources([]byte(`{"swagger": "2.0"}`))
+	assert2.Error(t, err)
+}
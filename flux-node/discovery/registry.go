@@ -0,0 +1,76 @@
+package discovery
+
+import (
+	"bytes"
+	"github.com/bytepowered/flux/flux-node/remoting"
+	"sync"
+)
+
+// NodeRegistry 记录已接收的注册节点数据，用于周期性全量对账。
+type NodeRegistry struct {
+	nodes map[string][]byte
+	mu    sync.RWMutex
+}
+
+func NewNodeRegistry() *NodeRegistry {
+	return &NodeRegistry{
+		nodes: make(map[string][]byte, 16),
+	}
+}
+
+// Track 包装节点监听函数：记录节点数据，并为删除事件补全节点数据；
+// 数据未变化的新增/更新事件，以及重复的删除事件将被忽略。
+func (g *NodeRegistry) Track(listener remoting.NodeChangedListener) remoting.NodeChangedListener {
+	return func(event remoting.NodeEvent) {
+		switch event.EventType {
+		case remoting.EventTypeNodeAdd, remoting.EventTypeNodeUpdate:
+			if cached, ok := g.Load(event.Path); ok && bytes.Equal(cached, event.Data) {
+				return
+			}
+			g.Store(event.Path, event.Data)
+		case remoting.EventTypeNodeDelete:
+			data, ok := g.Remove(event.Path)
+			if !ok {
+				return
+			}
+			if len(event.Data) == 0 {
+				event.Data = data
+			}
+		}
+		listener(event)
+	}
+}
+
+func (g *NodeRegistry) Store(path string, data []byte) {
+	g.mu.Lock()
+	g.nodes[path] = data
+	g.mu.Unlock()
+}
+
+func (g *NodeRegistry) Load(path string) ([]byte, bool) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	data, ok := g.nodes[path]
+	return data, ok
+}
+
+func (g *NodeRegistry) Remove(path string) ([]byte, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	data, ok := g.nodes[path]
+	if ok {
+		delete(g.nodes, path)
+	}
+	return data, ok
+}
+
+// Paths 返回已记录的全部节点路径
+func (g *NodeRegistry) Paths() []string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	out := make([]string, 0, len(g.nodes))
+	for p := range g.nodes {
+		out = append(out, p)
+	}
+	return out
+}
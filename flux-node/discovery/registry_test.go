@@ -0,0 +1,30 @@
+package discovery
+
+import (
+	"github.com/bytepowered/flux/flux-node/remoting"
+	assert2 "github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNodeRegistryTrack(t *testing.T) {
+	assert := assert2.New(t)
+	registry := NewNodeRegistry()
+	received := make([]remoting.NodeEvent, 0)
+	listener := registry.Track(func(event remoting.NodeEvent) {
+		received = append(received, event)
+	})
+	data := []byte(`{"k":1}`)
+	listener(remoting.NodeEvent{Path: "/a", EventType: remoting.EventTypeNodeAdd, Data: data})
+	// 数据未变化，忽略
+	listener(remoting.NodeEvent{Path: "/a", EventType: remoting.EventTypeNodeAdd, Data: data})
+	assert.Equal(1, len(received))
+	assert.Equal([]string{"/a"}, registry.Paths())
+	// 删除事件补全节点数据
+	listener(remoting.NodeEvent{Path: "/a", EventType: remoting.EventTypeNodeDelete})
+	assert.Equal(2, len(received))
+	assert.Equal(data, received[1].Data)
+	// 重复删除，忽略
+	listener(remoting.NodeEvent{Path: "/a", EventType: remoting.EventTypeNodeDelete})
+	assert.Equal(2, len(received))
+	assert.Equal(0, len(registry.Paths()))
+}
@@ -14,7 +14,10 @@ const (
 	ResourceId = "resource"
 )
 
-var _ flux.EndpointDiscovery = new(ResourceDiscoveryService)
+var (
+	_ flux.EndpointDiscovery = new(ResourceDiscoveryService)
+	_ flux.ConsumerDiscovery = new(ResourceDiscoveryService)
+)
 
 type (
 	// ZookeeperOption 配置函数
@@ -24,6 +27,7 @@ type (
 type Resources struct {
 	Endpoints []flux.Endpoint `yaml:"endpoints"`
 	Services  []flux.Service  `yaml:"services"`
+	Consumers []flux.Consumer `yaml:"consumers"`
 }
 
 // NewResourceServiceWith returns new a resource based discovery service
@@ -54,10 +58,17 @@ func (r *ResourceDiscoveryService) Init(config *flux.Configuration) error {
 	if err := r.includes(files); nil != err {
 		return err
 	}
+	// 加载OpenAPI文档定义
+	docs := config.GetStringSlice("openapis")
+	logger.Infow("Resource discovery, load openapi documents", "openapis", docs)
+	if err := r.openapis(docs); nil != err {
+		return err
+	}
 	// 本地指定
 	define := map[string]interface{}{
 		"endpoints": config.GetOrDefault("endpoints", make([]interface{}, 0)),
 		"services":  config.GetOrDefault("services", make([]interface{}, 0)),
+		"consumers": config.GetOrDefault("consumers", make([]interface{}, 0)),
 	}
 	if bytes, err := ext.JSONMarshal(define); nil != err {
 		return fmt.Errorf("response discovery, redecode config, error: %w", err)
@@ -65,7 +76,8 @@ func (r *ResourceDiscoveryService) Init(config *flux.Configuration) error {
 		var out Resources
 		if err := yaml.Unmarshal(bytes, &out); nil != err {
 			return fmt.Errorf("discovery service decode config, err: %w", err)
-		} else if len(out.Endpoints) > 0 || len(out.Services) > 0 {
+		} else if len(out.Endpoints) > 0 || len(out.Services) > 0 || len(out.Consumers) > 0 {
+			out.normalize()
 			r.resources = append(r.resources, out)
 		}
 	}
@@ -96,6 +108,19 @@ func (r *ResourceDiscoveryService) WatchServices(ctx context.Context, events cha
 	return nil
 }
 
+func (r *ResourceDiscoveryService) WatchConsumers(ctx context.Context, events chan<- flux.ConsumerEvent) error {
+	for _, res := range r.resources {
+		for _, c := range res.Consumers {
+			if c.IsValid() {
+				events <- flux.ConsumerEvent{EventType: flux.EventTypeAdded, Consumer: c}
+			} else {
+				logger.Warnw("DISCOVERY:RESOURCE:CONSUMER:INVALID", "consumer-id", c.ConsumerId)
+			}
+		}
+	}
+	return nil
+}
+
 func (r *ResourceDiscoveryService) includes(files []string) error {
 	for _, file := range files {
 		bytes, err := ioutil.ReadFile(file)
@@ -105,6 +130,40 @@ func (r *ResourceDiscoveryService) includes(files []string) error {
 		var out Resources
 		if err := yaml.Unmarshal(bytes, &out); nil != err {
 			return fmt.Errorf("discovery service decode config, path: %s, err: %w", file, err)
+		} else {
+			out.normalize()
+			r.resources = append(r.resources, out)
+		}
+	}
+	return nil
+}
+
+// normalize 转换YAML解析的属性值，map[interface{}]interface{}不支持JSON序列化
+func (res Resources) normalize() {
+	for i := range res.Endpoints {
+		normalizeAttributes(res.Endpoints[i].Attributes)
+		normalizeAttributes(res.Endpoints[i].Service.Attributes)
+		normalizeAttributes(res.Endpoints[i].PermissionService.Attributes)
+	}
+	for i := range res.Services {
+		normalizeAttributes(res.Services[i].Attributes)
+	}
+}
+
+func normalizeAttributes(attrs []flux.Attribute) {
+	for i := range attrs {
+		attrs[i].Value = normalizeYAMLValue(attrs[i].Value)
+	}
+}
+
+func (r *ResourceDiscoveryService) openapis(files []string) error {
+	for _, file := range files {
+		bytes, err := ioutil.ReadFile(file)
+		if nil != err {
+			return fmt.Errorf("discovery service read openapi, path: %s, err: %w", file, err)
+		}
+		if out, err := NewOpenAPIResources(bytes); nil != err {
+			return fmt.Errorf("discovery service decode openapi, path: %s, err: %w", file, err)
 		} else {
 			r.resources = append(r.resources, out)
 		}
@@ -0,0 +1,66 @@
+package discovery
+
+import (
+	"context"
+	"github.com/bytepowered/flux/flux-node"
+	assert2 "github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResourceDiscoveryLocalProtos(t *testing.T) {
+	assert := assert2.New(t)
+	dir, err := ioutil.TempDir("", "flux-resource")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "local.yml")
+	assert.NoError(ioutil.WriteFile(file, []byte(`
+endpoints:
+    -   httpPattern: "/fixed"
+        httpMethod: "GET"
+        service:
+            attributes:
+                -   name: "rpcProto"
+                    value: "FIXED"
+                -   name: "fixedBody"
+                    value:
+                        status: "ok"
+                        items: [ { id: 1 } ]
+    -   httpPattern: "/static/*"
+        httpMethod: "GET"
+        service:
+            attributes:
+                -   name: "rpcProto"
+                    value: "STATIC"
+services:
+    -   serviceId: "redirect"
+        attributes:
+            -   name: "rpcProto"
+                value: "REDIRECT"
+            -   name: "redirectLocation"
+                value: "/new"
+`), 0644))
+	r := NewResourceServiceWith("test")
+	assert.NoError(r.includes([]string{file}))
+	endpoints := make(chan flux.EndpointEvent, 4)
+	assert.NoError(r.WatchEndpoints(context.TODO(), endpoints))
+	close(endpoints)
+	// 未定义staticRoot的STATIC服务无效
+	var loaded []flux.Endpoint
+	for event := range endpoints {
+		loaded = append(loaded, event.Endpoint)
+	}
+	if assert.Len(loaded, 1) {
+		// YAML解析的数据转换为可JSON序列化的类型
+		body := loaded[0].Service.GetAttr("fixedBody").Value
+		assert.Equal(map[string]interface{}{
+			"status": "ok",
+			"items":  []interface{}{map[string]interface{}{"id": 1}},
+		}, body)
+	}
+	services := make(chan flux.ServiceEvent, 4)
+	assert.NoError(r.WatchServices(context.TODO(), services))
+	assert.Len(services, 1)
+}
@@ -1,6 +1,7 @@
 package discovery
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -8,6 +9,7 @@ import (
 	"github.com/bytepowered/flux/flux-node/logger"
 	"github.com/bytepowered/flux/flux-node/remoting"
 	"github.com/bytepowered/flux/flux-node/remoting/zk"
+	"sync"
 	"time"
 )
 
@@ -15,6 +17,7 @@ const (
 	// 在ZK注册的根节点。需要与客户端的注册保持一致。
 	zkDiscoveryEndpointPath = "/flux-endpoint"
 	zkDiscoveryServicePath  = "/flux-service"
+	zkDiscoveryConsumerPath = "/flux-consumer"
 )
 
 const (
@@ -24,10 +27,15 @@ const (
 const (
 	zkConfigRootpathEndpoint = "rootpath_endpoint"
 	zkConfigRootpathService  = "rootpath_service"
+	zkConfigRootpathConsumer = "rootpath_consumer"
 	zkConfigRegistrySelector = "registry_selector"
+	zkConfigResyncInterval   = "resync_interval"
 )
 
-var _ flux.EndpointDiscovery = new(ZookeeperDiscoveryService)
+var (
+	_ flux.EndpointDiscovery = new(ZookeeperDiscoveryService)
+	_ flux.ConsumerDiscovery = new(ZookeeperDiscoveryService)
+)
 
 type (
 	// ZookeeperOption 配置函数
@@ -40,7 +48,19 @@ type ZookeeperDiscoveryService struct {
 	globalAlias  map[string]string
 	endpointPath string
 	servicePath  string
+	consumerPath string
 	retrievers   []*zk.ZookeeperRetriever
+	resync       time.Duration
+	watching     []zkWatching
+	watchingMu   sync.Mutex
+}
+
+// zkWatching 记录已监听的根节点，用于周期性全量对账
+type zkWatching struct {
+	retriever *zk.ZookeeperRetriever
+	rootpath  string
+	registry  *NodeRegistry
+	listener  remoting.NodeChangedListener
 }
 
 // WithGlobalAlias 配置注册中心的配置别名
@@ -70,37 +90,53 @@ func (r *ZookeeperDiscoveryService) Init(config *flux.Configuration) error {
 	config.SetDefaults(map[string]interface{}{
 		zkConfigRootpathEndpoint: zkDiscoveryEndpointPath,
 		zkConfigRootpathService:  zkDiscoveryServicePath,
+		zkConfigRootpathConsumer: zkDiscoveryConsumerPath,
+		zkConfigResyncInterval:   time.Minute,
 	})
-	selected := config.GetStringSlice(zkConfigRegistrySelector)
-	if len(selected) == 0 {
-		selected = []string{"default"}
-	}
-	logger.Infow("ZkEndpointDiscovery selected discovery", "selected-ids", selected)
 	r.endpointPath = config.GetString(zkConfigRootpathEndpoint)
 	r.servicePath = config.GetString(zkConfigRootpathService)
+	r.consumerPath = config.GetString(zkConfigRootpathConsumer)
+	r.resync = config.GetDuration(zkConfigResyncInterval)
 	if r.endpointPath == "" || r.servicePath == "" {
 		return errors.New("config(rootpath_endpoint, rootpath_service) is empty")
 	}
-	r.retrievers = make([]*zk.ZookeeperRetriever, len(selected))
+	retrievers, err := newZookeeperRetrievers(config, r.globalAlias)
+	if nil != err {
+		return err
+	}
+	r.retrievers = retrievers
+	return nil
+}
+
+// newZookeeperRetrievers 按registry_selector选择并初始化多个注册中心的ZK客户端
+func newZookeeperRetrievers(config *flux.Configuration, globalAlias map[string]string) ([]*zk.ZookeeperRetriever, error) {
+	selected := config.GetStringSlice(zkConfigRegistrySelector)
+	if len(selected) == 0 {
+		selected = []string{"default"}
+	}
+	logger.Infow("ZkEndpointDiscovery selected discovery", "selected-ids", selected)
+	retrievers := make([]*zk.ZookeeperRetriever, len(selected))
 	registries := config.Sub("registry_centers")
 	for i := range selected {
 		id := selected[i]
-		r.retrievers[i] = zk.NewZookeeperRetriever(id)
+		retrievers[i] = zk.NewZookeeperRetriever(id)
 		zkconf := registries.Sub(id)
 		zkconf.SetKeyAlias(map[string]string{
 			"address":  "zookeeper.address",
+			"username": "zookeeper.username",
 			"password": "zookeeper.password",
 			"timeout":  "zookeeper.timeout",
+			"chroot":   "zookeeper.chroot",
 		})
-		if len(r.globalAlias) != 0 {
-			zkconf.SetKeyAlias(r.globalAlias)
+		if len(globalAlias) != 0 {
+			zkconf.SetKeyAlias(globalAlias)
 		}
 		logger.Infow("ZkEndpointDiscovery start zk discovery", "discovery-id", id)
-		if err := r.retrievers[i].Init(zkconf); nil != err {
-			return err
+		if err := retrievers[i].Init(zkconf); nil != err {
+			return nil, err
 		}
 	}
-	return nil
+	return retrievers, nil
 }
 
 // OnEndpointChanged Listen http endpoints events
@@ -120,7 +156,11 @@ func (r *ZookeeperDiscoveryService) WatchEndpoints(ctx context.Context, events c
 		}
 	}
 	logger.Infow(msg, "endpoint-path", r.endpointPath)
-	return r.onRetrievers(ctx, r.endpointPath, callback)
+	if err := r.onRetrievers(ctx, r.endpointPath, callback); nil != err {
+		return err
+	}
+	go r.resyncLoop(ctx, r.endpointPath)
+	return nil
 }
 
 // OnServiceChanged Listen gateway services events
@@ -137,7 +177,39 @@ func (r *ZookeeperDiscoveryService) WatchServices(ctx context.Context, events ch
 		}
 	}
 	logger.Infow(msg, "endpoint-path", r.servicePath)
-	return r.onRetrievers(ctx, r.servicePath, callback)
+	if err := r.onRetrievers(ctx, r.servicePath, callback); nil != err {
+		return err
+	}
+	go r.resyncLoop(ctx, r.servicePath)
+	return nil
+}
+
+// WatchConsumers Listen gateway consumers events
+func (r *ZookeeperDiscoveryService) WatchConsumers(ctx context.Context, events chan<- flux.ConsumerEvent) error {
+	const msg = "DISCOVERY:ZOOKEEPER:CONSUMER:LISTEN_NODE"
+	if r.consumerPath == "" {
+		logger.Infow(msg+"/DISABLED", "consumer-path", r.consumerPath)
+		return nil
+	}
+	callback := func(event remoting.NodeEvent) {
+		defer func() {
+			if r := recover(); nil != r {
+				logger.Errorw(msg, "consumer-event", event.Path, "error", r)
+			}
+		}()
+		// 节点数据包含Key哈希值，日志只输出节点路径
+		if evt, err := NewConsumerEvent(event.Data, event.EventType); nil == err {
+			events <- evt
+		} else {
+			logger.Errorw(msg, "consumer-event", event.Path, "error", err)
+		}
+	}
+	logger.Infow(msg, "consumer-path", r.consumerPath)
+	if err := r.onRetrievers(ctx, r.consumerPath, callback); nil != err {
+		return err
+	}
+	go r.resyncLoop(ctx, r.consumerPath)
+	return nil
 }
 
 func (r *ZookeeperDiscoveryService) onRetrievers(ctx context.Context, path string, callback func(remoting.NodeEvent)) error {
@@ -175,16 +247,112 @@ func (r *ZookeeperDiscoveryService) watch(retriever *zk.ZookeeperRetriever, root
 			return fmt.Errorf("init metadata node: %w", err)
 		}
 	}
+	registry := NewNodeRegistry()
+	listener := registry.Track(nodeListener)
+	r.watchingMu.Lock()
+	r.watching = append(r.watching, zkWatching{
+		retriever: retriever, rootpath: rootpath, registry: registry, listener: listener,
+	})
+	r.watchingMu.Unlock()
 	return retriever.AddChildrenNodeChangedListener("", rootpath, func(event remoting.NodeEvent) {
 		logger.Infow("DISCOVERY:ZOOKEEPER:RETRIEVERS:WATCH:RECV", "event", event)
-		if event.EventType == remoting.EventTypeChildAdd {
-			if err := retriever.AddNodeChangedListener("", event.Path, nodeListener); nil != err {
+		switch event.EventType {
+		case remoting.EventTypeChildAdd:
+			if err := watchNode(retriever, event.Path, listener); nil != err {
 				logger.Warnw("Watch child node data", "error", err)
 			}
+		case remoting.EventTypeChildDelete:
+			// 子节点删除时，由Registry补全节点数据；与节点监听的删除事件去重
+			listener(remoting.NodeEvent{Path: event.Path, EventType: remoting.EventTypeNodeDelete})
 		}
 	})
 }
 
+// watchNode 监听节点数据；节点已注册监听时，只重新启动已停止的Watch，不重复添加监听函数
+func watchNode(retriever *zk.ZookeeperRetriever, nodePath string, listener remoting.NodeChangedListener) error {
+	if retriever.Rewatch(nodePath) {
+		return nil
+	}
+	return retriever.AddNodeChangedListener("", nodePath, listener)
+}
+
+func (r *ZookeeperDiscoveryService) resyncLoop(ctx context.Context, rootpath string) {
+	if r.resync <= 0 {
+		logger.Infow("DISCOVERY:ZOOKEEPER:RESYNC:DISABLED", "watch-path", rootpath)
+		return
+	}
+	ticker := time.NewTicker(r.resync)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Infow("DISCOVERY:ZOOKEEPER:RESYNC:STOP", "watch-path", rootpath)
+			return
+		case <-ticker.C:
+			r.watchingMu.Lock()
+			watching := make([]zkWatching, len(r.watching))
+			copy(watching, r.watching)
+			r.watchingMu.Unlock()
+			for _, w := range watching {
+				if w.rootpath == rootpath {
+					r.doResync(w)
+				}
+			}
+		}
+	}
+}
+
+// doResync 全量读取根节点下的子节点，与已接收的节点数据比对，并发送修正事件
+func (r *ZookeeperDiscoveryService) doResync(w zkWatching) {
+	const msg = "DISCOVERY:ZOOKEEPER:RESYNC"
+	defer func() {
+		if rvr := recover(); nil != rvr {
+			logger.Errorw(msg+"/PANIC", "watch-path", w.rootpath, "error", rvr)
+		}
+	}()
+	children, err := w.retriever.Children(w.rootpath)
+	if nil != err {
+		resyncCounter.WithLabelValues(r.id, w.retriever.Id, w.rootpath, "error").Inc()
+		logger.Warnw(msg+"/ERROR", "retriever-id", w.retriever.Id, "watch-path", w.rootpath, "error", err)
+		return
+	}
+	drift := func(typ string) {
+		resyncDriftCounter.WithLabelValues(r.id, w.retriever.Id, w.rootpath, typ).Inc()
+	}
+	present := make(map[string]struct{}, len(children))
+	for _, child := range children {
+		present[child] = struct{}{}
+		data, err := w.retriever.GetData(child)
+		if nil != err {
+			logger.Warnw(msg+"/GET_DATA", "node-path", child, "error", err)
+			continue
+		}
+		if cached, ok := w.registry.Load(child); !ok {
+			logger.Infow(msg+"/DRIFT:ADDED", "node-path", child)
+			drift(driftTypeAdded)
+			w.listener(remoting.NodeEvent{Path: child, EventType: remoting.EventTypeNodeAdd, Data: data})
+		} else if !bytes.Equal(cached, data) {
+			logger.Infow(msg+"/DRIFT:UPDATED", "node-path", child)
+			drift(driftTypeUpdated)
+			w.listener(remoting.NodeEvent{Path: child, EventType: remoting.EventTypeNodeUpdate, Data: data})
+		}
+		// 节点监听丢失时，重新注册
+		if !w.retriever.IsWatching(child) {
+			if err := watchNode(w.retriever, child, w.listener); nil != err {
+				logger.Warnw(msg+"/REWATCH", "node-path", child, "error", err)
+			}
+		}
+	}
+	for _, p := range w.registry.Paths() {
+		if _, ok := present[p]; !ok {
+			logger.Infow(msg+"/DRIFT:REMOVED", "node-path", p)
+			drift(driftTypeRemoved)
+			w.listener(remoting.NodeEvent{Path: p, EventType: remoting.EventTypeNodeDelete})
+		}
+	}
+	resyncCounter.WithLabelValues(r.id, w.retriever.Id, w.rootpath, "success").Inc()
+}
+
 // Startup startup discovery service
 func (r *ZookeeperDiscoveryService) Startup() error {
 	logger.Info("ZkEndpointDiscovery startup")
@@ -12,15 +12,34 @@ const (
 	ErrorCodeGatewayEndpoint    = "GATEWAY:ENDPOINT"
 	ErrorCodeGatewayCircuited   = "GATEWAY:CIRCUITED"
 	ErrorCodeGatewayCanceled    = "GATEWAY:CANCELED"
+	ErrorCodeGatewayDraining    = "GATEWAY:DRAINING"
 	ErrorCodeRequestInvalid     = "REQUEST:INVALID"
 	ErrorCodeRequestNotFound    = "REQUEST:NOT_FOUND"
+	ErrorCodeRequestNotAllowed  = "REQUEST:METHOD_NOT_ALLOWED"
+	ErrorCodeRequestRateLimited = "REQUEST:RATE_LIMITED"
+	ErrorCodeRequestIPDenied    = "REQUEST:IP_DENIED"
 	ErrorCodePermissionDenied   = "PERMISSION:ACCESS_DENIED"
 )
 
 const (
-	ErrorCodeJwtMalformed = "AUTHORIZATION:JWT:MALFORMED"
-	ErrorCodeJwtExpired   = "AUTHORIZATION:JWT:EXPIRED"
-	ErrorCodeJwtNotFound  = "AUTHORIZATION:JWT:NOTFOUND"
+	ErrorCodeJwtMalformed     = "AUTHORIZATION:JWT:MALFORMED"
+	ErrorCodeJwtExpired       = "AUTHORIZATION:JWT:EXPIRED"
+	ErrorCodeJwtNotFound      = "AUTHORIZATION:JWT:NOTFOUND"
+	ErrorCodeJwtInvalidClaims = "AUTHORIZATION:JWT:INVALID_CLAIMS"
+
+	ErrorCodeOAuth2NotFound = "AUTHORIZATION:OAUTH2:NOTFOUND"
+	ErrorCodeOAuth2Inactive = "AUTHORIZATION:OAUTH2:INACTIVE"
+
+	ErrorCodeApiKeyNotFound = "AUTHORIZATION:APIKEY:NOTFOUND"
+	ErrorCodeApiKeyInvalid  = "AUTHORIZATION:APIKEY:INVALID"
+	ErrorCodeConsumerDenied = "AUTHORIZATION:CONSUMER:DENIED"
+
+	ErrorCodeTLSClientCertRequired = "AUTHORIZATION:TLS:CLIENT_CERT_REQUIRED"
+
+	ErrorCodeSignatureNotFound = "AUTHORIZATION:SIGNATURE:NOTFOUND"
+	ErrorCodeSignatureInvalid  = "AUTHORIZATION:SIGNATURE:INVALID"
+	ErrorCodeSignatureExpired  = "AUTHORIZATION:SIGNATURE:EXPIRED"
+	ErrorCodeSignatureReplayed = "AUTHORIZATION:SIGNATURE:REPLAYED"
 )
 
 const (
@@ -37,11 +56,19 @@ const (
 	ErrorMessageHttpInvokeFailed   = "TRANSPORT:HT:INVOKE"
 	ErrorMessageHttpAssembleFailed = "TRANSPORT:HT:ASSEMBLE"
 
+	ErrorMessageRedirectLocationMissing = "TRANSPORT:REDIRECT:LOCATION_MISSING"
+	ErrorMessageRedirectLocationInvalid = "TRANSPORT:REDIRECT:LOCATION_INVALID"
+	ErrorMessageStaticFileNotFound      = "TRANSPORT:STATIC:FILE_NOT_FOUND"
+	ErrorMessageStaticFileSystemMissing = "TRANSPORT:STATIC:FS_MISSING"
+
 	ErrorMessagePermissionAccessDenied    = "PERMISSION:ACCESS_DENIED"
 	ErrorMessagePermissionServiceNotFound = "PERMISSION:SERVICE:NOT_FOUND"
 	ErrorMessagePermissionVerifyError     = "PERMISSION:VERIFY:ERROR"
 
-	ErrorMessageWebServerRequestNotFound = "SERVER:REQUEST:NOT_FOUND"
+	ErrorMessageWebServerRequestNotFound  = "SERVER:REQUEST:NOT_FOUND"
+	ErrorMessageWebServerMethodNotAllowed = "SERVER:REQUEST:METHOD_NOT_ALLOWED"
+	ErrorMessageWebServerDraining         = "SERVER:SHUTDOWN:DRAINING"
+	ErrorMessageWebServerClientCertAbsent = "SERVER:TLS:CLIENT_CERT_ABSENT"
 
 	ErrorMessageRequestPrepare = "REQUEST:BODY:PREPARE"
 )
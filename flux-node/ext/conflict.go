@@ -0,0 +1,36 @@
+package ext
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"sync"
+)
+
+var (
+	discoveryConflicts = new(sync.Map)
+)
+
+// RecordDiscoveryConflict 记录注册中心数据冲突；相同数据、相同来源的冲突只保留最新记录。
+func RecordDiscoveryConflict(conflict flux.DiscoveryConflict) {
+	discoveryConflicts.Store(conflict.Kind+"#"+conflict.Key+"#"+conflict.Source, conflict)
+}
+
+// DiscoveryConflicts 返回全部注册中心数据冲突记录
+func DiscoveryConflicts() []flux.DiscoveryConflict {
+	out := make([]flux.DiscoveryConflict, 0, 8)
+	discoveryConflicts.Range(func(_, value interface{}) bool {
+		out = append(out, value.(flux.DiscoveryConflict))
+		return true
+	})
+	return out
+}
+
+// RemoveDiscoveryConflicts 删除指定数据的全部冲突记录
+func RemoveDiscoveryConflicts(kind, key string) {
+	prefix := kind + "#" + key + "#"
+	discoveryConflicts.Range(func(k, _ interface{}) bool {
+		if id := k.(string); len(id) > len(prefix) && id[:len(prefix)] == prefix {
+			discoveryConflicts.Delete(k)
+		}
+		return true
+	})
+}
@@ -0,0 +1,84 @@
+package ext
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/bytepowered/flux/flux-node"
+	"sync"
+)
+
+var (
+	consumerNotFound flux.Consumer
+	consumersMu      sync.RWMutex
+	consumersMap     = make(map[string]flux.Consumer, 16)
+	consumerKeysMap  = make(map[string]string, 16)
+)
+
+// HashConsumerKey 返回API Key的SHA-256哈希值(Hex)
+func HashConsumerKey(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])
+}
+
+// RegisterConsumer 注册Consumer，并建立API Key哈希值索引
+func RegisterConsumer(consumer flux.Consumer) {
+	consumersMu.Lock()
+	defer consumersMu.Unlock()
+	removeConsumer(consumer.ConsumerId)
+	consumersMap[consumer.ConsumerId] = consumer
+	for _, hash := range consumer.KeyHashes {
+		consumerKeysMap[hash] = consumer.ConsumerId
+	}
+}
+
+// RemoveConsumerByID 删除Consumer及其API Key索引
+func RemoveConsumerByID(consumerId string) {
+	consumersMu.Lock()
+	defer consumersMu.Unlock()
+	removeConsumer(consumerId)
+}
+
+// ConsumerByID 按ID查找Consumer
+func ConsumerByID(consumerId string) (flux.Consumer, bool) {
+	consumersMu.RLock()
+	defer consumersMu.RUnlock()
+	c, ok := consumersMap[consumerId]
+	if !ok {
+		return consumerNotFound, false
+	}
+	return c, true
+}
+
+// ConsumerByKey 按API Key查找Consumer
+func ConsumerByKey(apiKey string) (flux.Consumer, bool) {
+	hash := HashConsumerKey(apiKey)
+	consumersMu.RLock()
+	defer consumersMu.RUnlock()
+	id, ok := consumerKeysMap[hash]
+	if !ok {
+		return consumerNotFound, false
+	}
+	return consumersMap[id], true
+}
+
+// Consumers 返回全部注册的Consumer
+func Consumers() map[string]flux.Consumer {
+	consumersMu.RLock()
+	defer consumersMu.RUnlock()
+	out := make(map[string]flux.Consumer, len(consumersMap))
+	for id, c := range consumersMap {
+		out[id] = c
+	}
+	return out
+}
+
+func removeConsumer(consumerId string) {
+	if old, ok := consumersMap[consumerId]; ok {
+		for _, hash := range old.KeyHashes {
+			if consumerKeysMap[hash] == consumerId {
+				delete(consumerKeysMap, hash)
+			}
+		}
+		delete(consumersMap, consumerId)
+	}
+}
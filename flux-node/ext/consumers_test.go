@@ -0,0 +1,32 @@
+package ext
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	assert2 "github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestConsumerByKey(t *testing.T) {
+	assert := assert2.New(t)
+	RegisterConsumer(flux.Consumer{
+		ConsumerId: "partner-a",
+		KeyHashes:  []string{HashConsumerKey("key-1"), HashConsumerKey("key-2")},
+	})
+	c, ok := ConsumerByKey("key-2")
+	assert.True(ok)
+	assert.Equal("partner-a", c.ConsumerId)
+	// 更新后，旧Key失效
+	RegisterConsumer(flux.Consumer{
+		ConsumerId: "partner-a",
+		KeyHashes:  []string{HashConsumerKey("key-3")},
+	})
+	_, ok = ConsumerByKey("key-1")
+	assert.False(ok)
+	_, ok = ConsumerByKey("key-3")
+	assert.True(ok)
+	RemoveConsumerByID("partner-a")
+	_, ok = ConsumerByKey("key-3")
+	assert.False(ok)
+	_, ok = ConsumerByID("partner-a")
+	assert.False(ok)
+}
@@ -14,6 +14,19 @@ func MakeEndpointKey(method, pattern string) string {
 	return strings.ToUpper(method) + "#" + pattern
 }
 
+// MakeEndpointKeyOf 构建Endpoint的注册Key；Endpoint定义了Host和请求谓词时，追加到Key，
+// 格式：METHOD#pattern@host1,host2?predicate1&predicate2
+func MakeEndpointKeyOf(endpoint *flux.Endpoint) string {
+	key := MakeEndpointKey(endpoint.HttpMethod, endpoint.HttpPattern)
+	if hosts := endpoint.RouteHosts(); len(hosts) > 0 {
+		key += "@" + strings.Join(hosts, ",")
+	}
+	if matches := endpoint.RouteMatches(); len(matches) > 0 {
+		key += "?" + strings.Join(matches, "&")
+	}
+	return key
+}
+
 func RegisterEndpoint(key string, endpoint *flux.Endpoint) *flux.MVCEndpoint {
 	mvce := flux.NewMVCEndpoint(endpoint)
 	endpoints.Store(key, mvce)
@@ -28,6 +41,11 @@ func EndpointByKey(key string) (*flux.MVCEndpoint, bool) {
 	return nil, false
 }
 
+// RemoveEndpoint 删除指定Key的多版本Endpoint
+func RemoveEndpoint(key string) {
+	endpoints.Delete(key)
+}
+
 func Endpoints() map[string]*flux.MVCEndpoint {
 	out := make(map[string]*flux.MVCEndpoint, 32)
 	endpoints.Range(func(key, value interface{}) bool {
@@ -9,6 +9,7 @@ var (
 	hooksPrepare  = make([]flux.PrepareHookFunc, 0, 16)
 	hooksStartup  = make([]flux.Startuper, 0, 16)
 	hooksShutdown = make([]flux.Shutdowner, 0, 16)
+	hooksService  = make([]flux.ServiceEventHookFunc, 0, 4)
 )
 
 // AddHookFunc 添加生命周期启动与停止的钩子接口
@@ -27,6 +28,11 @@ func AddPrepareHook(pf flux.PrepareHookFunc) {
 	hooksPrepare = append(hooksPrepare, fluxpkg.MustNotNil(pf, "PrepareHookFunc is nil").(flux.PrepareHookFunc))
 }
 
+// AddServiceEventHook 添加Service变更事件的钩子函数
+func AddServiceEventHook(hf flux.ServiceEventHookFunc) {
+	hooksService = append(hooksService, fluxpkg.MustNotNil(hf, "ServiceEventHookFunc is nil").(flux.ServiceEventHookFunc))
+}
+
 func PrepareHooks() []flux.PrepareHookFunc {
 	dst := make([]flux.PrepareHookFunc, len(hooksPrepare))
 	copy(dst, hooksPrepare)
@@ -44,3 +50,9 @@ func ShutdownHooks() []flux.Shutdowner {
 	copy(dst, hooksShutdown)
 	return dst
 }
+
+func ServiceEventHooks() []flux.ServiceEventHookFunc {
+	dst := make([]flux.ServiceEventHookFunc, len(hooksService))
+	copy(dst, hooksService)
+	return dst
+}
@@ -14,6 +14,8 @@ type (
 	Factory func() interface{}
 	// PrepareHookFunc 在初始化调用前的预备函数
 	PrepareHookFunc func() error
+	// ServiceEventHookFunc 在Service注册数据变更生效后调用的钩子函数
+	ServiceEventHookFunc func(event ServiceEvent)
 	// Startuper 用于介入服务启动生命周期的Hook，通常与 Orderer 接口一起使用。
 	Startuper interface {
 		Startup() error // 当服务启动时，调用此函数
@@ -27,6 +27,7 @@ type AdaptWebContext struct {
 	context   context.Context
 	echoc     echo.Context
 	variables map[interface{}]interface{}
+	pathVars  url.Values
 }
 
 func (w *AdaptWebContext) WebListener() flux.WebListener {
@@ -78,6 +79,13 @@ func (w *AdaptWebContext) QueryVars() url.Values {
 }
 
 func (w *AdaptWebContext) PathVars() url.Values {
+	if w.pathVars != nil {
+		copied := make(url.Values, len(w.pathVars))
+		for n := range w.pathVars {
+			copied.Set(n, w.pathVars.Get(n))
+		}
+		return copied
+	}
 	names := w.echoc.ParamNames()
 	copied := make(url.Values, len(names))
 	for _, n := range names {
@@ -86,6 +94,11 @@ func (w *AdaptWebContext) PathVars() url.Values {
 	return copied
 }
 
+func (w *AdaptWebContext) SetPathVars(vars url.Values) {
+	// 不使用echo.SetParamNames：它会修改全局的最大参数数量
+	w.pathVars = vars
+}
+
 func (w *AdaptWebContext) FormVars() url.Values {
 	f, _ := w.echoc.FormParams()
 	return f
@@ -104,6 +117,9 @@ func (w *AdaptWebContext) QueryVar(name string) string {
 }
 
 func (w *AdaptWebContext) PathVar(name string) string {
+	if w.pathVars != nil {
+		return w.pathVars.Get(name)
+	}
 	// use cached vars
 	return w.echoc.Param(name)
 }
@@ -122,10 +138,15 @@ func (w *AdaptWebContext) BodyReader() (io.ReadCloser, error) {
 
 func (w *AdaptWebContext) Rewrite(method string, path string) {
 	if "" != method {
+		// 多次重写时，保留最初的请求方法
+		if _, ok := w.variables[flux.XOriginalMethod]; !ok && method != w.Request().Method {
+			w.variables[flux.XOriginalMethod] = w.Request().Method
+		}
 		w.Request().Method = method
 	}
 	if "" != path {
 		w.Request().URL.Path = path
+		w.Request().URL.RawPath = ""
 	}
 }
 
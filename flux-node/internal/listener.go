@@ -2,7 +2,9 @@ package internal
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
+	"fmt"
 	"github.com/bytepowered/flux/flux-node"
 	"github.com/bytepowered/flux/flux-node/ext"
 	"github.com/bytepowered/flux/flux-node/logger"
@@ -12,6 +14,9 @@ import (
 	"net/http"
 	"runtime/debug"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const (
@@ -20,11 +25,20 @@ const (
 	ConfigKeyTLSCertFile = "tls_cert_file"
 	ConfigKeyTLSKeyFile  = "tls_key_file"
 	ConfigKeyBodyLimit   = "body_limit"
-	ConfigKeyCORSEnable  = "cors_enable"
+	ConfigKeyCORSEnable  = "cors_enable" // Deprecated: 使用ListenServer的cors配置
 	ConfigKeyCSRFEnable  = "csrf_enable"
 	ConfigKeyFeatures    = "features"
 )
 
+const (
+	// 客户端CA证书，用于验证客户端证书(mTLS)
+	ConfigKeyTLSClientCAFile = "tls_client_ca_file"
+	// 客户端证书验证模式：none, request, require
+	ConfigKeyTLSClientAuth = "tls_client_auth"
+	// 证书文件变更检查间隔；设置为0时不重新加载
+	ConfigKeyTLSReloadInterval = "tls_reload_interval"
+)
+
 var _ flux.WebListener = new(AdaptWebListener)
 
 func init() {
@@ -45,6 +59,7 @@ func NewAdaptWebListenerWith(listenerId string, options *flux.Configuration, ide
 		id:           listenerId,
 		server:       server,
 		bodyResolver: DefaultRequestBodyResolver,
+		routes:       make(map[string]*routeHandler, 16),
 	}
 	// Init context
 	server.Pre(func(next echo.HandlerFunc) echo.HandlerFunc {
@@ -82,11 +97,7 @@ func NewAdaptWebListenerWith(listenerId string, options *flux.Configuration, ide
 		logger.Infof("WebListener(id:%s), feature BODY-LIMIT: enabled, size= %s", webListener.id, limit)
 		server.Pre(middleware.BodyLimit(limit))
 	}
-	// CORS
-	if enabled := features.GetBool(ConfigKeyCORSEnable); enabled {
-		logger.Infof("WebListener(id:%s), feature CORS: enabled", webListener.id)
-		server.Pre(middleware.CORS())
-	}
+	// CORS由网关路由处理，按Endpoint选择CORS策略；参见 listener.CORSPolicy
 	// CSRF
 	if enabled := features.GetBool(ConfigKeyCSRFEnable); enabled {
 		logger.Infof("WebListener(id:%s), feature CSRF: enabled", webListener.id)
@@ -107,8 +118,26 @@ type AdaptWebListener struct {
 	bodyResolver flux.WebBodyResolver
 	tlsCertFile  string
 	tlsKeyFile   string
+	tlsCAFile    string
+	tlsAuth      tls.ClientAuthType
+	tlsReload    time.Duration
+	tlsReloader  *TLSReloader
 	address      string
 	started      bool
+	routes       map[string]*routeHandler
+	routesMu     sync.Mutex
+}
+
+// routeHandler 可替换的路由处理函数；echo路由不支持删除，删除后的路由由NotFound处理。
+type routeHandler struct {
+	handler atomic.Value
+}
+
+func (r *routeHandler) AdaptFunc(c echo.Context) error {
+	if h, ok := r.handler.Load().(echo.HandlerFunc); ok && h != nil {
+		return h(c)
+	}
+	return echo.NotFoundHandler(c)
 }
 
 func (s *AdaptWebListener) ListenerId() string {
@@ -118,6 +147,17 @@ func (s *AdaptWebListener) ListenerId() string {
 func (s *AdaptWebListener) Init(opts *flux.Configuration) error {
 	s.tlsCertFile = opts.GetString(ConfigKeyTLSCertFile)
 	s.tlsKeyFile = opts.GetString(ConfigKeyTLSKeyFile)
+	s.tlsCAFile = opts.GetString(ConfigKeyTLSClientCAFile)
+	if auth, err := ParseTLSClientAuth(opts.GetString(ConfigKeyTLSClientAuth)); nil != err {
+		return fmt.Errorf("%w, listener-id: %s", err, s.id)
+	} else {
+		s.tlsAuth = auth
+	}
+	if opts.IsSet(ConfigKeyTLSReloadInterval) {
+		s.tlsReload = opts.GetDuration(ConfigKeyTLSReloadInterval)
+	} else {
+		s.tlsReload = 30 * time.Second
+	}
 	addr, port := opts.GetString(ConfigKeyAddress), opts.GetString(ConfigKeyBindPort)
 	if strings.Contains(addr, ":") {
 		s.address = addr
@@ -134,13 +174,34 @@ func (s *AdaptWebListener) Init(opts *flux.Configuration) error {
 func (s *AdaptWebListener) Listen() error {
 	logger.Infof("WebListener(id:%s) start listen: %s", s.id, s.address)
 	s.started = true
+	// echo.Shutdown后Server不可重用，每次启动使用新的Server实例
+	s.server.Server, s.server.TLSServer = new(http.Server), new(http.Server)
+	s.server.Listener, s.server.TLSListener = nil, nil
 	if "" != s.tlsCertFile && "" != s.tlsKeyFile {
-		return s.server.StartTLS(s.address, s.tlsCertFile, s.tlsKeyFile)
+		return s.listenTLS()
 	} else {
 		return s.server.Start(s.address)
 	}
 }
 
+// listenTLS 使用可重新加载的证书启动TLS服务；配置客户端CA时启用客户端证书验证
+func (s *AdaptWebListener) listenTLS() error {
+	protos := []string{"http/1.1"}
+	if !s.server.DisableHTTP2 {
+		protos = append([]string{"h2"}, protos...)
+	}
+	reloader, err := NewTLSReloader(s.tlsCertFile, s.tlsKeyFile, s.tlsCAFile, s.tlsAuth, protos)
+	if nil != err {
+		return fmt.Errorf("%w, listener-id: %s", err, s.id)
+	}
+	s.tlsReloader = reloader
+	go reloader.Watch(s.tlsReload)
+	logger.Infof("WebListener(id:%s), feature TLS: enabled, client-auth: %s", s.id, s.tlsAuth)
+	s.server.TLSServer.Addr = s.address
+	s.server.TLSServer.TLSConfig = reloader.TLSConfig()
+	return s.server.StartServer(s.server.TLSServer)
+}
+
 func (s *AdaptWebListener) SetBodyResolver(r flux.WebBodyResolver) {
 	fluxpkg.AssertNotNil(r, "WebBodyResolver must not nil, listener-id: "+s.id)
 	s.mustNotStarted().bodyResolver = r
@@ -192,7 +253,7 @@ func (s *AdaptWebListener) AddHandler(method, pattern string, h flux.WebHandler,
 	for i, mi := range is {
 		wms[i] = AdaptWebInterceptor(mi).AdaptFunc
 	}
-	s.server.Add(strings.ToUpper(method), s.resolve(pattern), AdaptWebHandler(h).AdaptFunc, wms...)
+	s.addRoute(method, pattern, AdaptWebHandler(h).AdaptFunc, wms)
 }
 
 func (s *AdaptWebListener) AddHttpHandler(method, pattern string, h http.Handler, m ...func(http.Handler) http.Handler) {
@@ -203,7 +264,35 @@ func (s *AdaptWebListener) AddHttpHandler(method, pattern string, h http.Handler
 	for i, mf := range m {
 		wms[i] = echo.WrapMiddleware(mf)
 	}
-	s.server.Add(strings.ToUpper(method), s.resolve(pattern), echo.WrapHandler(h), wms...)
+	s.addRoute(method, pattern, echo.WrapHandler(h), wms)
+}
+
+func (s *AdaptWebListener) RemoveHandler(method, pattern string) {
+	fluxpkg.Assert("" != method, "Method must not empty")
+	fluxpkg.Assert("" != pattern, "Pattern must not empty")
+	s.routesMu.Lock()
+	defer s.routesMu.Unlock()
+	if route, ok := s.routes[strings.ToUpper(method)+"#"+s.resolve(pattern)]; ok {
+		route.handler.Store(echo.HandlerFunc(nil))
+	}
+}
+
+// addRoute 注册路由；相同路由重复注册时，替换其处理函数
+func (s *AdaptWebListener) addRoute(method, pattern string, h echo.HandlerFunc, wms []echo.MiddlewareFunc) {
+	for i := len(wms) - 1; i >= 0; i-- {
+		h = wms[i](h)
+	}
+	method, path := strings.ToUpper(method), s.resolve(pattern)
+	key := method + "#" + path
+	s.routesMu.Lock()
+	defer s.routesMu.Unlock()
+	route, ok := s.routes[key]
+	if !ok {
+		route = new(routeHandler)
+		s.routes[key] = route
+		s.server.Add(method, path, route.AdaptFunc)
+	}
+	route.handler.Store(h)
 }
 
 func (s *AdaptWebListener) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -220,6 +309,10 @@ func (s *AdaptWebListener) ShadowServer() interface{} {
 
 func (s *AdaptWebListener) Close(ctx context.Context) error {
 	s.started = false
+	if s.tlsReloader != nil {
+		s.tlsReloader.Close()
+		s.tlsReloader = nil
+	}
 	return s.server.Shutdown(ctx)
 }
 
@@ -0,0 +1,153 @@
+package internal
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	TLSClientAuthNone    = "none"
+	TLSClientAuthRequest = "request"
+	TLSClientAuthRequire = "require"
+)
+
+// ParseTLSClientAuth 解析客户端证书验证模式：none, request(客户端提供证书时验证), require(必须提供并验证)
+func ParseTLSClientAuth(mode string) (tls.ClientAuthType, error) {
+	switch strings.ToLower(mode) {
+	case "", TLSClientAuthNone:
+		return tls.NoClientCert, nil
+	case TLSClientAuthRequest:
+		return tls.VerifyClientCertIfGiven, nil
+	case TLSClientAuthRequire:
+		return tls.RequireAndVerifyClientCert, nil
+	default:
+		return tls.NoClientCert, fmt.Errorf("unknown tls client auth mode: %s", mode)
+	}
+}
+
+// TLSReloader 加载服务端证书和客户端CA证书，并周期性检查文件变更后重新加载
+type TLSReloader struct {
+	certFile   string
+	keyFile    string
+	caFile     string
+	clientAuth tls.ClientAuthType
+	nextProtos []string
+	config     atomic.Value
+	modTimes   map[string]time.Time
+	stop       chan struct{}
+}
+
+func NewTLSReloader(certFile, keyFile, caFile string, clientAuth tls.ClientAuthType, nextProtos []string) (*TLSReloader, error) {
+	if clientAuth != tls.NoClientCert && caFile == "" {
+		return nil, errors.New("tls client ca file is required when client auth enabled")
+	}
+	r := &TLSReloader{
+		certFile:   certFile,
+		keyFile:    keyFile,
+		caFile:     caFile,
+		clientAuth: clientAuth,
+		nextProtos: nextProtos,
+		modTimes:   make(map[string]time.Time, 3),
+		stop:       make(chan struct{}),
+	}
+	r.modTimes = r.stat()
+	if err := r.load(); nil != err {
+		return nil, err
+	}
+	return r, nil
+}
+
+// TLSConfig 返回TLS配置；每次握手使用最新加载的证书
+func (r *TLSReloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return r.config.Load().(*tls.Config), nil
+		},
+	}
+}
+
+// Watch 周期性检查证书文件的修改时间，变更后重新加载；加载失败时保留旧证书
+func (r *TLSReloader) Watch(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			modTimes := r.stat()
+			if !r.changed(modTimes) {
+				continue
+			}
+			if err := r.load(); nil != err {
+				logger.Warnw("LISTENER:TLS:RELOAD/ERROR", "cert-file", r.certFile, "ca-file", r.caFile, "error", err)
+				continue
+			}
+			r.modTimes = modTimes
+			logger.Infow("LISTENER:TLS:RELOAD/OK", "cert-file", r.certFile, "ca-file", r.caFile)
+		}
+	}
+}
+
+func (r *TLSReloader) Close() {
+	close(r.stop)
+}
+
+func (r *TLSReloader) load() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if nil != err {
+		return fmt.Errorf("load tls key pair: %w", err)
+	}
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   r.clientAuth,
+		NextProtos:   r.nextProtos,
+	}
+	if r.caFile != "" {
+		data, err := ioutil.ReadFile(r.caFile)
+		if nil != err {
+			return fmt.Errorf("read tls client ca: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return fmt.Errorf("no certificates found in tls client ca: %s", r.caFile)
+		}
+		config.ClientCAs = pool
+	}
+	r.config.Store(config)
+	return nil
+}
+
+func (r *TLSReloader) stat() map[string]time.Time {
+	out := make(map[string]time.Time, 3)
+	for _, file := range []string{r.certFile, r.keyFile, r.caFile} {
+		if file == "" {
+			continue
+		}
+		if info, err := os.Stat(file); nil == err {
+			out[file] = info.ModTime()
+		}
+	}
+	return out
+}
+
+func (r *TLSReloader) changed(modTimes map[string]time.Time) bool {
+	for file, mt := range modTimes {
+		if !mt.Equal(r.modTimes[file]) {
+			return true
+		}
+	}
+	return false
+}
@@ -13,6 +13,8 @@ const (
 	MIMEApplicationJSON            = "application/json"
 	MIMEApplicationJSONCharsetUTF8 = MIMEApplicationJSON + "; " + charsetUTF8
 	MIMEApplicationForm            = "application/x-www-form-urlencoded"
+	MIMETextPlain                  = "text/plain"
+	MIMETextPlainCharsetUTF8       = MIMETextPlain + "; " + charsetUTF8
 )
 
 // Headers
@@ -26,6 +28,8 @@ const (
 	HeaderContentLength       = "Content-Length"
 	HeaderContentType         = "Content-Type"
 	HeaderCookie              = "Cookie"
+	HeaderCacheControl        = "Cache-Control"
+	HeaderETag                = "ETag"
 	HeaderSetCookie           = "Set-Cookie"
 	HeaderIfModifiedSince     = "If-Modified-Since"
 	HeaderLastModified        = "Last-Modified"
@@ -33,6 +37,7 @@ const (
 	HeaderUpgrade             = "Upgrade"
 	HeaderVary                = "Vary"
 	HeaderWWWAuthenticate     = "WWW-Authenticate"
+	HeaderRetryAfter          = "Retry-After"
 	HeaderXForwardedFor       = "X-Forwarded-For"
 	HeaderXForwardedProto     = "X-Forwarded-Protocol"
 	HeaderXForwardedProtocol  = "X-Forwarded-Protocol"
@@ -79,6 +84,7 @@ const (
 	StatusServerError  = http.StatusInternalServerError
 	StatusBadGateway   = http.StatusBadGateway
 	StatusNoContent    = http.StatusNoContent
+	StatusUnavailable  = http.StatusServiceUnavailable
 )
 
 // Web interfaces defines
@@ -155,6 +161,9 @@ type ServerWebContext interface {
 	// PathValue 查询指定Name的动态路径参数值
 	PathVar(name string) string
 
+	// SetPathVars 设置动态路径参数；用于网关路由表匹配请求后设置路径参数
+	SetPathVars(vars url.Values)
+
 	// FormValue 查询指定Name的表单参数值
 	FormVar(name string) string
 
@@ -164,7 +173,7 @@ type ServerWebContext interface {
 	// BodyReader 返回可重复读取的Reader接口；
 	BodyReader() (io.ReadCloser, error)
 
-	// Rewrite 修改请求方法和路径；
+	// Rewrite 修改请求方法和路径；修改请求方法时，原始请求方法记录到Variable：XOriginalMethod
 	Rewrite(method string, path string)
 
 	// Write 直接写入并返回响应状态码和响应数据到客户端
@@ -234,6 +243,9 @@ type WebListener interface {
 	// AddHttpHandler 添加http标准请求路由处理函数及其中间件
 	AddHttpHandler(method, pattern string, h http.Handler, m ...func(http.Handler) http.Handler)
 
+	// RemoveHandler 删除请求路由处理函数；删除后的路由请求由NotFound处理函数处理
+	RemoveHandler(method, pattern string)
+
 	// ServeHTTP Http调用
 	ServeHTTP(w http.ResponseWriter, r *http.Request)
 
@@ -0,0 +1,249 @@
+package listener
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	ConfigKeyCORS                 = "cors"
+	ConfigKeyCORSEnable           = "enable"
+	ConfigKeyCORSAllowOrigins     = "allow_origins"
+	ConfigKeyCORSAllowMethods     = "allow_methods"
+	ConfigKeyCORSAllowHeaders     = "allow_headers"
+	ConfigKeyCORSAllowCredentials = "allow_credentials"
+	ConfigKeyCORSMaxAge           = "max_age"
+	ConfigKeyCORSExposeHeaders    = "expose_headers"
+)
+
+const (
+	// 兼容旧版本的CORS开关：features.cors_enable
+	configKeyFeatures          = "features"
+	configKeyFeatureCORSEnable = "cors_enable"
+	corsOriginRegexPrefix      = "regex:"
+)
+
+var (
+	DefaultCORSAllowOrigins = []string{"*"}
+	DefaultCORSAllowMethods = []string{
+		http.MethodGet, http.MethodHead, http.MethodPut, http.MethodPatch, http.MethodPost, http.MethodDelete,
+	}
+)
+
+// 已编译的Origin正则表达式；编译失败时缓存为nil
+var corsOriginRegexps = new(sync.Map)
+
+// CORSPolicy 跨域资源共享策略；Endpoint可通过属性覆盖ListenServer的配置
+type CORSPolicy struct {
+	Enabled          bool
+	AllowOrigins     []string // 允许的Origin，支持：*，精确匹配，通配符：https://*.example.com，正则：regex:^https://.+$
+	AllowMethods     []string // 允许的请求方法
+	AllowHeaders     []string // 允许的请求Header；为空时返回预检请求的Access-Control-Request-Headers
+	AllowCredentials bool     // 是否允许携带凭证；允许时返回请求的Origin；AllowOrigins包含*时不允许
+	MaxAge           int      // 预检请求结果的缓存时间，单位：秒
+	ExposeHeaders    []string // 允许客户端读取的响应Header
+}
+
+// LoadCORSPolicy 从ListenServer配置的cors加载CORS策略；兼容features.cors_enable开关
+func LoadCORSPolicy(config *flux.Configuration) *CORSPolicy {
+	cors := config.Sub(ConfigKeyCORS)
+	enabled := config.Sub(configKeyFeatures).GetBool(configKeyFeatureCORSEnable)
+	if cors.IsSet(ConfigKeyCORSEnable) {
+		enabled = cors.GetBool(ConfigKeyCORSEnable)
+	}
+	policy := &CORSPolicy{
+		Enabled:          enabled,
+		AllowOrigins:     cors.GetStringSlice(ConfigKeyCORSAllowOrigins),
+		AllowMethods:     toUpperStrings(cors.GetStringSlice(ConfigKeyCORSAllowMethods)),
+		AllowHeaders:     cors.GetStringSlice(ConfigKeyCORSAllowHeaders),
+		AllowCredentials: cors.GetBool(ConfigKeyCORSAllowCredentials),
+		MaxAge:           cors.GetInt(ConfigKeyCORSMaxAge),
+		ExposeHeaders:    cors.GetStringSlice(ConfigKeyCORSExposeHeaders),
+	}
+	if len(policy.AllowOrigins) == 0 {
+		policy.AllowOrigins = DefaultCORSAllowOrigins
+	}
+	if len(policy.AllowMethods) == 0 {
+		policy.AllowMethods = DefaultCORSAllowMethods
+	}
+	if policy.checkCredentials() {
+		logger.Warnw("SERVER:CORS:CREDENTIALS_DISABLED", "reason", "allow_credentials with wildcard origin")
+	}
+	return policy
+}
+
+// IsCORSPreflight 判断请求是否为CORS预检请求
+func IsCORSPreflight(request *http.Request) bool {
+	return request.Method == http.MethodOptions &&
+		request.Header.Get(flux.HeaderOrigin) != "" &&
+		request.Header.Get(flux.HeaderAccessControlRequestMethod) != ""
+}
+
+// WithEndpoint 返回按Endpoint属性覆盖后的CORS策略；Endpoint定义corsOrigins属性时启用CORS
+func (p *CORSPolicy) WithEndpoint(endpoint *flux.Endpoint) *CORSPolicy {
+	out := CORSPolicy{AllowOrigins: DefaultCORSAllowOrigins, AllowMethods: DefaultCORSAllowMethods}
+	if p != nil {
+		out = *p
+	}
+	overridden := false
+	if attr, ok := endpoint.GetAttrEx(flux.EndpointAttrTagCORSOrigins); ok {
+		out.Enabled, out.AllowOrigins, overridden = true, attr.GetStringSlice(), true
+	}
+	if attr, ok := endpoint.GetAttrEx(flux.EndpointAttrTagCORSMethods); ok {
+		out.AllowMethods, overridden = toUpperStrings(attr.GetStringSlice()), true
+	}
+	if attr, ok := endpoint.GetAttrEx(flux.EndpointAttrTagCORSHeaders); ok {
+		out.AllowHeaders, overridden = attr.GetStringSlice(), true
+	}
+	if attr, ok := endpoint.GetAttrEx(flux.EndpointAttrTagCORSCredentials); ok {
+		out.AllowCredentials, overridden = attr.GetBool(), true
+	}
+	if attr, ok := endpoint.GetAttrEx(flux.EndpointAttrTagCORSMaxAge); ok {
+		out.MaxAge, overridden = attr.GetInt(), true
+	}
+	if attr, ok := endpoint.GetAttrEx(flux.EndpointAttrTagCORSExposeHeaders); ok {
+		out.ExposeHeaders, overridden = attr.GetStringSlice(), true
+	}
+	if !overridden && p != nil {
+		return p
+	}
+	// 每个请求都会执行，不输出日志
+	out.checkCredentials()
+	return &out
+}
+
+// checkCredentials 允许任意Origin(*)时，禁止携带凭证，避免任意站点携带凭证访问；返回是否禁止了凭证
+func (p *CORSPolicy) checkCredentials() bool {
+	if !p.AllowCredentials {
+		return false
+	}
+	for _, pattern := range p.AllowOrigins {
+		if strings.TrimSpace(pattern) == "*" {
+			p.AllowCredentials = false
+			return true
+		}
+	}
+	return false
+}
+
+// AllowOrigin 判断是否允许请求的Origin；返回Access-Control-Allow-Origin的响应值
+func (p *CORSPolicy) AllowOrigin(origin string) (string, bool) {
+	if p == nil || !p.Enabled || origin == "" {
+		return "", false
+	}
+	for _, pattern := range p.AllowOrigins {
+		if !matchCORSOrigin(pattern, origin) {
+			continue
+		}
+		if strings.TrimSpace(pattern) == "*" {
+			return "*", true
+		}
+		return origin, true
+	}
+	return "", false
+}
+
+// AllowMethod 判断是否允许请求方法
+func (p *CORSPolicy) AllowMethod(method string) bool {
+	for _, m := range p.AllowMethods {
+		if m == "*" || m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// WritePreflight 写入预检请求的响应Header；Origin或请求方法不允许时，只写入Vary，返回false
+func (p *CORSPolicy) WritePreflight(header http.Header, request *http.Request) bool {
+	header.Add(flux.HeaderVary, flux.HeaderOrigin)
+	header.Add(flux.HeaderVary, flux.HeaderAccessControlRequestMethod)
+	header.Add(flux.HeaderVary, flux.HeaderAccessControlRequestHeaders)
+	allowOrigin, ok := p.AllowOrigin(request.Header.Get(flux.HeaderOrigin))
+	method := strings.ToUpper(request.Header.Get(flux.HeaderAccessControlRequestMethod))
+	if !ok || !p.AllowMethod(method) {
+		return false
+	}
+	header.Set(flux.HeaderAccessControlAllowOrigin, allowOrigin)
+	header.Set(flux.HeaderAccessControlAllowMethods, strings.Join(p.AllowMethods, ","))
+	if len(p.AllowHeaders) > 0 {
+		header.Set(flux.HeaderAccessControlAllowHeaders, strings.Join(p.AllowHeaders, ","))
+	} else if reqHeaders := request.Header.Get(flux.HeaderAccessControlRequestHeaders); reqHeaders != "" {
+		header.Set(flux.HeaderAccessControlAllowHeaders, reqHeaders)
+	}
+	if p.AllowCredentials {
+		header.Set(flux.HeaderAccessControlAllowCredentials, "true")
+	}
+	if p.MaxAge > 0 {
+		header.Set(flux.HeaderAccessControlMaxAge, strconv.Itoa(p.MaxAge))
+	}
+	return true
+}
+
+// WriteResponse 写入实际请求的CORS响应Header；Origin不允许时，只写入Vary，返回false
+func (p *CORSPolicy) WriteResponse(header http.Header, request *http.Request) bool {
+	origin := request.Header.Get(flux.HeaderOrigin)
+	if origin == "" || p == nil || !p.Enabled {
+		return false
+	}
+	header.Add(flux.HeaderVary, flux.HeaderOrigin)
+	allowOrigin, ok := p.AllowOrigin(origin)
+	if !ok {
+		return false
+	}
+	header.Set(flux.HeaderAccessControlAllowOrigin, allowOrigin)
+	if p.AllowCredentials {
+		header.Set(flux.HeaderAccessControlAllowCredentials, "true")
+	}
+	if len(p.ExposeHeaders) > 0 {
+		header.Set(flux.HeaderAccessControlExposeHeaders, strings.Join(p.ExposeHeaders, ","))
+	}
+	return true
+}
+
+// matchCORSOrigin 匹配Origin：*，精确匹配(忽略大小写)，通配符，regex:前缀的正则表达式
+func matchCORSOrigin(pattern, origin string) bool {
+	pattern = strings.TrimSpace(pattern)
+	switch {
+	case pattern == "*":
+		return true
+	case strings.HasPrefix(pattern, corsOriginRegexPrefix):
+		regex := compileCORSOrigin(pattern, strings.TrimPrefix(pattern, corsOriginRegexPrefix))
+		return regex != nil && regex.MatchString(origin)
+	case strings.Contains(pattern, "*"):
+		parts := strings.Split(pattern, "*")
+		for i, part := range parts {
+			parts[i] = regexp.QuoteMeta(part)
+		}
+		regex := compileCORSOrigin(pattern, "(?i)^"+strings.Join(parts, "[^/]+")+"$")
+		return regex != nil && regex.MatchString(origin)
+	default:
+		return strings.EqualFold(pattern, origin)
+	}
+}
+
+func compileCORSOrigin(pattern, expr string) *regexp.Regexp {
+	if cached, ok := corsOriginRegexps.Load(pattern); ok {
+		return cached.(*regexp.Regexp)
+	}
+	regex, err := regexp.Compile(expr)
+	if nil != err {
+		logger.Warnw("SERVER:CORS:INVALID_ORIGIN", "origin", pattern, "error", err)
+		regex = nil
+	}
+	corsOriginRegexps.Store(pattern, regex)
+	return regex
+}
+
+// toUpperStrings 返回转换为大写的副本；不修改参数，参数可能是Endpoint共享的属性值
+func toUpperStrings(values []string) []string {
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = strings.ToUpper(strings.TrimSpace(v))
+	}
+	return out
+}
@@ -0,0 +1,86 @@
+package listener
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	assert2 "github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORSPolicy(t *testing.T) {
+	assert := assert2.New(t)
+	ext.SetLoggerFactory(logger.DefaultFactory)
+	config := flux.NewConfiguration("listeners.corstest")
+	config.Set("cors.enable", true)
+	config.Set("cors.allow_origins", []string{"https://app.com", "https://*.brand.com", "regex:^https://[a-z]+\\.dev:[0-9]+$"})
+	config.Set("cors.expose_headers", []string{"X-Trace-Id"})
+	policy := LoadCORSPolicy(config)
+	assert.True(policy.Enabled)
+	assert.Equal(DefaultCORSAllowMethods, policy.AllowMethods)
+	cases := []struct {
+		origin string
+		allow  bool
+	}{
+		{"https://app.com", true},
+		{"HTTPS://APP.COM", true},
+		{"https://shop.brand.com", true},
+		{"https://brand.com", false},
+		{"https://shop.brand.com.evil.com", false},
+		{"https://local.dev:8080", true},
+		{"http://app.com", false},
+	}
+	for _, c := range cases {
+		value, ok := policy.AllowOrigin(c.origin)
+		assert.Equal(c.allow, ok, c.origin)
+		if ok {
+			assert.Equal(c.origin, value, c.origin)
+		}
+	}
+	request := httptest.NewRequest(http.MethodGet, "http://gw.com/api", nil)
+	request.Header.Set(flux.HeaderOrigin, "https://app.com")
+	header := http.Header{}
+	assert.True(policy.WriteResponse(header, request))
+	assert.Equal("https://app.com", header.Get(flux.HeaderAccessControlAllowOrigin))
+	assert.Equal("X-Trace-Id", header.Get(flux.HeaderAccessControlExposeHeaders))
+	assert.Equal(flux.HeaderOrigin, header.Get(flux.HeaderVary))
+	// Endpoint属性覆盖
+	endpoint := &flux.Endpoint{EmbeddedAttributes: flux.EmbeddedAttributes{Attributes: []flux.Attribute{
+		{Name: flux.EndpointAttrTagCORSOrigins, Value: "*"},
+		{Name: flux.EndpointAttrTagCORSMethods, Value: []string{"get", "post"}},
+	}}}
+	merged := policy.WithEndpoint(endpoint)
+	value, ok := merged.AllowOrigin("https://any.com")
+	assert.True(ok)
+	assert.Equal("*", value)
+	assert.True(merged.AllowMethod(http.MethodPost))
+	assert.False(merged.AllowMethod(http.MethodDelete))
+	assert.Equal([]string{"https://app.com", "https://*.brand.com", "regex:^https://[a-z]+\\.dev:[0-9]+$"}, policy.AllowOrigins)
+	// 不修改Endpoint的属性值
+	assert.Equal([]string{"get", "post"}, endpoint.GetAttr(flux.EndpointAttrTagCORSMethods).GetStringSlice())
+	// 允许任意Origin时，禁止携带凭证
+	wildcard := flux.NewConfiguration("listeners.corswildcard")
+	wildcard.Set("cors.enable", true)
+	wildcard.Set("cors.allow_origins", []string{"*"})
+	wildcard.Set("cors.allow_credentials", true)
+	assert.False(LoadCORSPolicy(wildcard).AllowCredentials)
+	credentials := policy.WithEndpoint(&flux.Endpoint{EmbeddedAttributes: flux.EmbeddedAttributes{Attributes: []flux.Attribute{
+		{Name: flux.EndpointAttrTagCORSOrigins, Value: "*"},
+		{Name: flux.EndpointAttrTagCORSCredentials, Value: true},
+	}}})
+	assert.False(credentials.AllowCredentials)
+	header = http.Header{}
+	request.Header.Set(flux.HeaderOrigin, "https://evil.com")
+	assert.True(credentials.WriteResponse(header, request))
+	assert.Equal("*", header.Get(flux.HeaderAccessControlAllowOrigin))
+	assert.Empty(header.Get(flux.HeaderAccessControlAllowCredentials))
+	// 未启用时，旧版本开关启用默认策略
+	legacy := flux.NewConfiguration("listeners.corslegacy")
+	assert.False(LoadCORSPolicy(legacy).Enabled)
+	legacy.Set("features.cors_enable", true)
+	value, ok = LoadCORSPolicy(legacy).AllowOrigin("https://any.com")
+	assert.True(ok)
+	assert.Equal("*", value)
+}
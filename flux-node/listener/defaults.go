@@ -34,7 +34,13 @@ func DefaultErrorHandler(webex flux.ServerWebContext, error error) {
 		logger.Trace(webex.RequestId()).Errorw("SERVER:ERROR_HANDLE", "error", err)
 		return
 	}
-	webex.ResponseWriter().Header().Add("X-Writer-Id", "Fx-EWriter")
+	header := webex.ResponseWriter().Header()
+	for k, hv := range serr.Header {
+		for _, v := range hv {
+			header.Add(k, v)
+		}
+	}
+	header.Add("X-Writer-Id", "Fx-EWriter")
 	if err := webex.Write(serr.StatusCode, flux.MIMEApplicationJSON, bytes); nil != err {
 		logger.Trace(webex.RequestId()).Errorw("SERVER:ERROR_HANDLE", "error", err)
 	}
@@ -0,0 +1,187 @@
+package listener
+
+import (
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+const (
+	ConfigKeyRewrites = "rewrites"
+)
+
+// RewriteRule 请求重写规则；匹配条件(Host/Methods/Prefix/Regex)全部满足时，按顺序执行重写动作：
+// 1. Regex+Replace 正则替换路径(Replace为空时只匹配)；2. StripPrefix 删除路径前缀；3. AddPrefix 添加路径前缀；
+// 4. Method 重写方法；5. MethodOverride 按X-HTTP-Method-Override重写方法；6. RewriteHost 重写Host；
+type RewriteRule struct {
+	Host           string   // 匹配Host，支持精确匹配和通配子域名：*.example.com
+	Methods        []string // 匹配请求方法，为空时匹配全部
+	Prefix         string   // 匹配路径前缀
+	Regex          string   // 匹配路径正则表达式
+	Replace        string   // 正则替换模板，支持$1等分组引用
+	StripPrefix    string   // 删除的路径前缀
+	AddPrefix      string   // 添加的路径前缀
+	Method         string   // 重写的请求方法
+	MethodOverride bool     // 是否允许通过X-HTTP-Method-Override重写POST请求方法
+	RewriteHost    string   // 重写的Host
+	Last           bool     // 匹配后不再执行后续规则
+	regex          *regexp.Regexp
+}
+
+// Rewriter 按顺序执行请求重写规则
+type Rewriter struct {
+	rules []RewriteRule
+}
+
+// NewRewriter 创建请求重写器；正则表达式错误时返回错误
+func NewRewriter(rules []RewriteRule) (*Rewriter, error) {
+	compiled := make([]RewriteRule, len(rules))
+	for i, rule := range rules {
+		if rule.Regex != "" {
+			regex, err := regexp.Compile(rule.Regex)
+			if nil != err {
+				return nil, fmt.Errorf("compile rewrite regex: %s, error: %w", rule.Regex, err)
+			}
+			rule.regex = regex
+		}
+		rule.Host = strings.ToLower(rule.Host)
+		rule.Method = strings.ToUpper(rule.Method)
+		for j, m := range rule.Methods {
+			rule.Methods[j] = strings.ToUpper(m)
+		}
+		compiled[i] = rule
+	}
+	return &Rewriter{rules: compiled}, nil
+}
+
+// LoadRewriteRules 从ListenServer配置的rewrites列表加载重写规则
+func LoadRewriteRules(config *flux.Configuration) []RewriteRule {
+	items := config.GetConfigurations(ConfigKeyRewrites)
+	rules := make([]RewriteRule, 0, len(items))
+	for _, item := range items {
+		rules = append(rules, RewriteRule{
+			Host:           item.GetString("host"),
+			Methods:        item.GetStringSlice("methods"),
+			Prefix:         item.GetString("prefix"),
+			Regex:          item.GetString("regex"),
+			Replace:        item.GetString("replace"),
+			StripPrefix:    item.GetString("strip_prefix"),
+			AddPrefix:      item.GetString("add_prefix"),
+			Method:         item.GetString("method"),
+			MethodOverride: item.GetBool("method_override"),
+			RewriteHost:    item.GetString("rewrite_host"),
+			Last:           item.GetBool("last"),
+		})
+	}
+	return rules
+}
+
+// NewRewriteInterceptor 创建在路由之前执行请求重写的拦截器
+func NewRewriteInterceptor(rewriter *Rewriter) flux.WebInterceptor {
+	return func(next flux.WebHandler) flux.WebHandler {
+		return func(webex flux.ServerWebContext) error {
+			rewriter.Rewrite(webex)
+			return next(webex)
+		}
+	}
+}
+
+// Rewrite 对请求执行重写规则
+func (r *Rewriter) Rewrite(webex flux.ServerWebContext) {
+	request := webex.Request()
+	for i := range r.rules {
+		rule := &r.rules[i]
+		method, path, host := request.Method, request.URL.Path, request.Host
+		if !rule.matches(method, path, host) {
+			continue
+		}
+		if rule.regex != nil && rule.Replace != "" {
+			path = rule.regex.ReplaceAllString(path, rule.Replace)
+			if !strings.HasPrefix(path, "/") {
+				path = "/" + path
+			}
+		}
+		if rule.StripPrefix != "" && strings.HasPrefix(path, rule.StripPrefix) {
+			path = path[len(rule.StripPrefix):]
+			if !strings.HasPrefix(path, "/") {
+				path = "/" + path
+			}
+		}
+		if rule.AddPrefix != "" {
+			path = strings.TrimSuffix(rule.AddPrefix, "/") + path
+		}
+		if rule.Method != "" {
+			method = rule.Method
+		}
+		if rule.MethodOverride && method == http.MethodPost {
+			if override := strings.ToUpper(webex.HeaderVar(flux.HeaderXHTTPMethodOverride)); isOverrideMethod(override) {
+				method = override
+			} else if override != "" {
+				logger.Trace(webex.RequestId()).Warnw("SERVER:REWRITE:IGNORE_METHOD_OVERRIDE", "method", override)
+			}
+		}
+		if rule.RewriteHost != "" {
+			request.Host = rule.RewriteHost
+		}
+		if method != request.Method || path != request.URL.Path {
+			logger.Trace(webex.RequestId()).Infow("SERVER:REWRITE",
+				"from", []string{request.Method, request.URL.Path}, "to", []string{method, path})
+			webex.Rewrite(method, path)
+		}
+		if rule.Last {
+			return
+		}
+	}
+}
+
+func (rule *RewriteRule) matches(method, path, host string) bool {
+	if rule.Host != "" && !MatchHost(rule.Host, host) {
+		return false
+	}
+	if len(rule.Methods) > 0 {
+		found := false
+		for _, m := range rule.Methods {
+			if m == method {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if rule.Prefix != "" && !strings.HasPrefix(path, rule.Prefix) {
+		return false
+	}
+	if rule.regex != nil && !rule.regex.MatchString(path) {
+		return false
+	}
+	return true
+}
+
+// isOverrideMethod 检查X-HTTP-Method-Override的值是否为支持的HTTP方法
+func isOverrideMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodPost, http.MethodDelete, http.MethodPut,
+		http.MethodHead, http.MethodOptions, http.MethodPatch, http.MethodTrace:
+		return true
+	default:
+		return false
+	}
+}
+
+// MatchHost 匹配Host，忽略端口；支持精确匹配和通配子域名：*.example.com 匹配 a.example.com，不匹配 example.com
+func MatchHost(pattern, host string) bool {
+	host = strings.ToLower(host)
+	if idx := strings.LastIndexByte(host, ':'); idx > 0 && !strings.HasSuffix(host, "]") {
+		host = host[:idx]
+	}
+	pattern = strings.ToLower(pattern)
+	if strings.HasPrefix(pattern, "*.") {
+		return len(host) > len(pattern)-1 && strings.HasSuffix(host, pattern[1:])
+	}
+	return pattern == host
+}
@@ -0,0 +1,67 @@
+package listener
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/internal"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/labstack/echo/v4"
+	assert2 "github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"testing"
+)
+
+func newRewriteWebContext(method, url string) flux.ServerWebContext {
+	request := httptest.NewRequest(method, url, nil)
+	return internal.NewServeWebContext(echo.New().NewContext(request, httptest.NewRecorder()), "rewrite", nil)
+}
+
+func TestRewriter(t *testing.T) {
+	assert := assert2.New(t)
+	ext.SetLoggerFactory(logger.DefaultFactory)
+	rewriter, err := NewRewriter([]RewriteRule{
+		{Regex: "^/v1/users/([0-9]+)$", Replace: "/api/users/$1", Last: true},
+		{Regex: "^/v2/(.*)$", Replace: "$1", Last: true},
+		{Regex: "^/match-only/", AddPrefix: "/api", Last: true},
+		{Prefix: "/legacy/", StripPrefix: "/legacy", AddPrefix: "/api"},
+		{Host: "*.old.com", RewriteHost: "new.com"},
+		{Methods: []string{"post"}, MethodOverride: true},
+	})
+	assert.NoError(err)
+	cases := []struct {
+		method, url, header    string
+		toMethod, toPath, host string
+	}{
+		{"GET", "http://a.com/v1/users/12", "", "GET", "/api/users/12", "a.com"},
+		{"GET", "http://a.com/legacy/orders", "", "GET", "/api/orders", "a.com"},
+		{"GET", "http://shop.old.com:8080/orders", "", "GET", "/orders", "new.com"},
+		{"GET", "http://old.com/orders", "", "GET", "/orders", "old.com"},
+		{"POST", "http://a.com/orders/1", "delete", "DELETE", "/orders/1", "a.com"},
+		{"POST", "http://a.com/orders/1", "", "POST", "/orders/1", "a.com"},
+		{"POST", "http://a.com/orders/1", "hack", "POST", "/orders/1", "a.com"},
+		{"POST", "http://a.com/orders/1", "connect", "POST", "/orders/1", "a.com"},
+		// 替换后补全路径前缀/；Replace为空时只匹配，不替换路径
+		{"GET", "http://a.com/v2/orders", "", "GET", "/orders", "a.com"},
+		{"GET", "http://a.com/match-only/orders", "", "GET", "/api/match-only/orders", "a.com"},
+	}
+	for _, c := range cases {
+		webex := newRewriteWebContext(c.method, c.url)
+		if c.header != "" {
+			webex.Request().Header.Set(flux.HeaderXHTTPMethodOverride, c.header)
+		}
+		rewriter.Rewrite(webex)
+		assert.Equal(c.toMethod, webex.Method(), c.url)
+		assert.Equal(c.toPath, webex.URL().Path, c.url)
+		assert.Equal(c.host, webex.Request().Host, c.url)
+	}
+	_, err = NewRewriter([]RewriteRule{{Regex: "(bad"}})
+	assert.Error(err)
+}
+
+func TestMatchHost(t *testing.T) {
+	assert := assert2.New(t)
+	assert.True(MatchHost("api.foo.com", "API.foo.com:443"))
+	assert.True(MatchHost("*.foo.com", "a.b.foo.com"))
+	assert.False(MatchHost("*.foo.com", "foo.com"))
+	assert.False(MatchHost("api.foo.com", "api.bar.com"))
+}
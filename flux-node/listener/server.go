@@ -1,6 +1,7 @@
 package listener
 
 import (
+	"fmt"
 	"github.com/bytepowered/flux/flux-node"
 	"github.com/bytepowered/flux/flux-node/ext"
 	fluxpkg "github.com/bytepowered/flux/flux-pkg"
@@ -15,6 +16,7 @@ func New(id string, config *flux.Configuration, wis []flux.WebInterceptor, opts
 	opts = append([]Option{
 		WithErrorHandler(DefaultErrorHandler),
 		WithNotfoundHandler(DefaultNotfoundHandler),
+		WithRewriteRules(LoadRewriteRules(config)),
 		WithInterceptors(wis),
 	}, opts...)
 	return NewWith(id, config, opts...)
@@ -43,6 +45,20 @@ func WithNotfoundHandler(f flux.WebHandler) Option {
 	}
 }
 
+// WithRewriteRules 添加请求重写拦截器，在路由之前执行
+func WithRewriteRules(rules []RewriteRule) Option {
+	return func(server flux.WebListener) {
+		if len(rules) == 0 {
+			return
+		}
+		rewriter, err := NewRewriter(rules)
+		fluxpkg.AssertL(nil == err, func() string {
+			return fmt.Sprintf("invalid rewrite rules, listener-id: %s, error: %s", server.ListenerId(), err)
+		})
+		server.AddInterceptor(NewRewriteInterceptor(rewriter))
+	}
+}
+
 func WithInterceptors(array []flux.WebInterceptor) Option {
 	return WithInterceptor(array...)
 }
@@ -5,7 +5,10 @@ import (
 	"github.com/bytepowered/flux/flux-node/server"
 	_ "github.com/bytepowered/flux/flux-node/transporter/dubbo"
 	_ "github.com/bytepowered/flux/flux-node/transporter/echo"
+	_ "github.com/bytepowered/flux/flux-node/transporter/fixed"
 	_ "github.com/bytepowered/flux/flux-node/transporter/http"
+	_ "github.com/bytepowered/flux/flux-node/transporter/redirect"
+	_ "github.com/bytepowered/flux/flux-node/transporter/static"
 )
 
 import (
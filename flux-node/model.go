@@ -3,6 +3,7 @@ package flux
 import (
 	fluxpkg "github.com/bytepowered/flux/flux-pkg"
 	"github.com/spf13/cast"
+	"sort"
 	"strings"
 	"sync"
 )
@@ -39,6 +40,8 @@ const (
 	ScopeHeader = "HEADER"
 	// 获取Header全部参数
 	ScopeHeaderMap = "HEADER_MAP"
+	// 只从Cookie中读取
+	ScopeCookie = "COOKIE"
 	// 获取Http Attributes的单个参数
 	ScopeAttr = "ATTR"
 	// 获取Http Attributes的Map结果
@@ -47,6 +50,8 @@ const (
 	ScopeBody = "BODY"
 	// 获取Request元数据
 	ScopeRequest = "REQUEST"
+	// 获取客户端TLS证书信息
+	ScopeTLS = "TLS"
 	// 自动查找数据源
 	ScopeAuto = "AUTO"
 )
@@ -64,6 +69,10 @@ const (
 	ProtoGRPC  = "GRPC"
 	ProtoHttp  = "HTTP"
 	ProtoEcho  = "ECHO"
+	// 网关本地处理，不需要后端服务的协议
+	ProtoStatic   = "STATIC"
+	ProtoRedirect = "REDIRECT"
+	ProtoFixed    = "FIXED"
 )
 
 // ServiceAttributes
@@ -76,12 +85,37 @@ const (
 	ServiceAttrTagRpcRetries = "rpcRetries"
 )
 
+// 网关本地处理协议的必要Service属性
+const (
+	ServiceAttrTagStaticRoot       = "staticRoot"       // STATIC：本地文件目录
+	ServiceAttrTagStaticFs         = "staticFs"         // STATIC：注册的文件系统名称
+	ServiceAttrTagRedirectLocation = "redirectLocation" // REDIRECT：重定向地址模板
+	ServiceAttrTagFixedStatus      = "fixedStatus"      // FIXED：响应状态码
+	ServiceAttrTagFixedBody        = "fixedBody"        // FIXED：响应数据
+)
+
 // EndpointAttributes
 const (
-	EndpointAttrTagNotDefined = ""           // 默认的，未定义的属性
-	EndpointAttrTagAuthorize  = "authorize"  // 标识Endpoint访问是否需要授权
-	EndpointAttrTagListenerId = "listenerId" // 标识Endpoint绑定到哪个ListenServer服务
-	EndpointAttrTagBizId      = "bizId"      // 标识Endpoint绑定到业务标识
+	EndpointAttrTagNotDefined    = ""              // 默认的，未定义的属性
+	EndpointAttrTagAuthorize     = "authorize"     // 标识Endpoint访问是否需要授权
+	EndpointAttrTagListenerId    = "listenerId"    // 标识Endpoint绑定到哪个ListenServer服务
+	EndpointAttrTagBizId         = "bizId"         // 标识Endpoint绑定到业务标识
+	EndpointAttrTagShadowService = "shadowService" // 标识Endpoint流量镜像的影子服务ServiceId
+	EndpointAttrTagShadowPercent = "shadowPercent" // 标识Endpoint流量镜像的百分比，取值范围：0-100
+	EndpointAttrTagRouteHost     = "routeHost"     // 标识Endpoint匹配的Host，支持精确匹配和通配子域名：*.example.com
+	EndpointAttrTagRouteMatch    = "routeMatch"    // 标识Endpoint匹配的请求谓词，例如：header:X-Tenant=acme, query:tenant=*
+	EndpointAttrTagClientCert    = "clientCert"    // 标识Endpoint是否要求客户端提供已验证的TLS证书
+	EndpointAttrTagSignature     = "signature"     // 标识Endpoint是否要求请求签名(HMAC)
+)
+
+// Endpoint跨域(CORS)属性；覆盖ListenServer的CORS配置
+const (
+	EndpointAttrTagCORSOrigins       = "corsOrigins"       // 允许的Origin列表，支持精确匹配、通配符和正则(regex:前缀)；定义时启用CORS
+	EndpointAttrTagCORSMethods       = "corsMethods"       // 允许的请求方法列表
+	EndpointAttrTagCORSHeaders       = "corsHeaders"       // 允许的请求Header列表
+	EndpointAttrTagCORSCredentials   = "corsCredentials"   // 是否允许携带凭证
+	EndpointAttrTagCORSMaxAge        = "corsMaxAge"        // 预检请求结果的缓存时间，单位：秒
+	EndpointAttrTagCORSExposeHeaders = "corsExposeHeaders" // 允许客户端读取的响应Header列表
 )
 
 // ArgumentAttributes
@@ -140,6 +174,10 @@ func (a Attribute) GetInt() int {
 	return cast.ToInt(a.Value)
 }
 
+func (a Attribute) GetFloat64() float64 {
+	return cast.ToFloat64(a.Value)
+}
+
 func (a Attribute) GetBool() bool {
 	return cast.ToBool(a.Value)
 }
@@ -226,8 +264,21 @@ func (b Service) RpcRetries() string {
 }
 
 // IsValid 判断服务配置是否有效；Interface+Method不能为空；
+// 网关本地处理的协议，不需要定义后端服务的Interface和Method，但需要定义协议的必要属性：
+// STATIC：staticRoot或staticFs；REDIRECT：redirectLocation；FIXED：fixedStatus或fixedBody
 func (b Service) IsValid() bool {
-	return b.Interface != "" && "" != b.Method
+	switch b.RpcProto() {
+	case ProtoStatic:
+		return b.GetAttr(ServiceAttrTagStaticRoot).GetString() != "" || b.GetAttr(ServiceAttrTagStaticFs).GetString() != ""
+	case ProtoRedirect:
+		return b.GetAttr(ServiceAttrTagRedirectLocation).GetString() != ""
+	case ProtoFixed:
+		_, status := b.GetAttrEx(ServiceAttrTagFixedStatus)
+		_, body := b.GetAttrEx(ServiceAttrTagFixedBody)
+		return status || body
+	default:
+		return b.Interface != "" && "" != b.Method
+	}
 }
 
 // HasArgs 判定是否有参数
@@ -270,6 +321,43 @@ func (e *Endpoint) Authorize() bool {
 	return e.GetAttr(EndpointAttrTagAuthorize).GetBool()
 }
 
+// RequireClientCert 返回Endpoint是否要求客户端提供已验证的TLS证书
+func (e *Endpoint) RequireClientCert() bool {
+	return e.GetAttr(EndpointAttrTagClientCert).GetBool()
+}
+
+// RequireSignature 返回Endpoint是否要求请求签名
+func (e *Endpoint) RequireSignature() bool {
+	return e.GetAttr(EndpointAttrTagSignature).GetBool()
+}
+
+// RouteHosts 返回Endpoint匹配的Host列表，已排序
+func (e *Endpoint) RouteHosts() []string {
+	return e.routeValues(EndpointAttrTagRouteHost, true)
+}
+
+// RouteMatches 返回Endpoint匹配的请求谓词列表，已排序
+func (e *Endpoint) RouteMatches() []string {
+	return e.routeValues(EndpointAttrTagRouteMatch, false)
+}
+
+func (e *Endpoint) routeValues(name string, lower bool) []string {
+	values := make([]string, 0, 2)
+	for _, attr := range e.GetAttrs(name) {
+		for _, v := range attr.GetStringSlice() {
+			if v = strings.TrimSpace(v); v == "" {
+				continue
+			}
+			if lower {
+				v = strings.ToLower(v)
+			}
+			values = append(values, v)
+		}
+	}
+	sort.Strings(values)
+	return values
+}
+
 // Multi version control Endpoint
 type MVCEndpoint struct {
 	versions      map[string]*Endpoint // 各版本数据
@@ -299,11 +387,19 @@ func (m *MVCEndpoint) Lookup(version string) (Endpoint, bool) {
 	if 0 == size {
 		return Endpoint{}, false
 	}
-	if "" == version || 1 == size {
+	if 1 == size {
 		for _, ep := range m.versions {
 			return m.dup(ep), true
 		}
 	}
+	// 未指定版本时，选择版本号最小的Endpoint，保证选择结果稳定
+	if "" == version {
+		for v := range m.versions {
+			if "" == version || v < version {
+				version = v
+			}
+		}
+	}
 	epv, ok := m.versions[version]
 	if !ok {
 		return Endpoint{}, false
@@ -337,6 +433,7 @@ func (m *MVCEndpoint) Random() Endpoint {
 	panic(fluxpkg.AssertMessagePrefix + "<multi-endpoint> must not empty, on query random")
 }
 
+// Endpoints 返回全部版本的Endpoint，按版本号排序
 func (m *MVCEndpoint) Endpoints() []*Endpoint {
 	m.RLock()
 	copies := make([]*Endpoint, 0, len(m.versions))
@@ -344,6 +441,9 @@ func (m *MVCEndpoint) Endpoints() []*Endpoint {
 		copies = append(copies, ep)
 	}
 	m.RUnlock()
+	sort.Slice(copies, func(i, j int) bool {
+		return copies[i].Version < copies[j].Version
+	})
 	return copies
 }
 
@@ -358,3 +458,53 @@ type ServiceEvent struct {
 	EventType EventType
 	Service   Service
 }
+
+// Consumer 网关API调用方定义；API Key仅保存SHA-256哈希值
+type Consumer struct {
+	ConsumerId   string            `json:"consumerId" yaml:"consumerId"`     // 调用方ID
+	Name         string            `json:"name" yaml:"name"`                 // 调用方名称
+	KeyHashes    []string          `json:"keyHashes" yaml:"keyHashes"`       // API Key的SHA-256哈希值(Hex)列表
+	Secrets      []string          `json:"secrets" yaml:"secrets"`           // 请求签名(HMAC)密钥列表，支持多个密钥轮换
+	Applications []string          `json:"applications" yaml:"applications"` // 允许访问的应用；为空时不限制
+	Endpoints    []string          `json:"endpoints" yaml:"endpoints"`       // 允许访问的Endpoint：pattern 或 METHOD#pattern；为空时不限制
+	Quotas       map[string]int64  `json:"quotas" yaml:"quotas"`             // 调用配额，例如：limit, daily
+	Metadata     map[string]string `json:"metadata" yaml:"metadata"`         // 元数据
+	Disabled     bool              `json:"disabled" yaml:"disabled"`         // 是否禁用
+}
+
+func (c Consumer) IsValid() bool {
+	return c.ConsumerId != "" && (len(c.KeyHashes) > 0 || len(c.Secrets) > 0)
+}
+
+// AllowApplication 判断是否允许访问指定应用
+func (c Consumer) AllowApplication(application string) bool {
+	if len(c.Applications) == 0 {
+		return true
+	}
+	for _, app := range c.Applications {
+		if app == "*" || app == application {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowEndpoint 判断是否允许访问指定Endpoint
+func (c Consumer) AllowEndpoint(method, pattern string) bool {
+	if len(c.Endpoints) == 0 {
+		return true
+	}
+	key := strings.ToUpper(method) + "#" + pattern
+	for _, ep := range c.Endpoints {
+		if ep == "*" || ep == pattern || ep == key {
+			return true
+		}
+	}
+	return false
+}
+
+// ConsumerEvent 定义从注册中心接收到的Consumer数据变更
+type ConsumerEvent struct {
+	EventType EventType
+	Consumer  Consumer
+}
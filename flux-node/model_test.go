@@ -188,3 +188,51 @@ type AssertCase struct {
 	Actual   func(endpoint *Endpoint) interface{}
 	Message  string
 }
+
+func TestMVCEndpointLookupDefault(t *testing.T) {
+	assert := assert2.New(t)
+	mvce := NewMVCEndpoint(&Endpoint{Version: "v2"})
+	mvce.Update("v3", &Endpoint{Version: "v3"})
+	mvce.Update("v1", &Endpoint{Version: "v1"})
+	for i := 0; i < 10; i++ {
+		ep, ok := mvce.Lookup("")
+		assert.True(ok)
+		assert.Equal("v1", ep.Version)
+	}
+	ep, ok := mvce.Lookup("v3")
+	assert.True(ok)
+	assert.Equal("v3", ep.Version)
+	_, ok = mvce.Lookup("v4")
+	assert.False(ok)
+	versions := make([]string, 0, 3)
+	for _, ep := range mvce.Endpoints() {
+		versions = append(versions, ep.Version)
+	}
+	assert.Equal([]string{"v1", "v2", "v3"}, versions)
+}
+
+func TestServiceIsValidLocalProtos(t *testing.T) {
+	newService := func(proto string, attrs ...Attribute) Service {
+		attrs = append(attrs, Attribute{Name: ServiceAttrTagRpcProto, Value: proto})
+		return Service{EmbeddedAttributes: EmbeddedAttributes{Attributes: attrs}}
+	}
+	cases := []struct {
+		name    string
+		service Service
+		valid   bool
+	}{
+		{name: "static-root", service: newService(ProtoStatic, Attribute{Name: ServiceAttrTagStaticRoot, Value: "./static"}), valid: true},
+		{name: "static-fs", service: newService(ProtoStatic, Attribute{Name: ServiceAttrTagStaticFs, Value: "embed"}), valid: true},
+		{name: "static-missing", service: newService(ProtoStatic)},
+		{name: "redirect", service: newService(ProtoRedirect, Attribute{Name: ServiceAttrTagRedirectLocation, Value: "/new"}), valid: true},
+		{name: "redirect-missing", service: newService(ProtoRedirect)},
+		{name: "fixed-status", service: newService(ProtoFixed, Attribute{Name: ServiceAttrTagFixedStatus, Value: 204}), valid: true},
+		{name: "fixed-body", service: newService(ProtoFixed, Attribute{Name: ServiceAttrTagFixedBody, Value: "ok"}), valid: true},
+		{name: "fixed-missing", service: newService(ProtoFixed)},
+		{name: "http", service: Service{Interface: "http://a.com", Method: "GET"}, valid: true},
+		{name: "http-missing", service: newService(ProtoHttp)},
+	}
+	for _, c := range cases {
+		assert2.Equal(t, c.valid, c.service.IsValid(), c.name)
+	}
+}
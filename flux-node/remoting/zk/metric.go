@@ -0,0 +1,21 @@
+package zk
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	connectionState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "flux",
+		Subsystem: "zookeeper",
+		Name:      "connection_state",
+		Help:      "Zookeeper session state of retriever",
+	}, []string{"RetrieverId"})
+	sessionEventCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "flux",
+		Subsystem: "zookeeper",
+		Name:      "session_event_total",
+		Help:      "Number of zookeeper session events",
+	}, []string{"RetrieverId", "Event"})
+)
@@ -16,58 +16,141 @@ import (
 	"time"
 )
 
+// 认证方式；客户端不支持SASL(Kerberos)认证，配置为sasl时初始化失败
+const (
+	AuthSchemeDigest = "digest"
+	AuthSchemeSASL   = "sasl"
+)
+
+const (
+	ACLWorld   = "world"
+	ACLCreator = "creator"
+)
+
 func NewZookeeperRetriever(id string) *ZookeeperRetriever {
 	return &ZookeeperRetriever{
 		Id:          id,
-		listenerMap: make(map[string][]remoting.NodeChangedListener),
+		listenerMap: make(map[string]*nodeListeners),
 		quit:        make(chan struct{}),
 	}
 }
 
 type RetrieverConfig struct {
-	ConnTimeout time.Duration
-	RetryMax    int
-	RetryDelay  time.Duration
+	ConnTimeout   time.Duration
+	RetryMax      int
+	RetryDelay    time.Duration
+	RetryDelayMax time.Duration
+	AuthScheme    string
+	Username      string
+	Password      string
+	ACL           string
+	Chroot        string
+}
+
+// nodeListeners 节点的监听函数列表，以及Watch协程状态
+type nodeListeners struct {
+	children  bool
+	active    bool
+	listeners []remoting.NodeChangedListener
 }
 
 type ZookeeperRetriever struct {
 	Id          string
 	conn        *zk.Conn
-	listenerMap map[string][]remoting.NodeChangedListener
+	listenerMap map[string]*nodeListeners
 	listenerMu  sync.RWMutex
 	quit        chan struct{}
 	address     []string
+	acl         []zk.ACL
 	config      RetrieverConfig
 }
 
 // Init 初始化
 func (r *ZookeeperRetriever) Init(config *flux.Configuration) error {
-	addr := config.GetString("address")
-	r.address = strings.Split(addr, ",")
-	if len(r.address) == 0 {
-		return fmt.Errorf("ZK address is required, id: %s", r.Id)
-	}
 	config.SetDefaults(map[string]interface{}{
-		"timeout":     time.Second * 20,
-		"retry-max":   60,
-		"retry-delay": time.Second * 10,
+		"timeout":         time.Second * 20,
+		"retry-max":       60,
+		"retry-delay":     time.Second * 10,
+		"retry-delay-max": time.Minute,
+		"auth-scheme":     AuthSchemeDigest,
 	})
+	// 兼容Zookeeper连接串的Chroot格式：host1:2181,host2:2181/chroot
+	addr, chroot := config.GetString("address"), config.GetString("chroot")
+	if idx := strings.Index(addr, "/"); idx > 0 {
+		addr, chroot = addr[:idx], addr[idx:]
+	}
+	if addr == "" {
+		return fmt.Errorf("ZK address is required, id: %s", r.Id)
+	}
+	r.address = strings.Split(addr, ",")
 	r.config = RetrieverConfig{
-		ConnTimeout: config.GetDuration("timeout"),
+		ConnTimeout:   config.GetDuration("timeout"),
+		RetryMax:      config.GetInt("retry-max"),
+		RetryDelay:    config.GetDuration("retry-delay"),
+		RetryDelayMax: config.GetDuration("retry-delay-max"),
+		AuthScheme:    strings.ToLower(config.GetString("auth-scheme")),
+		Username:      config.GetString("username"),
+		Password:      config.GetString("password"),
+		ACL:           strings.ToLower(config.GetString("acl")),
+		Chroot:        strings.TrimSuffix(chroot, "/"),
+	}
+	if r.config.RetryDelay <= 0 {
+		r.config.RetryDelay = time.Second
+	}
+	if r.config.RetryDelayMax < r.config.RetryDelay {
+		r.config.RetryDelayMax = r.config.RetryDelay
+	}
+	if r.config.Chroot != "" && !strings.HasPrefix(r.config.Chroot, "/") {
+		return fmt.Errorf("ZK chroot must start with '/', id: %s, chroot: %s", r.Id, r.config.Chroot)
+	}
+	switch r.config.AuthScheme {
+	case AuthSchemeDigest:
+		break
+	case AuthSchemeSASL:
+		return fmt.Errorf("ZK auth-scheme(sasl) not supported by client, use digest instead, id: %s", r.Id)
+	default:
+		return fmt.Errorf("ZK auth-scheme not supported, id: %s, scheme: %s", r.Id, r.config.AuthScheme)
+	}
+	if r.config.ACL == "" {
+		if r.hasAuth() {
+			r.config.ACL = ACLCreator
+		} else {
+			r.config.ACL = ACLWorld
+		}
+	}
+	switch r.config.ACL {
+	case ACLWorld:
+		r.acl = zk.WorldACL(zk.PermAll)
+	case ACLCreator:
+		if !r.hasAuth() {
+			return fmt.Errorf("ZK acl(creator) requires username/password, id: %s", r.Id)
+		}
+		r.acl = zk.AuthACL(zk.PermAll)
+	default:
+		return fmt.Errorf("ZK acl not supported, id: %s, acl: %s", r.Id, r.config.ACL)
 	}
 	return nil
 }
 
 // Startup 启动ZK客户端
 func (r *ZookeeperRetriever) Startup() error {
-	r.newLogger().Info("Zookeeper retriever startup")
-	conn, _, err := zk.Connect(r.address, r.config.ConnTimeout,
+	r.newLogger().Infow("Zookeeper retriever startup", "chroot", r.config.Chroot, "acl", r.config.ACL)
+	conn, events, err := zk.Connect(r.address, r.config.ConnTimeout,
 		zk.WithLogger(new(zkLogger)),
 	)
 	if err != nil {
 		return fmt.Errorf("zookeeper connection failed, id: %s, address: %s, err: %w", r.Id, r.address, err)
 	}
 	r.conn = conn
+	// 认证信息由客户端在重连后自动重新提交
+	if r.hasAuth() {
+		auth := []byte(r.config.Username + ":" + r.config.Password)
+		if err := r.conn.AddAuth(r.config.AuthScheme, auth); nil != err {
+			r.conn.Close()
+			return fmt.Errorf("zookeeper add auth failed, id: %s, scheme: %s, err: %w", r.Id, r.config.AuthScheme, err)
+		}
+	}
+	go r.watchSession(events)
 	return nil
 }
 
@@ -79,24 +162,61 @@ func (r *ZookeeperRetriever) Shutdown(ctx context.Context) error {
 	default:
 		r.newLogger().Info("Zookeeper retriever shutdown")
 		close(r.quit)
+		if nil != r.conn {
+			r.conn.Close()
+		}
 	}
 	return nil
 }
 
 // Exists 判定指定Path是否存在。注意Path是完整路径。
 func (r *ZookeeperRetriever) Exists(path string) (bool, error) {
-	b, _, err := r.conn.Exists(path)
+	b, _, err := r.conn.Exists(r.rooted(path))
 	return b, err
 }
 
-// Create 创建指定Path的节点
-func (r *ZookeeperRetriever) Create(path string) error {
-	_, err := r.conn.Create(path, []byte{}, 0, zk.WorldACL(zk.PermAll))
-	return err
+// Create 创建指定Path的节点；父节点不存在时逐级创建。
+func (r *ZookeeperRetriever) Create(nodePath string) error {
+	full := r.rooted(nodePath)
+	parts := strings.Split(strings.Trim(full, "/"), "/")
+	for i := range parts {
+		p := "/" + strings.Join(parts[:i+1], "/")
+		_, err := r.conn.Create(p, []byte{}, 0, r.acl)
+		if nil != err && err != zk.ErrNodeExists {
+			return err
+		}
+	}
+	return nil
+}
+
+// Children 返回指定Path的全部子节点完整路径
+func (r *ZookeeperRetriever) Children(parentNodePath string) ([]string, error) {
+	children, _, err := r.conn.Children(r.rooted(parentNodePath))
+	if nil != err {
+		return nil, err
+	}
+	for i, p := range children {
+		children[i] = path.Join(parentNodePath, p)
+	}
+	return children, nil
+}
+
+// GetData 读取指定Path节点的数据。节点不存在时返回 zk.ErrNoNode
+func (r *ZookeeperRetriever) GetData(nodePath string) ([]byte, error) {
+	data, _, err := r.conn.Get(r.rooted(nodePath))
+	return data, err
+}
+
+// IsWatching 判定指定Path是否已注册监听，并且Watch协程正在运行
+func (r *ZookeeperRetriever) IsWatching(nodePath string) bool {
+	r.listenerMu.RLock()
+	defer r.listenerMu.RUnlock()
+	nl, ok := r.listenerMap[nodePath]
+	return ok && nl.active
 }
 
 func (r *ZookeeperRetriever) AddChildrenNodeChangedListener(groupId, parentNodePath string, nodeChangedListener remoting.NodeChangedListener) error {
-	if init, err := r.setupListener(groupId, parentNodePath, nodeChangedListener); nil != err {
+	if init, err := r.setupListener(groupId, parentNodePath, nodeChangedListener, true); nil != err {
 		return err
 	} else if init {
 		go r.watchChildrenChanged(parentNodePath)
@@ -106,7 +226,7 @@ func (r *ZookeeperRetriever) AddChildrenNodeChangedListener(groupId, parentNodeP
 
 // AddNodeChangedListener 添加指定节点的数据变化监听接口
 func (r *ZookeeperRetriever) AddNodeChangedListener(groupId, nodePath string, dataChangedListener remoting.NodeChangedListener) error {
-	if init, err := r.setupListener(groupId, nodePath, dataChangedListener); nil != err {
+	if init, err := r.setupListener(groupId, nodePath, dataChangedListener, false); nil != err {
 		return err
 	} else if init {
 		go r.watchDataNodeChanged(nodePath)
@@ -114,59 +234,111 @@ func (r *ZookeeperRetriever) AddNodeChangedListener(groupId, nodePath string, da
 	return nil
 }
 
-func (r *ZookeeperRetriever) watchChildrenChanged(parentNodePath string) {
-	r.newLogger().Infow("Zookeeper retriever start watching children", "parent-path", parentNodePath)
-	defer func() {
-		r.newLogger().Infow("Zookeeper retriever stop watching children, purge listeners",
-			"parent-path", parentNodePath)
-		r.listenerMu.Lock()
-		delete(r.listenerMap, parentNodePath)
-		r.listenerMu.Unlock()
-	}()
-	handleChildChanged := func(event remoting.NodeEvent) {
-		r.listenerMu.RLock()
-		listeners, ok := r.listenerMap[parentNodePath]
-		r.listenerMu.RUnlock()
-		if !ok || 0 == len(listeners) {
+// Rewatch 重新启动已注册监听节点的Watch协程，不添加监听函数；返回节点是否已注册监听。
+func (r *ZookeeperRetriever) Rewatch(nodePath string) bool {
+	r.listenerMu.Lock()
+	defer r.listenerMu.Unlock()
+	nl, ok := r.listenerMap[nodePath]
+	if !ok {
+		return false
+	}
+	if !nl.active {
+		nl.active = true
+		r.startWatch(nodePath, nl.children)
+	}
+	return true
+}
+
+// watchSession 监听客户端会话状态。会话过期重建后，重新注册已停止的Watch。
+func (r *ZookeeperRetriever) watchSession(events <-chan zk.Event) {
+	for {
+		select {
+		case <-r.quit:
 			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if event.Type != zk.EventSession {
+				continue
+			}
+			connectionState.WithLabelValues(r.Id).Set(float64(event.State))
+			switch event.State {
+			case zk.StateExpired:
+				r.newLogger().Warnw("Zookeeper retriever session expired")
+				sessionEventCounter.WithLabelValues(r.Id, "expired").Inc()
+			case zk.StateDisconnected:
+				r.newLogger().Warnw("Zookeeper retriever disconnected")
+				sessionEventCounter.WithLabelValues(r.Id, "disconnected").Inc()
+			case zk.StateHasSession:
+				r.newLogger().Infow("Zookeeper retriever session established", "session-id", r.conn.SessionID())
+				sessionEventCounter.WithLabelValues(r.Id, "established").Inc()
+				r.rewatch()
+			}
 		}
-		for _, listener := range listeners {
-			listener(event)
+	}
+}
+
+// rewatch 重新启动已停止的Watch协程
+func (r *ZookeeperRetriever) rewatch() {
+	r.listenerMu.Lock()
+	defer r.listenerMu.Unlock()
+	for nodePath, nl := range r.listenerMap {
+		if nl.active {
+			continue
 		}
+		nl.active = true
+		r.startWatch(nodePath, nl.children)
+	}
+}
+
+func (r *ZookeeperRetriever) startWatch(nodePath string, children bool) {
+	r.newLogger().Infow("Zookeeper retriever rewatch", "node-path", nodePath, "children", children)
+	if children {
+		go r.watchChildrenChanged(nodePath)
+	} else {
+		go r.watchDataNodeChanged(nodePath)
 	}
+}
+
+func (r *ZookeeperRetriever) watchChildrenChanged(parentNodePath string) {
+	r.newLogger().Infow("Zookeeper retriever start watching children", "parent-path", parentNodePath)
+	defer r.deactivate(parentNodePath)
 	cachedChildren := make([]string, 0)
 	retries := 0
 	for {
-		newChildren, _, w, err := r.conn.ChildrenW(parentNodePath)
+		newChildren, _, w, err := r.conn.ChildrenW(r.rooted(parentNodePath))
 		if nil != err {
 			r.newLogger().Infow("Zookeeper retriever watching children",
 				"parent-path", parentNodePath, "error", err)
-			// retry
-			select {
-			case <-r.quit:
+			retries++
+			if !r.awaitRetry(retries) {
 				return
-			case <-time.After(r.config.RetryDelay):
-				retries++
-				r.newLogger().Infow("Zookeeper retriever retry watch children",
-					"parent-path", parentNodePath, "retry", retries)
-				if retries > r.config.RetryMax {
-					return
-				} else {
-					continue
-				}
 			}
+			r.newLogger().Infow("Zookeeper retriever retry watch children",
+				"parent-path", parentNodePath, "retry", retries)
+			continue
 		}
-		// New: notify
-		if len(newChildren) > 0 && len(cachedChildren) == 0 {
-			for _, p := range newChildren {
-				newChild := path.Join(parentNodePath, p)
-				cachedChildren = append(cachedChildren, newChild)
-				handleChildChanged(remoting.NodeEvent{
-					Path:      newChild,
+		retries = 0
+		// 每次重新注册Watch后，都与缓存的子节点比对；以修正会话中断期间丢失的事件
+		for i, p := range newChildren {
+			newChildren[i] = path.Join(parentNodePath, p) // Update full path
+			if !fluxpkg.StringSliceContains(cachedChildren, newChildren[i]) {
+				r.notify(parentNodePath, remoting.NodeEvent{
+					Path:      newChildren[i],
 					EventType: remoting.EventTypeChildAdd,
 				})
 			}
 		}
+		for _, p := range cachedChildren {
+			if !fluxpkg.StringSliceContains(newChildren, p) {
+				r.notify(parentNodePath, remoting.NodeEvent{
+					Path:      p,
+					EventType: remoting.EventTypeChildDelete,
+				})
+			}
+		}
+		cachedChildren = newChildren
 
 		select {
 		case <-r.quit:
@@ -174,61 +346,55 @@ func (r *ZookeeperRetriever) watchChildrenChanged(parentNodePath string) {
 
 		case zkEvent := <-w.EvtCh:
 			r.newLogger().Debugw("Zookeeper retriever receive event", "event", zkEvent)
-			if zkEvent.Type == zk.EventNodeChildrenChanged {
-				newChildren, _, err := r.conn.Children(zkEvent.Path)
-				if nil != err {
-					r.newLogger().Errorw("Zookeeper retriever get children data",
-						"parent-path", parentNodePath, "error", err)
-					return
-				}
-				// Add
-				for i, p := range newChildren {
-					newChildren[i] = path.Join(parentNodePath, p) // Update full path
-					if !fluxpkg.StringSliceContains(cachedChildren, newChildren[i]) {
-						handleChildChanged(remoting.NodeEvent{
-							Path:      newChildren[i],
-							EventType: remoting.EventTypeChildAdd,
-						})
-					}
-				}
-				// Deleted
-				for _, p := range cachedChildren {
-					if !fluxpkg.StringSliceContains(newChildren, p) {
-						handleChildChanged(remoting.NodeEvent{
-							Path:      p,
-							EventType: remoting.EventTypeChildDelete,
-						})
-					}
-				}
-				cachedChildren = newChildren
-			}
 		}
 	}
 }
 
 func (r *ZookeeperRetriever) watchDataNodeChanged(nodePath string) {
 	r.newLogger().Infow("Zookeeper retriever start watching node data", "node-path", nodePath)
-	defer func() {
-		r.newLogger().Infow("Zookeeper retriever stop watching node data, purge listeners",
-			"node-path", nodePath)
-		r.listenerMu.Lock()
-		delete(r.listenerMap, nodePath)
-		r.listenerMu.Unlock()
-	}()
-	inited := false
+	defer r.deactivate(nodePath)
+	const msgErrGetNodeData = "Zookeeper retriever get node data"
+	var (
+		existed   bool
+		lastMzxid int64
+	)
+	retries := 0
 	for {
-		_, _, w, err := r.conn.ExistsW(nodePath)
+		exists, stat, w, err := r.conn.ExistsW(r.rooted(nodePath))
 		if nil != err {
 			r.newLogger().Errorw("Zookeeper retriever watching node data", "node-path", nodePath, "error", err)
-			return
+			retries++
+			if !r.awaitRetry(retries) {
+				return
+			}
+			continue
 		}
-		if !inited {
-			w.EvtCh <- zk.Event{
-				Type:  zk.EventNodeCreated,
-				State: zk.StateUnknown,
-				Path:  nodePath,
+		retries = 0
+		// 每次重新注册Watch后，都与上次的节点版本比对；以修正会话中断期间丢失的事件
+		switch {
+		case exists && (!existed || stat.Mzxid != lastMzxid):
+			data, dstat, err := r.conn.Get(r.rooted(nodePath))
+			if nil != err {
+				// 节点在读取前被删除，由Watch事件触发下一轮比对
+				r.newLogger().Warnw(msgErrGetNodeData, "node-path", nodePath, "error", err)
+				break
+			}
+			eventType := remoting.EventType(remoting.EventTypeNodeUpdate)
+			if !existed {
+				eventType = remoting.EventTypeNodeAdd
 			}
-			inited = true
+			existed, lastMzxid = true, dstat.Mzxid
+			r.notify(nodePath, remoting.NodeEvent{
+				Path:      nodePath,
+				EventType: eventType,
+				Data:      data,
+			})
+		case !exists && existed:
+			existed, lastMzxid = false, 0
+			r.notify(nodePath, remoting.NodeEvent{
+				Path:      nodePath,
+				EventType: remoting.EventTypeNodeDelete,
+			})
 		}
 		select {
 		case <-r.quit:
@@ -236,52 +402,55 @@ func (r *ZookeeperRetriever) watchDataNodeChanged(nodePath string) {
 
 		case zkEvent := <-w.EvtCh:
 			r.newLogger().Debugw("Zookeeper retriever receive data event", "event", zkEvent)
-			var (
-				eventType remoting.EventType
-				eventData []byte
-			)
-			r.listenerMu.RLock()
-			listeners, ok := r.listenerMap[zkEvent.Path]
-			r.listenerMu.RUnlock()
-			if !ok || 0 == len(listeners) {
-				continue
-			}
-			const msgErrGetNodeData = "Zookeeper retriever get node data"
-			switch zkEvent.Type {
-			case zk.EventNodeDataChanged:
-				data, _, err := r.conn.Get(zkEvent.Path)
-				if nil != err {
-					r.newLogger().Errorw(msgErrGetNodeData, "node-path", nodePath, "error", err)
-					return
-				}
-				eventType = remoting.EventTypeNodeUpdate
-				eventData = data
-			case zk.EventNodeCreated:
-				data, _, err := r.conn.Get(zkEvent.Path)
-				if nil != err {
-					r.newLogger().Errorf(msgErrGetNodeData, "node-path", nodePath, "error", err)
-					return
-				}
-				eventType = remoting.EventTypeNodeAdd
-				eventData = data
-			case zk.EventNodeDeleted:
-				eventType = remoting.EventTypeNodeDelete
-			default:
-				continue
-			}
-			event := remoting.NodeEvent{
-				Path:      zkEvent.Path,
-				EventType: eventType,
-				Data:      eventData,
-			}
-			for _, listener := range listeners {
-				listener(event)
-			}
 		}
 	}
 }
 
-func (r *ZookeeperRetriever) setupListener(groupId, nodeKey string, listener remoting.NodeChangedListener) (bool, error) {
+// awaitRetry 按指数退避等待重试；返回false表示已停止或超过最大重试次数。
+func (r *ZookeeperRetriever) awaitRetry(retries int) bool {
+	if r.config.RetryMax > 0 && retries > r.config.RetryMax {
+		return false
+	}
+	delay := r.config.RetryDelay
+	for i := 1; i < retries && delay < r.config.RetryDelayMax; i++ {
+		delay *= 2
+	}
+	if delay > r.config.RetryDelayMax {
+		delay = r.config.RetryDelayMax
+	}
+	select {
+	case <-r.quit:
+		return false
+	case <-time.After(delay):
+		return true
+	}
+}
+
+// deactivate 标记Watch协程已停止；保留监听函数，以便会话重建后重新注册。
+func (r *ZookeeperRetriever) deactivate(nodePath string) {
+	r.newLogger().Infow("Zookeeper retriever stop watching", "node-path", nodePath)
+	r.listenerMu.Lock()
+	if nl, ok := r.listenerMap[nodePath]; ok {
+		nl.active = false
+	}
+	r.listenerMu.Unlock()
+}
+
+func (r *ZookeeperRetriever) notify(nodePath string, event remoting.NodeEvent) {
+	r.listenerMu.RLock()
+	nl, ok := r.listenerMap[nodePath]
+	var listeners []remoting.NodeChangedListener
+	if ok {
+		listeners = make([]remoting.NodeChangedListener, len(nl.listeners))
+		copy(listeners, nl.listeners)
+	}
+	r.listenerMu.RUnlock()
+	for _, listener := range listeners {
+		listener(event)
+	}
+}
+
+func (r *ZookeeperRetriever) setupListener(groupId, nodeKey string, listener remoting.NodeChangedListener, children bool) (bool, error) {
 	if groupId != "" {
 		r.newLogger().Warnw("Zookeeper retriever not support groupId", "groupId", groupId)
 	}
@@ -293,15 +462,36 @@ func (r *ZookeeperRetriever) setupListener(groupId, nodeKey string, listener rem
 	}
 	r.listenerMu.Lock()
 	defer r.listenerMu.Unlock()
-	if ls, ok := r.listenerMap[nodeKey]; ok {
-		r.listenerMap[nodeKey] = append(ls, listener)
-		return false, nil
+	if nl, ok := r.listenerMap[nodeKey]; ok {
+		nl.listeners = append(nl.listeners, listener)
+		if nl.active {
+			return false, nil
+		}
+		// Watch协程已停止时，重新启动；只重启Watch而不添加监听函数时，使用Rewatch
+		nl.active = true
+		return true, nil
 	} else {
-		r.listenerMap[nodeKey] = []remoting.NodeChangedListener{listener}
+		r.listenerMap[nodeKey] = &nodeListeners{
+			children:  children,
+			active:    true,
+			listeners: []remoting.NodeChangedListener{listener},
+		}
 		return true, nil
 	}
 }
 
+func (r *ZookeeperRetriever) hasAuth() bool {
+	return r.config.Username != "" || r.config.Password != ""
+}
+
+// rooted 返回添加Chroot前缀的实际路径
+func (r *ZookeeperRetriever) rooted(nodePath string) string {
+	if r.config.Chroot == "" {
+		return nodePath
+	}
+	return path.Join(r.config.Chroot, nodePath)
+}
+
 func (r *ZookeeperRetriever) newLogger() *zap.SugaredLogger {
 	return logger.NewWith("id", r.Id, "address", r.address)
 }
@@ -0,0 +1,73 @@
+package zk
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/bytepowered/flux/flux-node/remoting"
+	assert2 "github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRetrieverInit(t *testing.T) {
+	assert := assert2.New(t)
+	ext.SetLoggerFactory(logger.DefaultFactory)
+	cases := []struct {
+		name     string
+		settings map[string]interface{}
+		err      bool
+	}{
+		{name: "default", settings: map[string]interface{}{}},
+		{name: "digest", settings: map[string]interface{}{"auth-scheme": "DIGEST", "username": "u", "password": "p"}},
+		{name: "sasl", settings: map[string]interface{}{"auth-scheme": "sasl"}, err: true},
+		{name: "unknown", settings: map[string]interface{}{"auth-scheme": "kerberos"}, err: true},
+		{name: "creator-without-auth", settings: map[string]interface{}{"acl": "creator"}, err: true},
+		{name: "chroot", settings: map[string]interface{}{"chroot": "flux"}, err: true},
+	}
+	for _, c := range cases {
+		config := flux.NewConfiguration("zookeeper.init." + c.name)
+		config.Set("address", "127.0.0.1:2181")
+		for k, v := range c.settings {
+			config.Set(k, v)
+		}
+		err := NewZookeeperRetriever(c.name).Init(config)
+		assert.Equal(c.err, err != nil, c.name)
+	}
+	// 默认配置
+	r := NewZookeeperRetriever("defaults")
+	config := flux.NewConfiguration("zookeeper.init.defaults")
+	config.Set("address", "127.0.0.1:2181/flux/")
+	assert.NoError(r.Init(config))
+	assert.Equal(10*time.Second, r.config.RetryDelay)
+	assert.Equal(time.Minute, r.config.RetryDelayMax)
+	assert.Equal(AuthSchemeDigest, r.config.AuthScheme)
+	assert.Equal(ACLWorld, r.config.ACL)
+	assert.Equal("/flux", r.config.Chroot)
+	assert.Equal([]string{"127.0.0.1:2181"}, r.address)
+}
+
+func TestRetrieverReactivateListener(t *testing.T) {
+	assert := assert2.New(t)
+	ext.SetLoggerFactory(logger.DefaultFactory)
+	r := NewZookeeperRetriever("test")
+	counts := make([]int, 2)
+	first := func(remoting.NodeEvent) { counts[0]++ }
+	second := func(remoting.NodeEvent) { counts[1]++ }
+	init, err := r.setupListener("", "/flux/a", first, false)
+	assert.NoError(err)
+	assert.True(init)
+	assert.True(r.IsWatching("/flux/a"))
+	// 未注册的节点，不能重新启动Watch；已在监听的节点，不重复启动
+	assert.False(r.Rewatch("/flux/b"))
+	assert.True(r.Rewatch("/flux/a"))
+	// Watch协程停止后，需要重新注册；新添加的监听函数同样生效
+	r.deactivate("/flux/a")
+	assert.False(r.IsWatching("/flux/a"))
+	init, err = r.setupListener("", "/flux/a", second, false)
+	assert.NoError(err)
+	assert.True(init)
+	assert.True(r.IsWatching("/flux/a"))
+	r.notify("/flux/a", remoting.NodeEvent{Path: "/flux/a"})
+	assert.Equal([]int{1, 1}, counts)
+}
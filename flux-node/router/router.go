@@ -0,0 +1,312 @@
+package router
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Param 动态路径参数
+type Param struct {
+	Key   string
+	Value string
+}
+
+// Params 动态路径参数列表
+type Params []Param
+
+// Get 返回指定名称的参数值
+func (ps Params) Get(name string) string {
+	for _, p := range ps {
+		if p.Key == name {
+			return p.Value
+		}
+	}
+	return ""
+}
+
+// Values 转换为url.Values
+func (ps Params) Values() url.Values {
+	out := make(url.Values, len(ps))
+	for _, p := range ps {
+		out.Set(p.Key, p.Value)
+	}
+	return out
+}
+
+// Route 路由定义
+type Route struct {
+	Method  string
+	Pattern string
+	Value   interface{}
+	params  []string
+}
+
+type nodeKind uint8
+
+const (
+	kindStatic nodeKind = iota
+	kindParam
+	kindWildcard
+)
+
+// node 路由树节点；静态子节点以公共前缀压缩存储，动态参数和通配符节点以路径段为单位。
+type node struct {
+	prefix   string
+	statics  []*node
+	param    *node
+	wildcard *node
+	routes   map[string]*Route
+}
+
+// Router 不可变的路由表，支持动态路径参数 {name}/:name 和尾部通配符 */*name；
+// 匹配优先级：静态路径 > 动态参数 > 通配符。路由表构建后只读，可以无锁并发查找。
+type Router struct {
+	root *node
+	size int
+}
+
+// Builder 用于构建Router；Build之后不可再添加路由。
+type Builder struct {
+	root  *node
+	size  int
+	built bool
+}
+
+func NewBuilder() *Builder {
+	return &Builder{root: &node{}}
+}
+
+// Empty 返回一个空路由表
+func Empty() *Router {
+	return &Router{root: &node{}}
+}
+
+// Add 添加路由；相同Method和Pattern的路由重复添加时，返回错误。
+func (b *Builder) Add(method, pattern string, value interface{}) error {
+	if b.built {
+		return fmt.Errorf("router is built, method: %s, pattern: %s", method, pattern)
+	}
+	if method == "" || !strings.HasPrefix(pattern, "/") {
+		return fmt.Errorf("illegal route, method: %s, pattern: %s", method, pattern)
+	}
+	method = strings.ToUpper(method)
+	segments := strings.Split(pattern[1:], "/")
+	params := make([]string, 0, 2)
+	current := b.root
+	static := ""
+	for i, seg := range segments {
+		kind, name := parseSegment(seg)
+		switch kind {
+		case kindStatic:
+			static += "/" + seg
+			continue
+		case kindWildcard:
+			if i != len(segments)-1 {
+				return fmt.Errorf("wildcard must be the last segment, pattern: %s", pattern)
+			}
+		}
+		// 动态节点之前的静态路径，包含分隔符'/'
+		current = current.insertStatic(static + "/")
+		static = ""
+		params = append(params, name)
+		if kind == kindParam {
+			if current.param == nil {
+				current.param = &node{}
+			}
+			current = current.param
+		} else {
+			if current.wildcard == nil {
+				current.wildcard = &node{}
+			}
+			current = current.wildcard
+		}
+	}
+	if static != "" {
+		current = current.insertStatic(static)
+	}
+	if current.routes == nil {
+		current.routes = make(map[string]*Route, 1)
+	}
+	if exist, ok := current.routes[method]; ok {
+		return fmt.Errorf("duplicated route, method: %s, pattern: %s, exists: %s", method, pattern, exist.Pattern)
+	}
+	current.routes[method] = &Route{Method: method, Pattern: pattern, Value: value, params: params}
+	b.size++
+	return nil
+}
+
+// Build 构建不可变的路由表
+func (b *Builder) Build() *Router {
+	b.built = true
+	return &Router{root: b.root, size: b.size}
+}
+
+// Len 返回路由数量
+func (r *Router) Len() int {
+	return r.size
+}
+
+// Find 查找路由；返回匹配的路由和动态路径参数；
+// 路径匹配但Method不匹配时，返回nil路由和该路径允许的Method列表。
+func (r *Router) Find(method, path string) (*Route, Params, []string) {
+	method = strings.ToUpper(method)
+	values := make([]string, 0, 2)
+	var matched *node
+	found := r.root.search(path, method, &values, &matched)
+	if found != nil {
+		route := found.routes[method]
+		params := make(Params, len(route.params))
+		for i, name := range route.params {
+			params[i] = Param{Key: name, Value: values[i]}
+		}
+		return route, params, nil
+	}
+	if matched == nil {
+		return nil, nil, nil
+	}
+	allowed := make([]string, 0, len(matched.routes))
+	for m := range matched.routes {
+		allowed = append(allowed, m)
+	}
+	sort.Strings(allowed)
+	return nil, nil, allowed
+}
+
+// Routes 返回全部路由
+func (r *Router) Routes() []Route {
+	out := make([]Route, 0, r.size)
+	r.root.walk(func(route *Route) {
+		out = append(out, *route)
+	})
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Pattern != out[j].Pattern {
+			return out[i].Pattern < out[j].Pattern
+		}
+		return out[i].Method < out[j].Method
+	})
+	return out
+}
+
+// search 按优先级回溯查找；matched 记录首个路径匹配但Method不匹配的节点
+func (n *node) search(path, method string, values *[]string, matched **node) *node {
+	if path == "" {
+		if len(n.routes) > 0 {
+			if _, ok := n.routes[method]; ok {
+				return n
+			}
+			if *matched == nil {
+				*matched = n
+			}
+		}
+		// 通配符允许匹配空路径
+		if n.wildcard != nil {
+			*values = append(*values, "")
+			if found := n.wildcard.search("", method, values, matched); found != nil {
+				return found
+			}
+			*values = (*values)[:len(*values)-1]
+		}
+		return nil
+	}
+	// 静态节点
+	for _, child := range n.statics {
+		if strings.HasPrefix(path, child.prefix) {
+			if found := child.search(path[len(child.prefix):], method, values, matched); found != nil {
+				return found
+			}
+			break
+		}
+	}
+	// 动态参数节点：匹配一个非空路径段
+	if n.param != nil {
+		end := strings.IndexByte(path, '/')
+		if end < 0 {
+			end = len(path)
+		}
+		if end > 0 {
+			*values = append(*values, path[:end])
+			if found := n.param.search(path[end:], method, values, matched); found != nil {
+				return found
+			}
+			*values = (*values)[:len(*values)-1]
+		}
+	}
+	// 通配符节点：匹配剩余路径
+	if n.wildcard != nil {
+		*values = append(*values, path)
+		if found := n.wildcard.search("", method, values, matched); found != nil {
+			return found
+		}
+		*values = (*values)[:len(*values)-1]
+	}
+	return nil
+}
+
+// insertStatic 插入静态路径，按公共前缀拆分节点
+func (n *node) insertStatic(path string) *node {
+	if path == "" {
+		return n
+	}
+	for i, child := range n.statics {
+		common := commonPrefix(child.prefix, path)
+		if common == 0 {
+			continue
+		}
+		if common < len(child.prefix) {
+			// 拆分节点
+			split := &node{prefix: child.prefix[:common], statics: []*node{child}}
+			child.prefix = child.prefix[common:]
+			n.statics[i] = split
+			child = split
+		}
+		return child.insertStatic(path[common:])
+	}
+	child := &node{prefix: path}
+	n.statics = append(n.statics, child)
+	return child
+}
+
+func (n *node) walk(fn func(*Route)) {
+	for _, route := range n.routes {
+		fn(route)
+	}
+	for _, child := range n.statics {
+		child.walk(fn)
+	}
+	if n.param != nil {
+		n.param.walk(fn)
+	}
+	if n.wildcard != nil {
+		n.wildcard.walk(fn)
+	}
+}
+
+func parseSegment(seg string) (nodeKind, string) {
+	switch {
+	case strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") && len(seg) > 2:
+		return kindParam, seg[1 : len(seg)-1]
+	case strings.HasPrefix(seg, ":") && len(seg) > 1:
+		return kindParam, seg[1:]
+	case strings.HasPrefix(seg, "*"):
+		if len(seg) > 1 {
+			return kindWildcard, seg[1:]
+		}
+		return kindWildcard, "*"
+	default:
+		return kindStatic, seg
+	}
+}
+
+func commonPrefix(a, b string) int {
+	max := len(a)
+	if len(b) < max {
+		max = len(b)
+	}
+	i := 0
+	for i < max && a[i] == b[i] {
+		i++
+	}
+	return i
+}
@@ -0,0 +1,73 @@
+package router
+
+import (
+	assert2 "github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRouterFind(t *testing.T) {
+	assert := assert2.New(t)
+	b := NewBuilder()
+	routes := []struct {
+		method, pattern string
+	}{
+		{"GET", "/"},
+		{"GET", "/api/users"},
+		{"POST", "/api/users"},
+		{"GET", "/api/users/{id}"},
+		{"GET", "/api/users/me"},
+		{"GET", "/api/users/:uid/orders/{oid}"},
+		{"GET", "/api/user"},
+		{"GET", "/static/*path"},
+		{"GET", "/api/*"},
+	}
+	for _, r := range routes {
+		assert.NoError(b.Add(r.method, r.pattern, r.method+" "+r.pattern))
+	}
+	assert.Error(b.Add("GET", "/api/users/{name}", nil))
+	assert.Error(b.Add("GET", "/bad/*/tail", nil))
+	rt := b.Build()
+	assert.Equal(len(routes), rt.Len())
+	assert.Error(b.Add("GET", "/late", nil))
+	cases := []struct {
+		method, path, value string
+		params              map[string]string
+	}{
+		{"GET", "/", "GET /", nil},
+		{"get", "/api/users", "GET /api/users", nil},
+		{"POST", "/api/users", "POST /api/users", nil},
+		{"GET", "/api/users/me", "GET /api/users/me", nil},
+		{"GET", "/api/users/123", "GET /api/users/{id}", map[string]string{"id": "123"}},
+		{"GET", "/api/users/12/orders/34", "GET /api/users/:uid/orders/{oid}", map[string]string{"uid": "12", "oid": "34"}},
+		{"GET", "/api/user", "GET /api/user", nil},
+		{"GET", "/static/css/app.css", "GET /static/*path", map[string]string{"path": "css/app.css"}},
+		{"GET", "/api/users/12/unknown", "GET /api/*", map[string]string{"*": "users/12/unknown"}},
+		{"GET", "/api/", "GET /api/*", map[string]string{"*": ""}},
+	}
+	for _, c := range cases {
+		route, params, _ := rt.Find(c.method, c.path)
+		if assert.NotNil(route, c.path) {
+			assert.Equal(c.value, route.Value, c.path)
+			for k, v := range c.params {
+				assert.Equal(v, params.Get(k), c.path)
+			}
+			assert.Equal(len(c.params), len(params), c.path)
+		}
+	}
+	// Method不匹配
+	route, _, allowed := rt.Find("DELETE", "/api/users")
+	assert.Nil(route)
+	assert.Equal([]string{"GET", "POST"}, allowed)
+	// 不存在
+	route, _, allowed = rt.Find("GET", "/none")
+	assert.Nil(route)
+	assert.Empty(allowed)
+	assert.Equal(len(routes), len(rt.Routes()))
+}
+
+func TestRouterEmpty(t *testing.T) {
+	route, params, allowed := Empty().Find("GET", "/")
+	assert2.Nil(t, route)
+	assert2.Nil(t, params)
+	assert2.Nil(t, allowed)
+}
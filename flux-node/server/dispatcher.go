@@ -9,17 +9,24 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"reflect"
 	"sort"
+	"sync/atomic"
 	"time"
 )
 
+const (
+	// 等待执行中请求完成的检查间隔
+	inflightAwaitInterval = 10 * time.Millisecond
+)
+
 type Dispatcher struct {
-	metrics *Metrics
-	hooks   []flux.PrepareHookFunc
+	metrics  *Metrics
+	hooks    []flux.PrepareHookFunc
+	inflight int64 // 正在执行Route的请求数量
 }
 
 func NewDispatcher() *Dispatcher {
 	return &Dispatcher{
-		metrics: NewMetrics(),
+		metrics: DefaultMetrics(),
 		hooks:   make([]flux.PrepareHookFunc, 0, 4),
 	}
 }
@@ -98,8 +105,11 @@ func (r *Dispatcher) Startup() error {
 	return nil
 }
 
+// Shutdown 按顺序执行Shutdown Hook，关闭Transporter和Discovery等资源；需在请求排空后调用
 func (r *Dispatcher) Shutdown(ctx context.Context) error {
-	for _, shutdown := range sortedShutdown(ext.ShutdownHooks()) {
+	hooks := sortedShutdown(ext.ShutdownHooks())
+	logger.Infow("SERVER:SHUTDOWN:HOOKS", "hooks", len(hooks), "inflight", r.Inflight())
+	for _, shutdown := range hooks {
 		if err := shutdown.Shutdown(ctx); nil != err {
 			return err
 		}
@@ -107,7 +117,31 @@ func (r *Dispatcher) Shutdown(ctx context.Context) error {
 	return nil
 }
 
+// Inflight 返回正在执行Route的请求数量
+func (r *Dispatcher) Inflight() int64 {
+	return atomic.LoadInt64(&r.inflight)
+}
+
+// AwaitInflight 等待正在执行的请求全部完成，或者Context超时；返回未完成的请求数量
+func (r *Dispatcher) AwaitInflight(ctx context.Context) int64 {
+	ticker := time.NewTicker(inflightAwaitInterval)
+	defer ticker.Stop()
+	for {
+		count := r.Inflight()
+		if count <= 0 {
+			return 0
+		}
+		select {
+		case <-ctx.Done():
+			return count
+		case <-ticker.C:
+		}
+	}
+}
+
 func (r *Dispatcher) Route(ctx *flux.Context) *flux.ServeError {
+	atomic.AddInt64(&r.inflight, 1)
+	defer atomic.AddInt64(&r.inflight, -1)
 	// 统计异常
 	doMetricEndpointFunc := func(err *flux.ServeError) *flux.ServeError {
 		// Access Counter: ProtoName, Interface, Method
@@ -21,4 +21,5 @@ func init() {
 	// Endpoint discovery
 	ext.RegisterEndpointDiscovery(discovery.NewZookeeperServiceWith(discovery.ZookeeperId))
 	ext.RegisterEndpointDiscovery(discovery.NewResourceServiceWith(discovery.ResourceId))
+	ext.RegisterEndpointDiscovery(discovery.NewDubboMetadataServiceWith(discovery.DubboMetadataId))
 }
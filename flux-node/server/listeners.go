@@ -0,0 +1,304 @@
+package server
+
+import (
+	goctx "context"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/bytepowered/flux/flux-node/listener"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ListenServer的运行状态
+const (
+	ListenerStatusCreated = "created"
+	ListenerStatusRunning = "running"
+	ListenerStatusStopped = "stopped"
+	ListenerStatusFailed  = "failed"
+)
+
+const (
+	// 运行时启动ListenServer，等待端口绑定结果的时间
+	listenerStartWait = 200 * time.Millisecond
+)
+
+// ListenerHealth ListenServer的健康状态
+type ListenerHealth struct {
+	Id     string    `json:"id"`
+	Status string    `json:"status"`
+	Error  string    `json:"error,omitempty"`
+	Since  time.Time `json:"since"`
+}
+
+// listenerState ListenServer的运行状态；由listenerMu保护
+type listenerState struct {
+	listener flux.WebListener
+	status   string
+	err      error
+	since    time.Time
+	stopping bool
+	serial   int // 每次启动递增，用于忽略上次运行的退出结果
+}
+
+func (st *listenerState) set(status string, err error) {
+	st.status, st.err, st.since = status, err, time.Now()
+}
+
+// WithConfigWebListeners 从配置 listeners.<id> 加载除default/admin以外的ListenServer
+func WithConfigWebListeners() Option {
+	return func(bs *BootstrapServer) {
+		for _, id := range LoadWebListenerIds() {
+			if _, ok := bs.WebListenerById(id); ok {
+				continue
+			}
+			config := LoadWebListenerConfig(id)
+			if IsDisabled(config) {
+				logger.Infow("SERVER:LISTENER:DISABLED", "listener-id", id)
+				continue
+			}
+			bs.AddWebListener(id, listener.New(id, config, nil))
+		}
+	}
+}
+
+// LoadWebListenerIds 返回配置中定义的全部ListenServer的ID
+func LoadWebListenerIds() []string {
+	ids := make([]string, 0, 4)
+	for id := range flux.NewConfiguration(flux.NamespaceWebListeners).ToStringMap() {
+		ids = append(ids, strings.ToLower(id))
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// WebListenerHealth 返回全部ListenServer的健康状态
+func (s *BootstrapServer) WebListenerHealth() []ListenerHealth {
+	s.listenerMu.RLock()
+	defer s.listenerMu.RUnlock()
+	out := make([]ListenerHealth, 0, len(s.states))
+	for id, st := range s.states {
+		health := ListenerHealth{Id: id, Status: st.status, Since: st.since}
+		if st.err != nil {
+			health.Error = st.err.Error()
+		}
+		out = append(out, health)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Id < out[j].Id
+	})
+	return out
+}
+
+// CreateWebListener 运行时根据配置 listeners.<id> 创建、初始化并启动ListenServer
+func (s *BootstrapServer) CreateWebListener(listenerID string) error {
+	id := strings.ToLower(listenerID)
+	if _, ok := s.WebListenerById(id); ok {
+		return fmt.Errorf("web listener already exists, listener-id: %s", id)
+	}
+	config := LoadWebListenerConfig(id)
+	if len(config.ToStringMap()) == 0 {
+		return fmt.Errorf("web listener config not found, listener-id: %s", id)
+	}
+	wl := listener.New(id, config, nil)
+	if err := wl.Init(config); nil != err {
+		return err
+	}
+	s.AddWebListener(id, wl)
+	return s.StartWebListener(id)
+}
+
+// StartWebListener 启动指定ID的ListenServer；端口绑定失败时返回错误。
+// 运行时启动的ListenServer异常退出时，不停止网关，退出错误记录在ListenServer的运行状态中。
+func (s *BootstrapServer) StartWebListener(listenerID string) error {
+	exit, err := s.startListener(strings.ToLower(listenerID), nil)
+	if err != nil {
+		return err
+	}
+	select {
+	case err := <-exit:
+		return err
+	case <-time.After(listenerStartWait):
+		return nil
+	}
+}
+
+// StopWebListener 停止指定ID的ListenServer；已停止的ListenServer可以重新启动
+func (s *BootstrapServer) StopWebListener(ctx goctx.Context, listenerID string) error {
+	id := strings.ToLower(listenerID)
+	s.listenerMu.Lock()
+	st, ok := s.states[id]
+	if !ok {
+		s.listenerMu.Unlock()
+		return fmt.Errorf("web listener not found, listener-id: %s", id)
+	}
+	if st.status != ListenerStatusRunning {
+		s.listenerMu.Unlock()
+		return nil
+	}
+	st.stopping = true
+	s.listenerMu.Unlock()
+	logger.Infow("SERVER:LISTENER:STOP", "listener-id", id)
+	err := st.listener.Close(ctx)
+	s.listenerMu.Lock()
+	if err != nil {
+		st.set(ListenerStatusFailed, err)
+	} else {
+		st.set(ListenerStatusStopped, nil)
+	}
+	s.listenerMu.Unlock()
+	return err
+}
+
+// RemoveWebListener 停止并删除指定ID的ListenServer；绑定的Endpoint路由保留，重新添加后恢复。
+func (s *BootstrapServer) RemoveWebListener(ctx goctx.Context, listenerID string) error {
+	id := strings.ToLower(listenerID)
+	if id == ListenerIdDefault || id == ListenServerIdAdmin {
+		return fmt.Errorf("web listener is not removable, listener-id: %s", id)
+	}
+	if err := s.StopWebListener(ctx, id); nil != err {
+		return err
+	}
+	s.listenerMu.Lock()
+	delete(s.listener, id)
+	delete(s.states, id)
+	s.listenerMu.Unlock()
+	s.routeMu.Lock()
+	delete(s.routers, id)
+	s.routeMu.Unlock()
+	logger.Infow("SERVER:LISTENER:REMOVE", "listener-id", id)
+	return nil
+}
+
+// startListener 异步启动ListenServer，返回其退出结果的Channel；
+// 非主动停止的退出错误记录到运行状态，并发送到errch(如果非nil)以通知BootstrapServer。
+func (s *BootstrapServer) startListener(id string, errch chan<- error) (<-chan error, error) {
+	s.listenerMu.Lock()
+	st, ok := s.states[id]
+	if !ok {
+		s.listenerMu.Unlock()
+		return nil, fmt.Errorf("web listener not found, listener-id: %s", id)
+	}
+	if st.status == ListenerStatusRunning {
+		s.listenerMu.Unlock()
+		return nil, fmt.Errorf("web listener is running, listener-id: %s", id)
+	}
+	st.stopping = false
+	st.serial++
+	serial := st.serial
+	st.set(ListenerStatusRunning, nil)
+	s.listenerMu.Unlock()
+	logger.Infow("SERVER:START:LISTENER:START", "listener-id", id)
+	exit := make(chan error, 1)
+	go func() {
+		err := st.listener.Listen()
+		s.listenerMu.Lock()
+		stopping := st.stopping || st.serial != serial
+		if stopping {
+			err = nil
+		} else {
+			if err == nil {
+				err = fmt.Errorf("web listener exited, listener-id: %s", id)
+			}
+			st.set(ListenerStatusFailed, err)
+		}
+		s.listenerMu.Unlock()
+		if err != nil {
+			logger.Errorw("SERVER:START:LISTENER:FAILED", "listener-id", id, "error", err)
+		} else {
+			logger.Infow("SERVER:START:LISTENER:STOP", "listener-id", id)
+		}
+		exit <- err
+		if err != nil && errch != nil {
+			errch <- err
+		}
+	}()
+	return exit, nil
+}
+
+// ListenersHandler 返回全部ListenServer的健康状态
+func (s *BootstrapServer) ListenersHandler(webex flux.ServerWebContext) error {
+	return sendAdminJSON(webex, http.StatusOK, s.WebListenerHealth())
+}
+
+// HealthHandler 返回网关的健康状态；停机排空阶段返回503
+func (s *BootstrapServer) HealthHandler(webex flux.ServerWebContext) error {
+	if s.IsDraining() {
+		return sendAdminJSON(webex, http.StatusServiceUnavailable, map[string]interface{}{
+			"status": "draining", "inflight": s.dispatcher.Inflight(),
+		})
+	}
+	return sendAdminJSON(webex, http.StatusOK, map[string]interface{}{
+		"status": "up", "inflight": s.dispatcher.Inflight(),
+	})
+}
+
+// ListenerHealthHandler 返回指定ListenServer的健康状态；未运行或停机排空阶段时返回503
+func (s *BootstrapServer) ListenerHealthHandler(webex flux.ServerWebContext) error {
+	id := strings.ToLower(webex.PathVar("id"))
+	for _, health := range s.WebListenerHealth() {
+		if health.Id == id {
+			status := http.StatusOK
+			if health.Status != ListenerStatusRunning || s.IsDraining() {
+				status = http.StatusServiceUnavailable
+			}
+			return sendAdminJSON(webex, status, health)
+		}
+	}
+	return sendAdminJSON(webex, http.StatusNotFound, map[string]string{"error": "listener not found: " + id})
+}
+
+// ListenerCreateHandler 根据配置创建并启动ListenServer
+func (s *BootstrapServer) ListenerCreateHandler(webex flux.ServerWebContext) error {
+	return s.doListenerAction(webex, s.CreateWebListener)
+}
+
+// ListenerStartHandler 启动ListenServer
+func (s *BootstrapServer) ListenerStartHandler(webex flux.ServerWebContext) error {
+	return s.doListenerAction(webex, s.StartWebListener)
+}
+
+// ListenerStopHandler 停止ListenServer
+func (s *BootstrapServer) ListenerStopHandler(webex flux.ServerWebContext) error {
+	return s.doListenerAction(webex, func(id string) error {
+		return s.stopWebListenerByAdmin(webex.Context(), id)
+	})
+}
+
+// stopWebListenerByAdmin 通过管理接口停止ListenServer；不允许停止Admin ListenServer自身
+func (s *BootstrapServer) stopWebListenerByAdmin(ctx goctx.Context, listenerID string) error {
+	if strings.ToLower(listenerID) == ListenServerIdAdmin {
+		return fmt.Errorf("web listener is not stoppable by admin api, listener-id: %s", ListenServerIdAdmin)
+	}
+	return s.StopWebListener(ctx, listenerID)
+}
+
+// ListenerRemoveHandler 停止并删除ListenServer
+func (s *BootstrapServer) ListenerRemoveHandler(webex flux.ServerWebContext) error {
+	return s.doListenerAction(webex, func(id string) error {
+		return s.RemoveWebListener(webex.Context(), id)
+	})
+}
+
+func (s *BootstrapServer) doListenerAction(webex flux.ServerWebContext, action func(id string) error) error {
+	id := webex.PathVar("id")
+	if id == "" {
+		return sendAdminJSON(webex, http.StatusBadRequest, map[string]string{"error": "listener id is required"})
+	}
+	if err := action(id); nil != err {
+		logger.Trace(webex.RequestId()).Warnw("SERVER:LISTENER:ADMIN", "listener-id", id, "error", err)
+		return sendAdminJSON(webex, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	return s.ListenersHandler(webex)
+}
+
+func sendAdminJSON(webex flux.ServerWebContext, status int, payload interface{}) error {
+	bytes, err := common.SerializeObject(payload)
+	if nil != err {
+		return err
+	}
+	return webex.Write(status, flux.MIMEApplicationJSONCharsetUTF8, bytes)
+}
@@ -0,0 +1,241 @@
+package server
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	discoveryConfigKeyPriority    = "priority"
+	discoveryConfigKeyMergePolicy = "merge_policy"
+)
+
+const (
+	mergeKindEndpoint = "endpoint"
+	mergeKindService  = "service"
+	mergeKindConsumer = "consumer"
+)
+
+// DiscoverySource 注册中心的优先级与合并策略
+type DiscoverySource struct {
+	Id       string
+	Priority int
+	Policy   string
+}
+
+// LoadDiscoverySource 从注册中心配置中加载优先级与合并策略
+func LoadDiscoverySource(id string, config *flux.Configuration) DiscoverySource {
+	config.SetDefaults(map[string]interface{}{
+		discoveryConfigKeyPriority:    0,
+		discoveryConfigKeyMergePolicy: flux.MergePolicyOverride,
+	})
+	policy := strings.ToLower(config.GetString(discoveryConfigKeyMergePolicy))
+	switch policy {
+	case flux.MergePolicyOverride, flux.MergePolicyReject, flux.MergePolicyShadow:
+		break
+	default:
+		logger.Warnw("SERVER:DISCOVERY:MERGE_POLICY/UNKNOWN", "discovery-id", id, "policy", policy)
+		policy = flux.MergePolicyOverride
+	}
+	return DiscoverySource{Id: id, Priority: config.GetInt(discoveryConfigKeyPriority), Policy: policy}
+}
+
+type mergeEntry struct {
+	source  DiscoverySource
+	value   interface{}
+	seq     uint64 // 数据首次到达的顺序
+	written uint64 // 数据最后写入的顺序
+}
+
+// MergeTable 合并多个注册中心对相同数据的定义。
+// 每个数据Key保存各注册中心的定义，生效数据在读取时计算：优先级最高者生效；相同优先级时，override策略的定义优先，
+// 多个override定义按最后写入者生效，其它按注册中心ID排序；reject策略的定义，在其它注册中心已先定义时不生效。
+// 删除生效数据后，按剩余的定义恢复。
+type MergeTable struct {
+	kind    string
+	entries map[string][]mergeEntry
+	seq     uint64
+	mu      sync.Mutex
+}
+
+func NewMergeTable(kind string) *MergeTable {
+	return &MergeTable{
+		kind:    kind,
+		entries: make(map[string][]mergeEntry, 64),
+	}
+}
+
+// Apply 合并来自指定注册中心的数据事件；返回需要生效的数据事件类型、数据，以及是否需要生效。
+func (t *MergeTable) Apply(key string, source DiscoverySource, eventType flux.EventType, value interface{}) (flux.EventType, interface{}, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	before, hasBefore := t.active(key)
+	switch eventType {
+	case flux.EventTypeAdded, flux.EventTypeUpdated:
+		t.merge(key, source, value)
+	case flux.EventTypeRemoved:
+		t.remove(key, source.Id)
+	}
+	entries := t.entries[key]
+	if len(entries) <= 1 {
+		ext.RemoveDiscoveryConflicts(t.kind, key)
+	}
+	after, hasAfter := t.active(key)
+	if (eventType == flux.EventTypeAdded || eventType == flux.EventTypeUpdated) && hasBefore && before.source.Id != source.Id {
+		t.conflict(key, source, before.source, t.resolution(source, after))
+	}
+	switch {
+	case !hasBefore && hasAfter:
+		return flux.EventTypeAdded, after.value, true
+	case hasBefore && !hasAfter:
+		return flux.EventTypeRemoved, before.value, true
+	case hasBefore && hasAfter:
+		// 生效数据所属注册中心变更，或者生效数据被更新
+		if after.source.Id != before.source.Id || after.source.Id == source.Id {
+			return flux.EventTypeUpdated, after.value, true
+		}
+	}
+	return eventType, nil, false
+}
+
+// merge 保存注册中心的定义；每个注册中心只保留一份定义，更新时保留首次到达的顺序，记录最后写入的顺序
+func (t *MergeTable) merge(key string, source DiscoverySource, value interface{}) {
+	t.seq++
+	entries := t.entries[key]
+	for i, e := range entries {
+		if e.source.Id == source.Id {
+			entries[i].source, entries[i].value, entries[i].written = source, value, t.seq
+			return
+		}
+	}
+	t.entries[key] = append(entries, mergeEntry{source: source, value: value, seq: t.seq, written: t.seq})
+}
+
+func (t *MergeTable) remove(key, sourceId string) {
+	entries := t.entries[key]
+	for i, e := range entries {
+		if e.source.Id == sourceId {
+			t.entries[key] = append(entries[:i], entries[i+1:]...)
+			break
+		}
+	}
+	if len(t.entries[key]) == 0 {
+		delete(t.entries, key)
+	}
+}
+
+// active 计算生效的定义
+func (t *MergeTable) active(key string) (mergeEntry, bool) {
+	entries := t.entries[key]
+	var winner mergeEntry
+	found := false
+	for _, e := range entries {
+		if e.source.Policy == flux.MergePolicyReject && t.preceded(entries, e) {
+			continue
+		}
+		if !found || t.less(winner, e) {
+			winner, found = e, true
+		}
+	}
+	return winner, found
+}
+
+// preceded 判定是否有其它注册中心的定义先于指定定义到达
+func (t *MergeTable) preceded(entries []mergeEntry, entry mergeEntry) bool {
+	for _, e := range entries {
+		if e.source.Id != entry.source.Id && e.seq < entry.seq {
+			return true
+		}
+	}
+	return false
+}
+
+// resolution 返回冲突的处理结果，用于记录冲突
+func (t *MergeTable) resolution(source DiscoverySource, after mergeEntry) string {
+	won := after.source.Id == source.Id
+	switch source.Policy {
+	case flux.MergePolicyReject:
+		if won {
+			return "accepted"
+		}
+		return "rejected"
+	case flux.MergePolicyShadow:
+		if won {
+			return "shadowing"
+		}
+		return "shadowed"
+	default:
+		if won {
+			return "overridden"
+		}
+		return "ignored"
+	}
+}
+
+// less 判定定义a是否低于b：优先级较高者优先；相同优先级时，override策略的定义优先，
+// 多个override定义按最后写入者优先，其它按注册中心ID较小者优先。
+func (t *MergeTable) less(a, b mergeEntry) bool {
+	if a.source.Priority != b.source.Priority {
+		return a.source.Priority < b.source.Priority
+	}
+	aOverride, bOverride := a.source.Policy == flux.MergePolicyOverride, b.source.Policy == flux.MergePolicyOverride
+	if aOverride != bOverride {
+		return bOverride
+	}
+	if aOverride {
+		return a.written < b.written
+	}
+	return a.source.Id > b.source.Id
+}
+
+func (t *MergeTable) conflict(key string, source, owner DiscoverySource, resolution string) {
+	logger.Warnw("SERVER:DISCOVERY:CONFLICT", "kind", t.kind, "key", key,
+		"source", source.Id, "source-priority", source.Priority, "policy", source.Policy,
+		"owner", owner.Id, "owner-priority", owner.Priority, "resolution", resolution)
+	ext.RecordDiscoveryConflict(flux.DiscoveryConflict{
+		Kind:           t.kind,
+		Key:            key,
+		Source:         source.Id,
+		SourcePriority: source.Priority,
+		Owner:          owner.Id,
+		OwnerPriority:  owner.Priority,
+		Policy:         source.Policy,
+		Resolution:     resolution,
+		Timestamp:      time.Now().UnixNano() / int64(time.Millisecond),
+	})
+}
+
+// DiscoveryEndpointEvent 携带来源注册中心的Endpoint事件
+type DiscoveryEndpointEvent struct {
+	Source DiscoverySource
+	Event  flux.EndpointEvent
+}
+
+// DiscoveryServiceEvent 携带来源注册中心的Service事件
+type DiscoveryServiceEvent struct {
+	Source DiscoverySource
+	Event  flux.ServiceEvent
+}
+
+// DiscoveryConsumerEvent 携带来源注册中心的Consumer事件
+type DiscoveryConsumerEvent struct {
+	Source DiscoverySource
+	Event  flux.ConsumerEvent
+}
+
+// makeMergeEndpointKey 构建Endpoint版本的合并Key：路由Key(Method+Pattern+Host+请求谓词)+版本；
+// 不同Host或请求谓词的相同版本为不同的Endpoint定义，Host或请求谓词变更时，需要注册中心删除原定义
+func makeMergeEndpointKey(endpoint *flux.Endpoint) string {
+	return ext.MakeEndpointKeyOf(endpoint) + "#" + endpoint.Version
+}
+
+func makeMergeServiceKey(service *flux.Service) string {
+	if service.ServiceId != "" {
+		return service.ServiceId
+	}
+	return service.ServiceID()
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	assert2 "github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMergeTableShadow(t *testing.T) {
+	assert := assert2.New(t)
+	table := NewMergeTable(mergeKindEndpoint)
+	zk := DiscoverySource{Id: "zookeeper", Priority: 0, Policy: flux.MergePolicyOverride}
+	res := DiscoverySource{Id: "resource", Priority: 100, Policy: flux.MergePolicyShadow}
+	// 本地数据先到达
+	etype, value, ok := table.Apply("k", res, flux.EventTypeAdded, "local")
+	assert.True(ok)
+	assert.Equal(flux.EventType(flux.EventTypeAdded), etype)
+	assert.Equal("local", value)
+	// 低优先级注册中心数据暂不生效
+	_, _, ok = table.Apply("k", zk, flux.EventTypeAdded, "remote")
+	assert.False(ok)
+	// 本地数据删除后，恢复注册中心数据
+	etype, value, ok = table.Apply("k", res, flux.EventTypeRemoved, "local")
+	assert.True(ok)
+	assert.Equal(flux.EventType(flux.EventTypeUpdated), etype)
+	assert.Equal("remote", value)
+	// 全部删除
+	etype, value, ok = table.Apply("k", zk, flux.EventTypeRemoved, "remote")
+	assert.True(ok)
+	assert.Equal(flux.EventType(flux.EventTypeRemoved), etype)
+	assert.Equal("remote", value)
+}
+
+func TestMergeTableArrivalOrder(t *testing.T) {
+	zk := DiscoverySource{Id: "zookeeper", Priority: 0, Policy: flux.MergePolicyOverride}
+	res := DiscoverySource{Id: "resource", Priority: 100, Policy: flux.MergePolicyShadow}
+	cases := []struct {
+		name  string
+		order []DiscoverySource
+	}{
+		{name: "resource-first", order: []DiscoverySource{res, zk}},
+		{name: "zookeeper-first", order: []DiscoverySource{zk, res}},
+	}
+	values := map[string]string{zk.Id: "remote", res.Id: "local"}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert2.New(t)
+			table := NewMergeTable(mergeKindEndpoint)
+			for _, source := range tc.order {
+				table.Apply("k", source, flux.EventTypeAdded, values[source.Id])
+			}
+			entry, ok := table.active("k")
+			assert.True(ok)
+			assert.Equal("local", entry.value)
+			// 删除高优先级数据，恢复低优先级数据
+			etype, value, ok := table.Apply("k", res, flux.EventTypeRemoved, "local")
+			assert.True(ok)
+			assert.Equal(flux.EventType(flux.EventTypeUpdated), etype)
+			assert.Equal("remote", value)
+		})
+	}
+}
+
+func TestMergeTableShadowRestore(t *testing.T) {
+	assert := assert2.New(t)
+	table := NewMergeTable(mergeKindEndpoint)
+	zk := DiscoverySource{Id: "zookeeper", Priority: 0, Policy: flux.MergePolicyShadow}
+	res := DiscoverySource{Id: "resource", Priority: 100, Policy: flux.MergePolicyShadow}
+	_, _, ok := table.Apply("k", zk, flux.EventTypeAdded, "remote")
+	assert.True(ok)
+	etype, value, ok := table.Apply("k", res, flux.EventTypeAdded, "local")
+	assert.True(ok)
+	assert.Equal(flux.EventType(flux.EventTypeUpdated), etype)
+	assert.Equal("local", value)
+	// 被遮蔽的数据更新，不生效
+	_, _, ok = table.Apply("k", zk, flux.EventTypeUpdated, "remote2")
+	assert.False(ok)
+	// 高优先级数据删除后，恢复低优先级数据
+	etype, value, ok = table.Apply("k", res, flux.EventTypeRemoved, "local")
+	assert.True(ok)
+	assert.Equal(flux.EventType(flux.EventTypeUpdated), etype)
+	assert.Equal("remote2", value)
+}
+
+func TestMergeTableReject(t *testing.T) {
+	assert := assert2.New(t)
+	table := NewMergeTable(mergeKindService)
+	a := DiscoverySource{Id: "a", Priority: 0, Policy: flux.MergePolicyOverride}
+	b := DiscoverySource{Id: "b", Priority: 10, Policy: flux.MergePolicyReject}
+	_, _, ok := table.Apply("k", a, flux.EventTypeAdded, "a")
+	assert.True(ok)
+	_, _, ok = table.Apply("k", b, flux.EventTypeAdded, "b")
+	assert.False(ok)
+	etype, value, ok := table.Apply("k", a, flux.EventTypeUpdated, "a2")
+	assert.True(ok)
+	assert.Equal(flux.EventType(flux.EventTypeUpdated), etype)
+	assert.Equal("a2", value)
+	// 先定义的数据删除后，被拒绝的数据生效
+	etype, value, ok = table.Apply("k", a, flux.EventTypeRemoved, "a2")
+	assert.True(ok)
+	assert.Equal(flux.EventType(flux.EventTypeUpdated), etype)
+	assert.Equal("b", value)
+}
+
+func TestMergeTableOverrideEqualPriority(t *testing.T) {
+	cases := []struct {
+		policy string
+		winner string
+	}{
+		// override：相同优先级时，最后写入者生效
+		{policy: flux.MergePolicyOverride, winner: "b2"},
+		// shadow：相同优先级时，按注册中心ID排序，与写入顺序无关
+		{policy: flux.MergePolicyShadow, winner: "a"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.policy, func(t *testing.T) {
+			assert := assert2.New(t)
+			table := NewMergeTable(mergeKindEndpoint)
+			a := DiscoverySource{Id: "a", Priority: 10, Policy: tc.policy}
+			b := DiscoverySource{Id: "b", Priority: 10, Policy: tc.policy}
+			table.Apply("k", a, flux.EventTypeAdded, "a")
+			table.Apply("k", b, flux.EventTypeAdded, "b")
+			table.Apply("k", b, flux.EventTypeUpdated, "b2")
+			entry, ok := table.active("k")
+			assert.True(ok)
+			assert.Equal(tc.winner, entry.value)
+		})
+	}
+	// override的数据再次写入时，重新生效
+	assert := assert2.New(t)
+	table := NewMergeTable(mergeKindEndpoint)
+	a := DiscoverySource{Id: "a", Priority: 10, Policy: flux.MergePolicyOverride}
+	b := DiscoverySource{Id: "b", Priority: 10, Policy: flux.MergePolicyOverride}
+	table.Apply("k", a, flux.EventTypeAdded, "a")
+	etype, value, ok := table.Apply("k", b, flux.EventTypeAdded, "b")
+	assert.True(ok)
+	assert.Equal(flux.EventType(flux.EventTypeUpdated), etype)
+	assert.Equal("b", value)
+	etype, value, ok = table.Apply("k", a, flux.EventTypeUpdated, "a2")
+	assert.True(ok)
+	assert.Equal(flux.EventType(flux.EventTypeUpdated), etype)
+	assert.Equal("a2", value)
+	// 生效数据删除后，恢复剩余的定义
+	etype, value, ok = table.Apply("k", a, flux.EventTypeRemoved, "a2")
+	assert.True(ok)
+	assert.Equal(flux.EventType(flux.EventTypeUpdated), etype)
+	assert.Equal("b", value)
+	// 低优先级的override数据不生效
+	_, _, ok = table.Apply("k", DiscoverySource{Id: "c", Priority: 0, Policy: flux.MergePolicyOverride}, flux.EventTypeAdded, "c")
+	assert.False(ok)
+}
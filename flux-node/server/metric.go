@@ -3,6 +3,7 @@ package server
 import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+	"sync"
 )
 
 var (
@@ -29,10 +30,24 @@ var (
 	}
 )
 
+var (
+	defaultMetrics     *Metrics
+	defaultMetricsOnce sync.Once
+)
+
+// DefaultMetrics 返回全局共享的Metrics；Metrics注册到全局Registry，不可重复创建。
+func DefaultMetrics() *Metrics {
+	defaultMetricsOnce.Do(func() {
+		defaultMetrics = NewMetrics()
+	})
+	return defaultMetrics
+}
+
 type Metrics struct {
 	EndpointAccess *prometheus.CounterVec
 	EndpointError  *prometheus.CounterVec
 	RouteDuration  *prometheus.HistogramVec
+	ShadowDiff     *prometheus.CounterVec
 }
 
 func NewMetrics() *Metrics {
@@ -56,5 +71,11 @@ func NewMetrics() *Metrics {
 			Help:      "Spend time by processing a endpoint",
 			Buckets:   defaultMetricBuckets,
 		}, []string{"ComponentType", "TypeId"}),
+		ShadowDiff: promauto.NewCounterVec(prometheus.CounterOpts{
+			Namespace: defaultMetricNamespace,
+			Subsystem: defaultMetricSubsystem,
+			Name:      "endpoint_shadow_total",
+			Help:      "Number of shadow requests by diff result",
+		}, []string{"ProtoName", "Interface", "Method", "Result"}),
 	}
 }
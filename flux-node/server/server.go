@@ -9,6 +9,7 @@ import (
 	"github.com/bytepowered/flux/flux-node/ext"
 	"github.com/bytepowered/flux/flux-node/listener"
 	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/bytepowered/flux/flux-node/router"
 	"github.com/bytepowered/flux/flux-pkg"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"golang.org/x/net/context"
@@ -17,8 +18,10 @@ import (
 	"os"
 	"os/signal"
 	"runtime/debug"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -34,6 +37,27 @@ const (
 	ListenServerIdAdmin = "admin"
 )
 
+const (
+	// 路由表批量构建的时间窗口
+	routerBatchWindow = 20 * time.Millisecond
+	// ListenServer注册的通配路由
+	routerCatchAllPattern = "/*"
+)
+
+var routerHttpMethods = []string{
+	http.MethodGet, http.MethodPost, http.MethodDelete, http.MethodPut,
+	http.MethodHead, http.MethodOptions, http.MethodPatch, http.MethodTrace,
+}
+
+// routeEntry 路由表中的Endpoint定义
+type routeEntry struct {
+	method  string
+	pattern string
+	hosts   []string
+	matches []string
+	mvce    *flux.MVCEndpoint
+}
+
 type (
 	// Option 配置HttpServeEngine函数
 	Option func(bs *BootstrapServer)
@@ -47,10 +71,24 @@ type BootstrapServer struct {
 	hookFunc    []flux.ContextHookFunc
 	versionFunc VersionLookupFunc
 	dispatcher  *Dispatcher
+	shadow      *ShadowMirror
+	sources     map[string]DiscoverySource
+	epMerge     *MergeTable
+	srvMerge    *MergeTable
+	csMerge     *MergeTable
+	states      map[string]*listenerState        // 各ListenServer的运行状态
+	listenerMu  sync.RWMutex                     // 保护listener和states
+	bindings    map[string]string                // Endpoint路由绑定的ListenerId
+	tables      map[string]map[string]routeEntry // 各ListenServer的路由定义
+	dirty       map[string]struct{}              // 路由定义已变更，需要重新构建路由表的ListenServer
+	routers     map[string]*atomic.Value         // 各ListenServer当前生效的路由表
+	routeMu     sync.Mutex                       // 保护bindings、tables、dirty和routers
 	pooled      *sync.Pool
 	started     chan struct{}
 	stopped     chan struct{}
 	banner      string
+	draining    int32         // 停机排空阶段，健康检查失败，拒绝新请求
+	drainDelay  time.Duration // 健康检查失败后，等待负载均衡摘除流量的时间
 }
 
 // WithContextHooks 配置请求Hook函数列表
@@ -81,6 +119,13 @@ func WithPrepareHooks(hooks ...flux.PrepareHookFunc) Option {
 	}
 }
 
+// WithDrainDelay 配置停机时健康检查失败后，停止接收请求前的等待时间
+func WithDrainDelay(delay time.Duration) Option {
+	return func(bs *BootstrapServer) {
+		bs.drainDelay = delay
+	}
+}
+
 func WithWebListener(server flux.WebListener) Option {
 	return func(bs *BootstrapServer) {
 		bs.AddWebListener(server.ListenerId(), server)
@@ -106,18 +151,44 @@ func NewDefaultBootstrapServer(options ...Option) *BootstrapServer {
 				// Http Inspect
 				{Method: "GET", Pattern: "/inspect/endpoints", Handler: fluxinspect.EndpointsHandler},
 				{Method: "GET", Pattern: "/inspect/services", Handler: fluxinspect.ServicesHandler},
+				{Method: "GET", Pattern: "/inspect/conflicts", Handler: fluxinspect.ConflictsHandler},
 				// Metrics
 				{Method: "GET", Pattern: "/inspect/metrics", Handler: flux.WrapHttpHandler(promhttp.Handler())},
 			}),
 		)),
+		// 配置定义的其它WebListener
+		WithConfigWebListeners(),
+		WithDrainDelay(flux.NewConfiguration(flux.NamespaceShutdown).GetDuration("drain_delay")),
+	}
+	srv := NewBootstrapServerWith(append(opts, options...)...)
+	// ListenServer运行时管理
+	if admin, ok := srv.WebListenerById(ListenServerIdAdmin); ok {
+		admin.AddHandler("GET", "/admin/health", srv.HealthHandler)
+		admin.AddHandler("GET", "/admin/listeners", srv.ListenersHandler)
+		admin.AddHandler("GET", "/admin/listeners/{id}/health", srv.ListenerHealthHandler)
+		admin.AddHandler("POST", "/admin/listeners/{id}", srv.ListenerCreateHandler)
+		admin.AddHandler("DELETE", "/admin/listeners/{id}", srv.ListenerRemoveHandler)
+		admin.AddHandler("POST", "/admin/listeners/{id}/start", srv.ListenerStartHandler)
+		admin.AddHandler("POST", "/admin/listeners/{id}/stop", srv.ListenerStopHandler)
 	}
-	return NewBootstrapServerWith(append(opts, options...)...)
+	return srv
 }
 
 func NewBootstrapServerWith(opts ...Option) *BootstrapServer {
+	dispatcher := NewDispatcher()
 	srv := &BootstrapServer{
-		dispatcher: NewDispatcher(),
+		dispatcher: dispatcher,
+		shadow:     NewShadowMirror(dispatcher.metrics),
+		sources:    make(map[string]DiscoverySource, 4),
+		epMerge:    NewMergeTable(mergeKindEndpoint),
+		srvMerge:   NewMergeTable(mergeKindService),
+		csMerge:    NewMergeTable(mergeKindConsumer),
+		bindings:   make(map[string]string, 64),
+		tables:     make(map[string]map[string]routeEntry, 2),
+		dirty:      make(map[string]struct{}, 2),
+		routers:    make(map[string]*atomic.Value, 2),
 		listener:   make(map[string]flux.WebListener, 2),
+		states:     make(map[string]*listenerState, 2),
 		hookFunc:   make([]flux.ContextHookFunc, 0, 4),
 		pooled:     &sync.Pool{New: func() interface{} { return flux.NewContext() }},
 		started:    make(chan struct{}),
@@ -138,14 +209,16 @@ func (s *BootstrapServer) Prepare() error {
 // Initial
 func (s *BootstrapServer) Initial() error {
 	// Listen Server
-	for id, webListener := range s.listener {
+	for id, webListener := range s.webListeners() {
 		if err := webListener.Init(LoadWebListenerConfig(id)); nil != err {
 			return err
 		}
 	}
 	// Discovery
 	for _, dis := range ext.EndpointDiscoveries() {
-		if err := s.dispatcher.AddInitHook(dis, LoadDiscoveryConfig(dis.Id())); nil != err {
+		config := LoadDiscoveryConfig(dis.Id())
+		s.sources[dis.Id()] = LoadDiscoverySource(dis.Id(), config)
+		if err := s.dispatcher.AddInitHook(dis, config); nil != err {
 			return err
 		}
 	}
@@ -170,46 +243,61 @@ func (s *BootstrapServer) start() error {
 	}
 	logger.Info("SERVER:START:DISPATCHER:OK")
 	// Discovery
-	endpoints := make(chan flux.EndpointEvent, 2)
-	services := make(chan flux.ServiceEvent, 2)
-	defer func() {
-		close(endpoints)
-		close(services)
-	}()
+	endpoints := make(chan DiscoveryEndpointEvent, 2)
+	services := make(chan DiscoveryServiceEvent, 2)
+	consumers := make(chan DiscoveryConsumerEvent, 2)
 	logger.Info("SERVER:START:DISCOVERY:START")
 	ctx, canceled := context.WithCancel(context.Background())
 	defer canceled()
-	go s.startEventLoop(ctx, endpoints, services)
-	if err := s.startEventWatch(ctx, endpoints, services); nil != err {
+	go s.startEventLoop(ctx, endpoints, services, consumers)
+	if err := s.startEventWatch(ctx, endpoints, services, consumers); nil != err {
 		return err
 	}
 	logger.Info("SERVER:START:DISCOVERY:OK")
-	// Listeners
-	var errch chan error
-	for lid, wl := range s.listener {
-		logger.Infow("SERVER:START:LISTENER:START", "listener-id", wl.ListenerId())
-		go func(id string, server flux.WebListener) {
-			errch <- server.Listen()
-			logger.Infow("SERVER:START:LISTENER:STOP", "listener-id", id)
-		}(lid, wl)
+	// Listeners：任一ListenServer非主动停止时，返回其错误
+	listeners := s.webListeners()
+	errch := make(chan error, len(listeners))
+	for id := range listeners {
+		if _, err := s.startListener(id, errch); nil != err {
+			return err
+		}
 	}
 	close(s.started)
-	return <-errch
+	select {
+	case err := <-errch:
+		return err
+	case <-s.stopped:
+		return http.ErrServerClosed
+	}
 }
 
-func (s *BootstrapServer) startEventLoop(ctx context.Context, endpoints chan flux.EndpointEvent, services chan flux.ServiceEvent) {
+func (s *BootstrapServer) startEventLoop(ctx context.Context, endpoints chan DiscoveryEndpointEvent, services chan DiscoveryServiceEvent, consumers chan DiscoveryConsumerEvent) {
 	logger.Info("SERVER:START:DISCOVERY:EVENT_LOOP:START")
 	defer logger.Info("SERVER:START:DISCOVERY:EVENT_LOOP:STOP")
+	// 在批次窗口内接收的Endpoint事件，合并构建一次路由表
+	var flush <-chan time.Time
 	for {
 		select {
 		case epEvt, ok := <-endpoints:
 			if ok {
-				s.onEndpointEvent(epEvt)
+				s.onDiscoveryEndpointEvent(epEvt)
+				if flush == nil {
+					flush = time.After(routerBatchWindow)
+				}
 			}
 
+		case <-flush:
+			flush = nil
+			s.flushRouters()
+
 		case esEvt, ok := <-services:
 			if ok {
-				s.onServiceEvent(esEvt)
+				s.onDiscoveryServiceEvent(esEvt)
+			}
+
+		case csEvt, ok := <-consumers:
+			if ok {
+				s.onDiscoveryConsumerEvent(csEvt)
 			}
 
 		case <-ctx.Done():
@@ -218,33 +306,101 @@ func (s *BootstrapServer) startEventLoop(ctx context.Context, endpoints chan flu
 	}
 }
 
-func (s *BootstrapServer) startEventWatch(ctx context.Context, endpoints chan flux.EndpointEvent, services chan flux.ServiceEvent) error {
-	for _, discovery := range ext.EndpointDiscoveries() {
-		logger.Infow("SERVER:START:DISCOVERY:WATCH", "discovery-id", discovery.Id())
-		if err := discovery.WatchEndpoints(ctx, endpoints); nil != err {
+func (s *BootstrapServer) startEventWatch(ctx context.Context, endpoints chan DiscoveryEndpointEvent, services chan DiscoveryServiceEvent, consumers chan DiscoveryConsumerEvent) error {
+	// 按优先级从高到低启动注册中心监听
+	discoveries := ext.EndpointDiscoveries()
+	sort.SliceStable(discoveries, func(i, j int) bool {
+		return s.sourceOf(discoveries[j].Id()).Priority < s.sourceOf(discoveries[i].Id()).Priority
+	})
+	for _, discovery := range discoveries {
+		source := s.sourceOf(discovery.Id())
+		logger.Infow("SERVER:START:DISCOVERY:WATCH", "discovery-id", discovery.Id(),
+			"priority", source.Priority, "merge-policy", source.Policy)
+		epEvents := make(chan flux.EndpointEvent, 2)
+		srvEvents := make(chan flux.ServiceEvent, 2)
+		csEvents := make(chan flux.ConsumerEvent, 2)
+		go func() {
+			for {
+				select {
+				case evt := <-epEvents:
+					endpoints <- DiscoveryEndpointEvent{Source: source, Event: evt}
+				case evt := <-srvEvents:
+					services <- DiscoveryServiceEvent{Source: source, Event: evt}
+				case evt := <-csEvents:
+					consumers <- DiscoveryConsumerEvent{Source: source, Event: evt}
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		if err := discovery.WatchEndpoints(ctx, epEvents); nil != err {
 			return err
 		}
-		if err := discovery.WatchServices(ctx, services); nil != err {
+		if err := discovery.WatchServices(ctx, srvEvents); nil != err {
 			return err
 		}
+		// Consumer注册为可选实现
+		if cd, ok := discovery.(flux.ConsumerDiscovery); ok {
+			if err := cd.WatchConsumers(ctx, csEvents); nil != err {
+				return err
+			}
+		}
 		logger.Infow("SERVER:START:DISCOVERY:WATCH/OK", "discovery-id", discovery.Id())
 	}
 	return nil
 }
 
-func (s *BootstrapServer) route(webex flux.ServerWebContext, server flux.WebListener, endpoints *flux.MVCEndpoint) (err error) {
+func (s *BootstrapServer) onDiscoveryEndpointEvent(de DiscoveryEndpointEvent) {
+	key := makeMergeEndpointKey(&de.Event.Endpoint)
+	if etype, value, ok := s.epMerge.Apply(key, de.Source, de.Event.EventType, de.Event.Endpoint); ok {
+		s.onEndpointEvent(flux.EndpointEvent{EventType: etype, Endpoint: value.(flux.Endpoint)})
+	}
+}
+
+func (s *BootstrapServer) onDiscoveryServiceEvent(de DiscoveryServiceEvent) {
+	key := makeMergeServiceKey(&de.Event.Service)
+	if etype, value, ok := s.srvMerge.Apply(key, de.Source, de.Event.EventType, de.Event.Service); ok {
+		s.onServiceEvent(flux.ServiceEvent{EventType: etype, Service: value.(flux.Service)})
+	}
+}
+
+func (s *BootstrapServer) onDiscoveryConsumerEvent(de DiscoveryConsumerEvent) {
+	key := de.Event.Consumer.ConsumerId
+	if etype, value, ok := s.csMerge.Apply(key, de.Source, de.Event.EventType, de.Event.Consumer); ok {
+		s.onConsumerEvent(flux.ConsumerEvent{EventType: etype, Consumer: value.(flux.Consumer)})
+	}
+}
+
+func (s *BootstrapServer) sourceOf(id string) DiscoverySource {
+	if source, ok := s.sources[id]; ok {
+		return source
+	}
+	return DiscoverySource{Id: id, Policy: flux.MergePolicyOverride}
+}
+
+func (s *BootstrapServer) route(webex flux.ServerWebContext, server flux.WebListener, endpoints *flux.MVCEndpoint, cors *listener.CORSPolicy) (err error) {
 	defer func(id string) {
 		if rvr := recover(); rvr != nil {
 			logger.Trace(id).Errorw("SERVER:ROUTE:CRITICAL_PANIC", "error", rvr, "debug", string(debug.Stack()))
 			err = fmt.Errorf("SERVER:ROUTE:%s", rvr)
 		}
 	}(webex.RequestId())
+	// 停机排空阶段，拒绝新请求
+	if s.IsDraining() {
+		server.HandleError(webex, &flux.ServeError{
+			StatusCode: flux.StatusUnavailable,
+			ErrorCode:  flux.ErrorCodeGatewayDraining,
+			Message:    flux.ErrorMessageWebServerDraining,
+		})
+		return nil
+	}
 	endpoint, found := endpoints.Lookup(s.versionFunc(webex))
 	// 实现动态Endpoint版本选择
+	// 选择器未选中时，使用默认版本选择结果
 	for _, selector := range ext.EndpointSelectors() {
 		if selector.Active(webex, server.ListenerId()) {
-			endpoint, found = selector.DoSelect(webex, server.ListenerId(), endpoints)
-			if found {
+			if selected, ok := selector.DoSelect(webex, server.ListenerId(), endpoints); ok {
+				endpoint, found = selected, true
 				break
 			}
 		}
@@ -258,6 +414,22 @@ func (s *BootstrapServer) route(webex flux.ServerWebContext, server flux.WebList
 	} else {
 		fluxpkg.Assert(endpoint.IsValid(), "<endpoint> must valid when routing")
 	}
+	// CORS响应Header，错误响应也需要返回，以便客户端读取错误信息
+	cors.WithEndpoint(&endpoint).WriteResponse(webex.ResponseWriter().Header(), webex.Request())
+	// Endpoint要求客户端提供已验证的TLS证书
+	if endpoint.RequireClientCert() {
+		if peer, ok := flux.NewTLSPeer(webex.Request().TLS); !ok || !peer.Verified {
+			logger.Trace(webex.RequestId()).Infow("SERVER:ROUTE:CLIENT_CERT_ABSENT",
+				"http-pattern", []string{webex.Method(), webex.URI(), webex.URL().Path},
+			)
+			server.HandleError(webex, &flux.ServeError{
+				StatusCode: flux.StatusUnauthorized,
+				ErrorCode:  flux.ErrorCodeTLSClientCertRequired,
+				Message:    flux.ErrorMessageWebServerClientCertAbsent,
+			})
+			return nil
+		}
+	}
 	ctxw := s.pooled.Get().(*flux.Context)
 	defer s.pooled.Put(ctxw)
 	ctxw.Reset(webex, &endpoint)
@@ -276,6 +448,8 @@ func (s *BootstrapServer) route(webex flux.ServerWebContext, server flux.WebList
 	if serr := s.dispatcher.Route(ctxw); nil != serr {
 		server.HandleError(webex, serr)
 	}
+	// 流量镜像：主请求完成后异步执行
+	s.shadow.Mirror(ctxw)
 	return nil
 }
 
@@ -305,6 +479,24 @@ func (s *BootstrapServer) onServiceEvent(event flux.ServiceEvent) {
 			ext.RemoveServiceByID(service.AliasId)
 		}
 	}
+	for _, hook := range ext.ServiceEventHooks() {
+		hook(event)
+	}
+}
+
+func (s *BootstrapServer) onConsumerEvent(event flux.ConsumerEvent) {
+	consumer := event.Consumer
+	switch event.EventType {
+	case flux.EventTypeAdded:
+		logger.Infow("SERVER:EVENT:CONSUMER:ADD", "consumer-id", consumer.ConsumerId, "disabled", consumer.Disabled)
+		ext.RegisterConsumer(consumer)
+	case flux.EventTypeUpdated:
+		logger.Infow("SERVER:EVENT:CONSUMER:UPDATE", "consumer-id", consumer.ConsumerId, "disabled", consumer.Disabled)
+		ext.RegisterConsumer(consumer)
+	case flux.EventTypeRemoved:
+		logger.Infow("SERVER:EVENT:CONSUMER:REMOVE", "consumer-id", consumer.ConsumerId)
+		ext.RemoveConsumerByID(consumer.ConsumerId)
+	}
 }
 
 func (s *BootstrapServer) onEndpointEvent(event flux.EndpointEvent) {
@@ -318,46 +510,179 @@ func (s *BootstrapServer) onEndpointEvent(event flux.EndpointEvent) {
 	initArguments(endpoint.Service.Arguments)
 	initArguments(endpoint.PermissionService.Arguments)
 	pattern := event.Endpoint.HttpPattern
-	mvce, register := s.selectMVCEndpoint(&endpoint)
+	key := ext.MakeEndpointKeyOf(&endpoint)
+	s.routeMu.Lock()
+	defer s.routeMu.Unlock()
 	switch event.EventType {
 	case flux.EventTypeAdded:
 		logger.Infow("SERVER:EVENT:ENDPOINT:ADD", "version", endpoint.Version, "method", method, "pattern", pattern)
+		mvce := s.selectMVCEndpoint(&endpoint)
 		mvce.Update(endpoint.Version, &endpoint)
-		// 根据Endpoint属性，选择ListenServer来绑定
-		if register {
-			id := endpoint.GetAttr(flux.EndpointAttrTagListenerId).GetString()
-			if id == "" {
-				id = ListenerIdDefault
-			}
-			server, ok := s.WebListenerById(id)
-			if ok {
-				logger.Infow("SERVER:EVENT:ENDPOINT:HTTP_HANDLER/"+id, "method", method, "pattern", pattern)
-				server.AddHandler(method, pattern, s.newEndpointHandler(server, mvce))
-			} else {
-				logger.Errorw("SERVER:EVENT:ENDPOINT:LISTENER_MISSED/"+id, "method", method, "pattern", pattern)
-			}
-		}
+		s.bindEndpoint(key, method, &endpoint, mvce)
 	case flux.EventTypeUpdated:
 		logger.Infow("SERVER:EVENT:ENDPOINT:UPDATE", "version", endpoint.Version, "method", method, "pattern", pattern)
+		mvce := s.selectMVCEndpoint(&endpoint)
 		mvce.Update(endpoint.Version, &endpoint)
+		s.bindEndpoint(key, method, &endpoint, mvce)
 	case flux.EventTypeRemoved:
-		logger.Infow("SERVER:EVENT:ENDPOINT:REMOVE", "method", method, "pattern", pattern)
-		mvce.Delete(endpoint.Version)
+		logger.Infow("SERVER:EVENT:ENDPOINT:REMOVE", "version", endpoint.Version, "method", method, "pattern", pattern)
+		s.deleteEndpointVersion(key, method, pattern, endpoint.Version)
 	}
 }
 
-// Shutdown to cleanup resources
+// deleteEndpointVersion 删除路由Key的指定版本；全部版本被删除时，注销路由，回收MVCEndpoint
+func (s *BootstrapServer) deleteEndpointVersion(key, method, pattern, version string) {
+	mvce, ok := ext.EndpointByKey(key)
+	if !ok {
+		return
+	}
+	mvce.Delete(version)
+	if mvce.IsEmpty() {
+		s.unbindEndpoint(key, method, pattern)
+		ext.RemoveEndpoint(key)
+	}
+}
+
+// bindEndpoint 根据Endpoint的ListenerId属性，选择ListenServer的路由表来绑定；绑定的ListenServer变更时，从原路由表删除。
+func (s *BootstrapServer) bindEndpoint(key, method string, endpoint *flux.Endpoint, mvce *flux.MVCEndpoint) {
+	id := strings.ToLower(endpoint.GetAttr(flux.EndpointAttrTagListenerId).GetString())
+	if id == "" {
+		id = ListenerIdDefault
+	}
+	pattern := endpoint.HttpPattern
+	if bound, ok := s.bindings[key]; ok && bound != id {
+		s.unbindEndpoint(key, method, pattern)
+	}
+	// ListenServer不存在时保留路由定义，ListenServer添加后生效
+	if _, ok := s.routers[id]; !ok {
+		logger.Errorw("SERVER:EVENT:ENDPOINT:LISTENER_MISSED/"+id, "method", method, "pattern", pattern)
+	}
+	table, ok := s.tables[id]
+	if !ok {
+		table = make(map[string]routeEntry, 64)
+		s.tables[id] = table
+	}
+	if entry, ok := table[key]; ok && entry.mvce == mvce {
+		return
+	}
+	logger.Infow("SERVER:EVENT:ENDPOINT:HTTP_HANDLER/"+id, "method", method, "pattern", pattern)
+	table[key] = routeEntry{method: method, pattern: pattern,
+		hosts: endpoint.RouteHosts(), matches: endpoint.RouteMatches(), mvce: mvce}
+	s.bindings[key] = id
+	s.dirty[id] = struct{}{}
+}
+
+func (s *BootstrapServer) unbindEndpoint(key, method, pattern string) {
+	id, ok := s.bindings[key]
+	if !ok {
+		return
+	}
+	logger.Infow("SERVER:EVENT:ENDPOINT:HTTP_HANDLER:REMOVE/"+id, "method", method, "pattern", pattern)
+	delete(s.bindings, key)
+	delete(s.tables[id], key)
+	s.dirty[id] = struct{}{}
+}
+
+// flushRouters 重新构建变更的路由表，并原子替换
+func (s *BootstrapServer) flushRouters() {
+	s.routeMu.Lock()
+	defer s.routeMu.Unlock()
+	s.doFlushRouters()
+}
+
+func (s *BootstrapServer) doFlushRouters() {
+	for id := range s.dirty {
+		current, ok := s.routers[id]
+		if !ok {
+			continue
+		}
+		table := s.tables[id]
+		keys := make([]string, 0, len(table))
+		for key := range table {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		// 相同Method和Pattern的Endpoint，按Host和请求谓词合并为一组路由候选
+		groups := make([]*routeGroup, 0, len(keys))
+		indexes := make(map[string]*routeGroup, len(keys))
+		for _, key := range keys {
+			entry := table[key]
+			gkey := ext.MakeEndpointKey(entry.method, entry.pattern)
+			group, ok := indexes[gkey]
+			if !ok {
+				group = newRouteGroup(entry.method, entry.pattern)
+				indexes[gkey] = group
+				groups = append(groups, group)
+			}
+			if err := group.add(key, entry); nil != err {
+				logger.Warnw("SERVER:ROUTER:ADD/IGNORE", "listener-id", id, "key", key, "error", err)
+			}
+		}
+		builder := router.NewBuilder()
+		for _, group := range groups {
+			if err := builder.Add(group.method, group.pattern, group); nil != err {
+				logger.Warnw("SERVER:ROUTER:ADD/IGNORE", "listener-id", id, "error", err)
+			}
+		}
+		snapshot := builder.Build()
+		current.Store(snapshot)
+		logger.Infow("SERVER:ROUTER:SWAP", "listener-id", id, "routes", snapshot.Len())
+	}
+	s.dirty = make(map[string]struct{}, len(s.routers))
+}
+
+// Shutdown 排空请求并释放资源：
+// 1. 标记排空状态，健康检查失败，等待负载均衡摘除流量；
+// 2. 停止业务ListenServer，不再接收新请求；
+// 3. 等待执行中的请求完成，或者Context超时；
+// 4. 停止管理ListenServer，关闭Transporter和Discovery等资源。
 func (s *BootstrapServer) Shutdown(ctx goctx.Context) error {
 	logger.Info("Server shutdown...")
 	defer close(s.stopped)
-	for id, server := range s.listener {
-		if err := server.Close(ctx); nil != err {
+	atomic.StoreInt32(&s.draining, 1)
+	logger.Infow("SERVER:SHUTDOWN:DRAINING", "inflight", s.dispatcher.Inflight(), "drain-delay", s.drainDelay.String())
+	if s.drainDelay > 0 {
+		select {
+		case <-time.After(s.drainDelay):
+		case <-ctx.Done():
+		}
+	}
+	listeners := s.webListeners()
+	stopped := 0
+	for id := range listeners {
+		if id == ListenServerIdAdmin {
+			continue
+		}
+		if err := s.StopWebListener(ctx, id); nil != err {
 			logger.Warnw("Server["+id+"] shutdown http server", "error", err)
 		}
+		stopped++
+	}
+	logger.Infow("SERVER:SHUTDOWN:LISTENERS", "stopped", stopped, "inflight", s.dispatcher.Inflight())
+	start := time.Now()
+	logger.Infow("SERVER:SHUTDOWN:AWAIT_INFLIGHT", "inflight", s.dispatcher.Inflight())
+	if remains := s.dispatcher.AwaitInflight(ctx); remains > 0 {
+		logger.Warnw("SERVER:SHUTDOWN:AWAIT_INFLIGHT/TIMEOUT", "remains", remains, "elapses", time.Since(start).String())
+	} else {
+		logger.Infow("SERVER:SHUTDOWN:AWAIT_INFLIGHT/DONE", "elapses", time.Since(start).String())
+	}
+	// 镜像请求使用Transporter执行，需要在执行停机Hook之前完成
+	if remains := s.shadow.Close(ctx); remains > 0 {
+		logger.Warnw("SERVER:SHUTDOWN:AWAIT_SHADOW/TIMEOUT", "remains", remains, "elapses", time.Since(start).String())
+	}
+	if _, ok := listeners[ListenServerIdAdmin]; ok {
+		if err := s.StopWebListener(ctx, ListenServerIdAdmin); nil != err {
+			logger.Warnw("Server["+ListenServerIdAdmin+"] shutdown http server", "error", err)
+		}
 	}
 	return s.dispatcher.Shutdown(ctx)
 }
 
+// IsDraining 返回服务是否处于停机排空阶段
+func (s *BootstrapServer) IsDraining() bool {
+	return atomic.LoadInt32(&s.draining) == 1
+}
+
 // GracefulShutdown
 func (s *BootstrapServer) OnSignalShutdown(quit chan os.Signal, to time.Duration) {
 	// 接收停止信号
@@ -401,38 +726,132 @@ func (s *BootstrapServer) SetWebNotfoundHandler(nfh flux.WebHandler) {
 	s.defaultListener().SetNotfoundHandler(nfh)
 }
 
-// AddWebListener 添加指定ID
+// AddWebListener 添加指定ID；ListenServer注册唯一的通配路由，由网关路由表分发请求。
 func (s *BootstrapServer) AddWebListener(listenerID string, server flux.WebListener) {
-	s.listener[strings.ToLower(listenerID)] = fluxpkg.MustNotNil(server, "WebListener is nil").(flux.WebListener)
+	id := strings.ToLower(listenerID)
+	fluxpkg.MustNotNil(server, "WebListener is nil")
+	snapshot := new(atomic.Value)
+	snapshot.Store(router.Empty())
+	cors := listener.LoadCORSPolicy(LoadWebListenerConfig(id))
+	if cors.Enabled {
+		logger.Infow("SERVER:LISTENER:CORS", "listener-id", id, "allow-origins", cors.AllowOrigins,
+			"allow-methods", cors.AllowMethods, "allow-credentials", cors.AllowCredentials)
+	}
+	for _, method := range routerHttpMethods {
+		server.AddHandler(method, routerCatchAllPattern, s.newRouterHandler(server, snapshot, cors))
+	}
+	s.listenerMu.Lock()
+	s.listener[id] = server
+	s.states[id] = &listenerState{listener: server, status: ListenerStatusCreated, since: time.Now()}
+	s.listenerMu.Unlock()
+	// 运行时添加时，恢复已绑定的路由
+	s.routeMu.Lock()
+	s.routers[id] = snapshot
+	if len(s.tables[id]) > 0 {
+		s.dirty[id] = struct{}{}
+		s.doFlushRouters()
+	}
+	s.routeMu.Unlock()
 }
 
 // WebListenerById 返回ListenServer实例
 func (s *BootstrapServer) WebListenerById(listenerID string) (flux.WebListener, bool) {
+	s.listenerMu.RLock()
+	defer s.listenerMu.RUnlock()
 	ls, ok := s.listener[strings.ToLower(listenerID)]
 	return ls, ok
 }
 
+// webListeners 返回全部ListenServer的快照
+func (s *BootstrapServer) webListeners() map[string]flux.WebListener {
+	s.listenerMu.RLock()
+	defer s.listenerMu.RUnlock()
+	out := make(map[string]flux.WebListener, len(s.listener))
+	for id, wl := range s.listener {
+		out[id] = wl
+	}
+	return out
+}
+
 // AddContextHookFunc 添加Http与Flux的Context桥接函数
 func (s *BootstrapServer) AddContextHookFunc(f flux.ContextHookFunc) {
 	s.hookFunc = append(s.hookFunc, f)
 }
 
-func (s *BootstrapServer) newEndpointHandler(server flux.WebListener, endpoint *flux.MVCEndpoint) flux.WebHandler {
+func (s *BootstrapServer) newRouterHandler(server flux.WebListener, snapshot *atomic.Value, cors *listener.CORSPolicy) flux.WebHandler {
 	return func(webex flux.ServerWebContext) error {
-		return s.route(webex, server, endpoint)
+		// CORS预检请求，按请求的目标方法查找Endpoint；Endpoint只声明GET/POST时，也能正确响应
+		if listener.IsCORSPreflight(webex.Request()) {
+			if handled, err := s.preflight(webex, snapshot, cors); handled {
+				return err
+			}
+		}
+		route, params, allowed := snapshot.Load().(*router.Router).Find(webex.Method(), webex.URL().Path)
+		if route == nil {
+			if len(allowed) > 0 {
+				webex.ResponseWriter().Header().Set(flux.HeaderAllow, strings.Join(allowed, ", "))
+				return &flux.ServeError{
+					StatusCode: http.StatusMethodNotAllowed,
+					ErrorCode:  flux.ErrorCodeRequestNotAllowed,
+					Message:    flux.ErrorMessageWebServerMethodNotAllowed,
+				}
+			}
+			return server.HandleNotfound(webex)
+		}
+		if len(params) > 0 {
+			webex.SetPathVars(params.Values())
+		}
+		mvce := route.Value.(*routeGroup).Select(webex)
+		if mvce == nil {
+			return server.HandleNotfound(webex)
+		}
+		return s.route(webex, server, mvce, cors)
+	}
+}
+
+// preflight 响应CORS预检请求；目标Endpoint不存在或未启用CORS时，返回false，按普通请求处理
+func (s *BootstrapServer) preflight(webex flux.ServerWebContext, snapshot *atomic.Value, cors *listener.CORSPolicy) (bool, error) {
+	method := strings.ToUpper(webex.HeaderVar(flux.HeaderAccessControlRequestMethod))
+	route, params, _ := snapshot.Load().(*router.Router).Find(method, webex.URL().Path)
+	if route == nil {
+		return false, nil
+	}
+	if len(params) > 0 {
+		webex.SetPathVars(params.Values())
+	}
+	mvce := route.Value.(*routeGroup).Select(webex)
+	if mvce == nil {
+		return false, nil
+	}
+	endpoint, found := mvce.Lookup(s.versionFunc(webex))
+	if !found {
+		return false, nil
+	}
+	policy := cors.WithEndpoint(&endpoint)
+	if !policy.Enabled {
+		return false, nil
+	}
+	if !policy.WritePreflight(webex.ResponseWriter().Header(), webex.Request()) {
+		logger.Trace(webex.RequestId()).Infow("SERVER:ROUTE:CORS_REJECTED",
+			"origin", webex.HeaderVar(flux.HeaderOrigin), "http-pattern", []string{method, webex.URL().Path},
+		)
 	}
+	webex.ResponseWriter().WriteHeader(http.StatusNoContent)
+	return true, nil
 }
 
-func (s *BootstrapServer) selectMVCEndpoint(endpoint *flux.Endpoint) (*flux.MVCEndpoint, bool) {
-	key := ext.MakeEndpointKey(endpoint.HttpMethod, endpoint.HttpPattern)
+func (s *BootstrapServer) selectMVCEndpoint(endpoint *flux.Endpoint) *flux.MVCEndpoint {
+	key := ext.MakeEndpointKeyOf(endpoint)
 	if mve, ok := ext.EndpointByKey(key); ok {
-		return mve, false
+		return mve
 	} else {
-		return ext.RegisterEndpoint(key, endpoint), true
+		return ext.RegisterEndpoint(key, endpoint)
 	}
 }
 
 func (s *BootstrapServer) defaultListener() flux.WebListener {
+	s.listenerMu.RLock()
+	defer s.listenerMu.RUnlock()
 	count := len(s.listener)
 	if count == 0 {
 		return nil
@@ -0,0 +1,362 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/internal"
+	"github.com/bytepowered/flux/flux-node/router"
+	"github.com/labstack/echo/v4"
+	assert2 "github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeWebListener struct {
+	flux.WebListener
+	id     string
+	routes map[string]flux.WebHandler
+}
+
+func newFakeWebListener(id string) *fakeWebListener {
+	return &fakeWebListener{id: id, routes: make(map[string]flux.WebHandler)}
+}
+
+func (f *fakeWebListener) ListenerId() string {
+	return f.id
+}
+
+func (f *fakeWebListener) AddHandler(method, pattern string, h flux.WebHandler, _ ...flux.WebInterceptor) {
+	f.routes[method+"#"+pattern] = h
+}
+
+func (f *fakeWebListener) RemoveHandler(method, pattern string) {
+	delete(f.routes, method+"#"+pattern)
+}
+
+func newTestEndpoint(version, listenerId string) flux.Endpoint {
+	return flux.Endpoint{
+		Version:     version,
+		HttpPattern: "/test/unbind",
+		HttpMethod:  "GET",
+		Service:     flux.Service{Interface: "com.foo.TestService", Method: "get"},
+		EmbeddedAttributes: flux.EmbeddedAttributes{
+			Attributes: []flux.Attribute{{Name: flux.EndpointAttrTagListenerId, Value: listenerId}},
+		},
+	}
+}
+
+func TestOnEndpointEventUnbind(t *testing.T) {
+	assert := assert2.New(t)
+	def, admin := newFakeWebListener(ListenerIdDefault), newFakeWebListener(ListenServerIdAdmin)
+	srv := NewBootstrapServerWith(WithWebListener(def), WithWebListener(admin))
+	// 注册唯一的通配路由
+	assert.Contains(def.routes, "GET#"+routerCatchAllPattern)
+	assert.Contains(admin.routes, "POST#"+routerCatchAllPattern)
+	find := func(id string) *flux.MVCEndpoint {
+		route, _, _ := srv.routers[id].Load().(*router.Router).Find("GET", "/test/unbind")
+		if route == nil {
+			return nil
+		}
+		return route.Value.(*routeGroup).candidates[0].mvce
+	}
+	const key = "GET#/test/unbind"
+	srv.onEndpointEvent(flux.EndpointEvent{EventType: flux.EventTypeAdded, Endpoint: newTestEndpoint("v1", "")})
+	srv.onEndpointEvent(flux.EndpointEvent{EventType: flux.EventTypeAdded, Endpoint: newTestEndpoint("v2", "")})
+	// 批量构建之前，路由表不变
+	assert.Nil(find(ListenerIdDefault))
+	srv.flushRouters()
+	assert.NotNil(find(ListenerIdDefault))
+	// 删除部分版本，保留路由
+	srv.onEndpointEvent(flux.EndpointEvent{EventType: flux.EventTypeRemoved, Endpoint: newTestEndpoint("v1", "")})
+	srv.flushRouters()
+	assert.NotNil(find(ListenerIdDefault))
+	_, ok := ext.EndpointByKey(key)
+	assert.True(ok)
+	// 更新绑定的ListenServer
+	srv.onEndpointEvent(flux.EndpointEvent{EventType: flux.EventTypeUpdated, Endpoint: newTestEndpoint("v2", ListenServerIdAdmin)})
+	srv.flushRouters()
+	assert.Nil(find(ListenerIdDefault))
+	assert.NotNil(find(ListenServerIdAdmin))
+	// 删除全部版本，注销路由并回收
+	srv.onEndpointEvent(flux.EndpointEvent{EventType: flux.EventTypeRemoved, Endpoint: newTestEndpoint("v2", ListenServerIdAdmin)})
+	srv.flushRouters()
+	assert.Nil(find(ListenServerIdAdmin))
+	_, ok = ext.EndpointByKey(key)
+	assert.False(ok)
+	// 重新注册
+	srv.onEndpointEvent(flux.EndpointEvent{EventType: flux.EventTypeAdded, Endpoint: newTestEndpoint("v3", "")})
+	srv.flushRouters()
+	mvce := find(ListenerIdDefault)
+	assert.NotNil(mvce)
+	ep, ok := mvce.Lookup("")
+	assert.True(ok)
+	assert.Equal("v3", ep.Version)
+}
+
+type blockingWebListener struct {
+	*fakeWebListener
+	failed error
+	mu     sync.Mutex
+	stop   chan struct{}
+}
+
+func newBlockingWebListener(id string, failed error) *blockingWebListener {
+	return &blockingWebListener{fakeWebListener: newFakeWebListener(id), failed: failed}
+}
+
+func (b *blockingWebListener) Listen() error {
+	if b.failed != nil {
+		return b.failed
+	}
+	b.mu.Lock()
+	stop := make(chan struct{})
+	b.stop = stop
+	b.mu.Unlock()
+	<-stop
+	return http.ErrServerClosed
+}
+
+func (b *blockingWebListener) Close(_ context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.stop != nil {
+		close(b.stop)
+		b.stop = nil
+	}
+	return nil
+}
+
+func TestWebListenerLifecycle(t *testing.T) {
+	assert := assert2.New(t)
+	srv := NewBootstrapServerWith(WithWebListener(newBlockingWebListener(ListenerIdDefault, nil)))
+	status := func(id string) string {
+		for _, h := range srv.WebListenerHealth() {
+			if h.Id == id {
+				return h.Status
+			}
+		}
+		return ""
+	}
+	assert.Equal(ListenerStatusCreated, status(ListenerIdDefault))
+	// 启动、停止、重新启动
+	errch := make(chan error, 1)
+	_, err := srv.startListener(ListenerIdDefault, errch)
+	assert.NoError(err)
+	assert.Equal(ListenerStatusRunning, status(ListenerIdDefault))
+	time.Sleep(10 * time.Millisecond)
+	assert.NoError(srv.StopWebListener(context.Background(), ListenerIdDefault))
+	assert.Equal(ListenerStatusStopped, status(ListenerIdDefault))
+	assert.NoError(srv.StartWebListener(ListenerIdDefault))
+	assert.Equal(ListenerStatusRunning, status(ListenerIdDefault))
+	assert.Empty(errch)
+	// 启动失败的错误通知到Bootstrap
+	failed := errors.New("address in use")
+	srv.AddWebListener("extra", newBlockingWebListener("extra", failed))
+	_, err = srv.startListener("extra", errch)
+	assert.NoError(err)
+	assert.Equal(failed, <-errch)
+	assert.Equal(ListenerStatusFailed, status("extra"))
+	assert.Equal(failed, srv.StartWebListener("extra"))
+	// 运行时启动的ListenServer异常退出，记录退出错误
+	crash := newBlockingWebListener("crash", nil)
+	srv.AddWebListener("crash", crash)
+	assert.NoError(srv.StartWebListener("crash"))
+	assert.NoError(crash.Close(context.Background()))
+	assert.Eventually(func() bool {
+		return status("crash") == ListenerStatusFailed
+	}, time.Second, 5*time.Millisecond)
+	for _, h := range srv.WebListenerHealth() {
+		if h.Id == "crash" {
+			assert.Equal(http.ErrServerClosed.Error(), h.Error)
+		}
+	}
+	assert.Empty(errch)
+	// 不允许通过管理接口停止Admin ListenServer
+	srv.AddWebListener(ListenServerIdAdmin, newBlockingWebListener(ListenServerIdAdmin, nil))
+	assert.NoError(srv.StartWebListener(ListenServerIdAdmin))
+	assert.Error(srv.stopWebListenerByAdmin(context.Background(), "ADMIN"))
+	assert.Equal(ListenerStatusRunning, status(ListenServerIdAdmin))
+	assert.NoError(srv.stopWebListenerByAdmin(context.Background(), "crash"))
+	assert.NoError(srv.StopWebListener(context.Background(), ListenServerIdAdmin))
+	// 删除
+	assert.Error(srv.RemoveWebListener(context.Background(), ListenerIdDefault))
+	assert.NoError(srv.RemoveWebListener(context.Background(), "extra"))
+	_, ok := srv.WebListenerById("extra")
+	assert.False(ok)
+}
+
+func TestWebListenerRestoreRoutes(t *testing.T) {
+	assert := assert2.New(t)
+	srv := NewBootstrapServerWith(WithWebListener(newFakeWebListener(ListenerIdDefault)))
+	srv.onEndpointEvent(flux.EndpointEvent{EventType: flux.EventTypeAdded, Endpoint: newTestEndpoint("v1", "late")})
+	srv.flushRouters()
+	// ListenServer运行时添加后，恢复已绑定的路由
+	srv.AddWebListener("late", newFakeWebListener("late"))
+	route, _, _ := srv.routers["late"].Load().(*router.Router).Find("GET", "/test/unbind")
+	assert.NotNil(route)
+	srv.onEndpointEvent(flux.EndpointEvent{EventType: flux.EventTypeRemoved, Endpoint: newTestEndpoint("v1", "late")})
+}
+
+func TestOnDiscoveryEndpointEventSameVersionHosts(t *testing.T) {
+	assert := assert2.New(t)
+	srv := NewBootstrapServerWith(WithWebListener(newFakeWebListener(ListenerIdDefault)))
+	source := DiscoverySource{Id: "tenants", Policy: flux.MergePolicyOverride}
+	newEndpoint := func(host string) flux.Endpoint {
+		ep := newTestEndpoint("v1", "")
+		ep.HttpPattern = "/test/tenant"
+		ep.Attributes = append(ep.Attributes, flux.Attribute{Name: flux.EndpointAttrTagRouteHost, Value: []string{host}})
+		return ep
+	}
+	find := func() []string {
+		srv.flushRouters()
+		route, _, _ := srv.routers[ListenerIdDefault].Load().(*router.Router).Find("GET", "/test/tenant")
+		if route == nil {
+			return nil
+		}
+		hosts := make([]string, 0, 2)
+		for _, c := range route.Value.(*routeGroup).candidates {
+			hosts = append(hosts, c.hosts...)
+		}
+		return hosts
+	}
+	// 不同Host的相同版本，为不同的Endpoint定义
+	a, b := newEndpoint("a.com"), newEndpoint("b.com")
+	srv.onDiscoveryEndpointEvent(DiscoveryEndpointEvent{Source: source, Event: flux.EndpointEvent{EventType: flux.EventTypeAdded, Endpoint: a}})
+	srv.onDiscoveryEndpointEvent(DiscoveryEndpointEvent{Source: source, Event: flux.EndpointEvent{EventType: flux.EventTypeAdded, Endpoint: b}})
+	assert.Equal([]string{"a.com", "b.com"}, find())
+	// 更新其中一个Host的定义，不影响另一个
+	b.Service.Method = "update"
+	srv.onDiscoveryEndpointEvent(DiscoveryEndpointEvent{Source: source, Event: flux.EndpointEvent{EventType: flux.EventTypeUpdated, Endpoint: b}})
+	assert.Equal([]string{"a.com", "b.com"}, find())
+	mvce, ok := ext.EndpointByKey(ext.MakeEndpointKeyOf(&b))
+	if assert.True(ok) {
+		ep, _ := mvce.Lookup("v1")
+		assert.Equal("update", ep.Service.Method)
+	}
+	srv.onDiscoveryEndpointEvent(DiscoveryEndpointEvent{Source: source, Event: flux.EndpointEvent{EventType: flux.EventTypeRemoved, Endpoint: a}})
+	assert.Equal([]string{"b.com"}, find())
+	srv.onDiscoveryEndpointEvent(DiscoveryEndpointEvent{Source: source, Event: flux.EndpointEvent{EventType: flux.EventTypeRemoved, Endpoint: b}})
+	assert.Nil(find())
+	assert.Empty(srv.tables[ListenerIdDefault])
+}
+
+func TestRouteGroupSelect(t *testing.T) {
+	assert := assert2.New(t)
+	srv := NewBootstrapServerWith(WithWebListener(newFakeWebListener(ListenerIdDefault)))
+	newEndpoint := func(version string, attrs ...flux.Attribute) flux.Endpoint {
+		ep := newTestEndpoint(version, "")
+		ep.HttpPattern = "/test/vhost"
+		ep.Attributes = append(ep.Attributes, attrs...)
+		return ep
+	}
+	endpoints := []flux.Endpoint{
+		newEndpoint("default"),
+		newEndpoint("wildcard", flux.Attribute{Name: flux.EndpointAttrTagRouteHost, Value: []string{"*.brand.com"}}),
+		newEndpoint("exact", flux.Attribute{Name: flux.EndpointAttrTagRouteHost, Value: []string{"shop.brand.com"}}),
+		newEndpoint("tenant", flux.Attribute{Name: flux.EndpointAttrTagRouteMatch, Value: []string{"header:X-Tenant=acme"}}),
+	}
+	for _, ep := range endpoints {
+		srv.onEndpointEvent(flux.EndpointEvent{EventType: flux.EventTypeAdded, Endpoint: ep})
+	}
+	srv.flushRouters()
+	route, _, _ := srv.routers[ListenerIdDefault].Load().(*router.Router).Find("GET", "/test/vhost")
+	group := route.Value.(*routeGroup)
+	assert.Equal(len(endpoints), len(group.candidates))
+	cases := []struct {
+		host, tenant, version string
+	}{
+		{"shop.brand.com", "", "exact"},
+		{"news.brand.com:8080", "", "wildcard"},
+		{"other.com", "", "default"},
+		{"other.com", "acme", "tenant"},
+		{"shop.brand.com", "acme", "exact"},
+	}
+	for _, c := range cases {
+		request := httptest.NewRequest("GET", "http://"+c.host+"/test/vhost", nil)
+		if c.tenant != "" {
+			request.Header.Set("X-Tenant", c.tenant)
+		}
+		webex := internal.NewServeWebContext(echo.New().NewContext(request, httptest.NewRecorder()), "vhost", nil)
+		mvce := group.Select(webex)
+		if assert.NotNil(mvce, c.host) {
+			ep, _ := mvce.Lookup("")
+			assert.Equal(c.version, ep.Version, c.host)
+		}
+	}
+	for _, ep := range endpoints {
+		srv.onEndpointEvent(flux.EndpointEvent{EventType: flux.EventTypeRemoved, Endpoint: ep})
+	}
+}
+
+func TestShutdownDrainsInflight(t *testing.T) {
+	assert := assert2.New(t)
+	srv := NewBootstrapServerWith(WithWebListener(newBlockingWebListener(ListenerIdDefault, nil)))
+	assert.NoError(srv.StartWebListener(ListenerIdDefault))
+	// 模拟执行中的请求
+	atomic.AddInt64(&srv.dispatcher.inflight, 1)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		assert.True(srv.IsDraining())
+		atomic.AddInt64(&srv.dispatcher.inflight, -1)
+	}()
+	start := time.Now()
+	assert.NoError(srv.Shutdown(context.Background()))
+	assert.True(time.Since(start) >= 50*time.Millisecond)
+	assert.Equal(int64(0), srv.dispatcher.Inflight())
+	// 超时返回未完成的请求数量
+	atomic.AddInt64(&srv.dispatcher.inflight, 2)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.Equal(int64(2), srv.dispatcher.AwaitInflight(ctx))
+}
+
+func TestCORSPreflight(t *testing.T) {
+	assert := assert2.New(t)
+	def := newFakeWebListener(ListenerIdDefault)
+	srv := NewBootstrapServerWith(WithWebListener(def), WithVersionLookupFunc(func(webex flux.ServerWebContext) string {
+		return webex.HeaderVar(DefaultHttpHeaderVersion)
+	}))
+	ep := newTestEndpoint("v1", "")
+	ep.HttpPattern = "/test/cors/{id}"
+	ep.Attributes = append(ep.Attributes,
+		flux.Attribute{Name: flux.EndpointAttrTagCORSOrigins, Value: []string{"https://*.brand.com"}},
+		flux.Attribute{Name: flux.EndpointAttrTagCORSCredentials, Value: true},
+		flux.Attribute{Name: flux.EndpointAttrTagCORSMaxAge, Value: 600},
+	)
+	srv.onEndpointEvent(flux.EndpointEvent{EventType: flux.EventTypeAdded, Endpoint: ep})
+	defer srv.onEndpointEvent(flux.EndpointEvent{EventType: flux.EventTypeRemoved, Endpoint: ep})
+	srv.flushRouters()
+	preflight := func(origin, method string) (*httptest.ResponseRecorder, error) {
+		request := httptest.NewRequest(http.MethodOptions, "http://gw.com/test/cors/1", nil)
+		request.Header.Set(flux.HeaderOrigin, origin)
+		request.Header.Set(flux.HeaderAccessControlRequestMethod, method)
+		request.Header.Set(flux.HeaderAccessControlRequestHeaders, "X-Token")
+		recorder := httptest.NewRecorder()
+		webex := internal.NewServeWebContext(echo.New().NewContext(request, recorder), "cors", nil)
+		return recorder, def.routes[http.MethodOptions+"#"+routerCatchAllPattern](webex)
+	}
+	// Endpoint只声明GET，也能响应预检请求
+	recorder, err := preflight("https://shop.brand.com", "GET")
+	assert.NoError(err)
+	assert.Equal(http.StatusNoContent, recorder.Code)
+	assert.Equal("https://shop.brand.com", recorder.Header().Get(flux.HeaderAccessControlAllowOrigin))
+	assert.Equal("true", recorder.Header().Get(flux.HeaderAccessControlAllowCredentials))
+	assert.Equal("X-Token", recorder.Header().Get(flux.HeaderAccessControlAllowHeaders))
+	assert.Equal("600", recorder.Header().Get(flux.HeaderAccessControlMaxAge))
+	// Origin不允许
+	recorder, err = preflight("https://evil.com", "GET")
+	assert.NoError(err)
+	assert.Equal(http.StatusNoContent, recorder.Code)
+	assert.Empty(recorder.Header().Get(flux.HeaderAccessControlAllowOrigin))
+	// 目标方法没有路由，按普通请求处理
+	_, err = preflight("https://shop.brand.com", "DELETE")
+	if serr, ok := err.(*flux.ServeError); assert.True(ok) {
+		assert.Equal(http.StatusMethodNotAllowed, serr.StatusCode)
+	}
+}
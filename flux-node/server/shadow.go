@@ -0,0 +1,206 @@
+package server
+
+import (
+	"bytes"
+	goctx "context"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/internal"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/bytepowered/flux/flux-node/transporter"
+	"github.com/labstack/echo/v4"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// 同时执行的镜像请求上限，超过时丢弃镜像请求
+	shadowMaxInflight = 256
+	// 停机时检查镜像请求是否完成的间隔
+	shadowAwaitInterval = 10 * time.Millisecond
+)
+
+// 镜像请求与主请求响应的比对结果
+const (
+	ShadowResultMatch   = "match"
+	ShadowResultStatus  = "status"
+	ShadowResultBody    = "body"
+	ShadowResultError   = "error"
+	ShadowResultDropped = "dropped"
+)
+
+// ShadowMirror 按Endpoint配置的百分比，将请求异步镜像到影子服务；
+// 影子服务的响应结果被丢弃，仅与主请求的响应结果比对，比对结果记录到Metrics。
+type ShadowMirror struct {
+	metrics  *Metrics
+	engine   *echo.Echo
+	inflight chan struct{}
+	closed   int32
+}
+
+// shadowTask 镜像请求的数据快照；主请求结束后，Context会被回收复用，镜像请求不可以引用Context。
+type shadowTask struct {
+	requestId  string
+	request    *http.Request
+	pathVars   url.Values
+	attributes map[string]interface{}
+	endpoint   flux.Endpoint
+	primary    *flux.ResponseBody
+}
+
+func NewShadowMirror(metrics *Metrics) *ShadowMirror {
+	return &ShadowMirror{
+		metrics:  metrics,
+		engine:   echo.New(),
+		inflight: make(chan struct{}, shadowMaxInflight),
+	}
+}
+
+// Mirror 在主请求完成后调用，按百分比采样并异步执行镜像请求；不阻塞主请求。
+func (m *ShadowMirror) Mirror(ctx *flux.Context) {
+	endpoint := ctx.Endpoint()
+	serviceId := endpoint.GetAttr(flux.EndpointAttrTagShadowService).GetString()
+	if serviceId == "" {
+		return
+	}
+	percent := endpoint.GetAttr(flux.EndpointAttrTagShadowPercent).GetFloat64()
+	if percent <= 0 || rand.Float64()*100 >= percent {
+		return
+	}
+	service, ok := ext.ServiceByID(serviceId)
+	if !ok {
+		logger.TraceContext(ctx).Warnw("SERVER:SHADOW:SERVICE_NOT_FOUND", "shadow-service-id", serviceId)
+		return
+	}
+	proto, uri, method := service.RpcProto(), service.Interface, service.Method
+	select {
+	case m.inflight <- struct{}{}:
+	default:
+		m.metrics.ShadowDiff.WithLabelValues(proto, uri, method, ShadowResultDropped).Inc()
+		return
+	}
+	// 停机后不再执行镜像请求
+	if atomic.LoadInt32(&m.closed) == 1 {
+		<-m.inflight
+		m.metrics.ShadowDiff.WithLabelValues(proto, uri, method, ShadowResultDropped).Inc()
+		return
+	}
+	task, err := newShadowTask(ctx, service)
+	if err != nil {
+		<-m.inflight
+		logger.TraceContext(ctx).Warnw("SERVER:SHADOW:CAPTURE_REQUEST", "error", err)
+		return
+	}
+	go func() {
+		defer func() {
+			<-m.inflight
+			if r := recover(); r != nil {
+				logger.Trace(task.requestId).Errorw("SERVER:SHADOW:PANIC", "error", r)
+			}
+		}()
+		result := m.invoke(task)
+		m.metrics.ShadowDiff.WithLabelValues(proto, uri, method, result).Inc()
+		logger.Trace(task.requestId).Infow("SERVER:SHADOW:COMPLETED", "shadow-service-id", serviceId, "result", result)
+	}()
+}
+
+// Close 停止接收新的镜像请求，等待执行中的镜像请求全部完成，或者Context超时；返回未完成的镜像请求数量
+func (m *ShadowMirror) Close(ctx goctx.Context) int {
+	atomic.StoreInt32(&m.closed, 1)
+	ticker := time.NewTicker(shadowAwaitInterval)
+	defer ticker.Stop()
+	for {
+		count := len(m.inflight)
+		if count <= 0 {
+			return 0
+		}
+		select {
+		case <-ctx.Done():
+			return count
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *ShadowMirror) invoke(task *shadowTask) string {
+	echoc := m.engine.NewContext(task.request, &shadowResponseWriter{header: make(http.Header)})
+	webex := internal.NewServeWebContext(echoc, task.requestId, nil)
+	webex.SetPathVars(task.pathVars)
+	ctx := flux.NewContext()
+	ctx.Reset(webex, &task.endpoint)
+	ctx.SetLogger(logger.Trace(task.requestId))
+	for k, v := range task.attributes {
+		ctx.SetAttribute(k, v)
+	}
+	response, serr := transporter.DoInvokeCodec(ctx, task.endpoint.Service)
+	return diffShadowResponse(task.primary, response, serr)
+}
+
+func newShadowTask(ctx *flux.Context, service flux.Service) (*shadowTask, error) {
+	reader, err := ctx.BodyReader()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	body, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	request := ctx.Request().Clone(goctx.Background())
+	request.Body = ioutil.NopCloser(bytes.NewReader(body))
+	request.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
+	request.Form, request.PostForm, request.MultipartForm = nil, nil, nil
+	endpoint := *ctx.Endpoint()
+	endpoint.Service = service
+	return &shadowTask{
+		requestId:  ctx.RequestId(),
+		request:    request,
+		pathVars:   ctx.PathVars(),
+		attributes: ctx.Attributes(),
+		endpoint:   endpoint,
+		primary:    ctx.Response(),
+	}, nil
+}
+
+// diffShadowResponse 比对主请求与镜像请求的响应结果；主请求响应为nil时，表示主请求失败。
+func diffShadowResponse(primary, shadow *flux.ResponseBody, serr *flux.ServeError) string {
+	if serr != nil {
+		if primary == nil {
+			return ShadowResultMatch
+		}
+		return ShadowResultError
+	}
+	if primary == nil || primary.StatusCode != shadow.StatusCode {
+		return ShadowResultStatus
+	}
+	pbytes, perr := common.SerializeObject(primary.Body)
+	sbytes, berr := common.SerializeObject(shadow.Body)
+	if perr != nil || berr != nil || !bytes.Equal(pbytes, sbytes) {
+		return ShadowResultBody
+	}
+	return ShadowResultMatch
+}
+
+// shadowResponseWriter 丢弃镜像请求的响应数据
+type shadowResponseWriter struct {
+	header http.Header
+}
+
+func (w *shadowResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *shadowResponseWriter) Write(data []byte) (int, error) {
+	return len(data), nil
+}
+
+func (w *shadowResponseWriter) WriteHeader(int) {
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"bytes"
+	goctx "context"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/internal"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	assert2 "github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type fakeShadowTransporter struct {
+	flux.Transporter
+	bodies chan string
+}
+
+func (f *fakeShadowTransporter) InvokeCodec(ctx *flux.Context, _ flux.Service) (*flux.ResponseBody, *flux.ServeError) {
+	reader, _ := ctx.BodyReader()
+	data, _ := ioutil.ReadAll(reader)
+	defer func() { f.bodies <- string(data) }()
+	return &flux.ResponseBody{StatusCode: 200, Body: "shadow:" + ctx.PathVar("id")}, nil
+}
+
+func newShadowMirror(name string) *ShadowMirror {
+	return NewShadowMirror(&Metrics{ShadowDiff: prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: name,
+	}, []string{"ProtoName", "Interface", "Method", "Result"})})
+}
+
+func newShadowContext(serviceId string, body []byte) *flux.Context {
+	request := httptest.NewRequest("POST", "http://mocking/users/123", bytes.NewReader(body))
+	request.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
+	webex := internal.NewServeWebContext(echo.New().NewContext(request, httptest.NewRecorder()), "shadow-id", nil)
+	webex.SetPathVars(map[string][]string{"id": {"123"}})
+	ctx := flux.NewContext()
+	ctx.Reset(webex, &flux.Endpoint{EmbeddedAttributes: flux.EmbeddedAttributes{Attributes: []flux.Attribute{
+		{Name: flux.EndpointAttrTagShadowService, Value: serviceId},
+		{Name: flux.EndpointAttrTagShadowPercent, Value: 100},
+	}}})
+	ctx.SetResponse(&flux.ResponseBody{StatusCode: 200, Body: "shadow:123"})
+	return ctx
+}
+
+func registerShadowService(proto, serviceId string, transporter flux.Transporter) {
+	ext.RegisterTransporter(proto, transporter)
+	ext.RegisterService(flux.Service{ServiceId: serviceId, Interface: "/shadow",
+		EmbeddedAttributes: flux.EmbeddedAttributes{Attributes: []flux.Attribute{
+			{Name: flux.ServiceAttrTagRpcProto, Value: proto},
+		}},
+	})
+}
+
+func TestShadowMirror(t *testing.T) {
+	assert := assert2.New(t)
+	fake := &fakeShadowTransporter{bodies: make(chan string, 1)}
+	registerShadowService("SHADOWTEST", "shadow.test", fake)
+	mirror := newShadowMirror("test_shadow_total")
+	ctx := newShadowContext("shadow.test", []byte("name=flux"))
+	mirror.Mirror(ctx)
+	// Context被回收复用后，镜像请求不受影响
+	ctx.Reset(ctx.ServerWebContext, &flux.Endpoint{})
+	select {
+	case data := <-fake.bodies:
+		assert.Equal("name=flux", data)
+	case <-time.After(time.Second):
+		assert.Fail("shadow request timeout")
+	}
+}
+
+func TestShadowMirrorClose(t *testing.T) {
+	assert := assert2.New(t)
+	fake := &fakeShadowTransporter{bodies: make(chan string)}
+	registerShadowService("SHADOWCLOSE", "shadow.close", fake)
+	mirror := newShadowMirror("test_shadow_close_total")
+	mirror.Mirror(newShadowContext("shadow.close", []byte("first")))
+	// 镜像请求未完成时，等待超时
+	timeout, cancel := goctx.WithTimeout(goctx.Background(), 30*time.Millisecond)
+	defer cancel()
+	assert.Equal(1, mirror.Close(timeout))
+	done := make(chan int, 1)
+	go func() {
+		done <- mirror.Close(goctx.Background())
+	}()
+	select {
+	case <-done:
+		assert.Fail("close returned before shadow request completed")
+	case <-time.After(30 * time.Millisecond):
+	}
+	assert.Equal("first", <-fake.bodies)
+	assert.Equal(0, <-done)
+	// 停止后，不再执行镜像请求
+	mirror.Mirror(newShadowContext("shadow.close", []byte("second")))
+	assert.Equal(0, len(mirror.inflight))
+	select {
+	case data := <-fake.bodies:
+		assert.Fail("unexpected shadow request", data)
+	case <-time.After(30 * time.Millisecond):
+	}
+}
+
+func TestDiffShadowResponse(t *testing.T) {
+	assert := assert2.New(t)
+	ok := &flux.ResponseBody{StatusCode: 200, Body: `{"id":1}`}
+	serr := &flux.ServeError{StatusCode: 500}
+	assert.Equal(ShadowResultMatch, diffShadowResponse(ok, &flux.ResponseBody{StatusCode: 200, Body: `{"id":1}`}, nil))
+	assert.Equal(ShadowResultBody, diffShadowResponse(ok, &flux.ResponseBody{StatusCode: 200, Body: `{"id":2}`}, nil))
+	assert.Equal(ShadowResultStatus, diffShadowResponse(ok, &flux.ResponseBody{StatusCode: 404}, nil))
+	assert.Equal(ShadowResultStatus, diffShadowResponse(nil, ok, nil))
+	assert.Equal(ShadowResultError, diffShadowResponse(ok, nil, serr))
+	assert.Equal(ShadowResultMatch, diffShadowResponse(nil, nil, serr))
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/bytepowered/flux/flux-node/listener"
+	"github.com/bytepowered/flux/flux-pkg"
+	"strings"
+)
+
+// routeGroup 相同Method和Pattern的路由候选列表，按匹配精确度从高到低排序；
+// 候选路由通过Host和请求谓词区分，用于多域名、多租户共享相同路径。
+type routeGroup struct {
+	method     string
+	pattern    string
+	candidates []*routeCandidate
+}
+
+type routeCandidate struct {
+	key        string
+	hosts      []string
+	predicates []routePredicate
+	mvce       *flux.MVCEndpoint
+}
+
+// routePredicate 请求谓词：scope:key=value；value为*时，表示参数存在即匹配
+type routePredicate struct {
+	scope string
+	key   string
+	value string
+}
+
+func newRoutePredicate(expr string) (routePredicate, error) {
+	idx := strings.Index(expr, "=")
+	if idx <= 0 {
+		return routePredicate{}, fmt.Errorf("illegal route predicate: %s", expr)
+	}
+	scope, key, ok := fluxpkg.LookupParseExpr(strings.TrimSpace(expr[:idx]))
+	if !ok {
+		return routePredicate{}, fmt.Errorf("illegal route predicate: %s", expr)
+	}
+	return routePredicate{scope: scope, key: key, value: strings.TrimSpace(expr[idx+1:])}, nil
+}
+
+func (p routePredicate) test(webex flux.ServerWebContext) bool {
+	value := common.LookupWebValue(webex, p.scope, p.key)
+	return value != "" && (p.value == "*" || p.value == value)
+}
+
+func newRouteGroup(method, pattern string) *routeGroup {
+	return &routeGroup{method: method, pattern: pattern, candidates: make([]*routeCandidate, 0, 1)}
+}
+
+// add 添加路由候选，需按Key顺序添加以保证精确度相同时选择结果稳定；请求谓词格式错误时返回错误
+func (g *routeGroup) add(key string, entry routeEntry) error {
+	predicates := make([]routePredicate, 0, len(entry.matches))
+	for _, expr := range entry.matches {
+		p, err := newRoutePredicate(expr)
+		if nil != err {
+			return err
+		}
+		predicates = append(predicates, p)
+	}
+	g.candidates = append(g.candidates, &routeCandidate{
+		key: key, hosts: entry.hosts, predicates: predicates, mvce: entry.mvce,
+	})
+	return nil
+}
+
+// Select 返回与请求匹配的最精确的路由；无匹配时返回nil。
+// 精确度：精确Host > 通配Host(后缀越长越精确) > 无Host；相同时，谓词数量多者优先。
+func (g *routeGroup) Select(webex flux.ServerWebContext) *flux.MVCEndpoint {
+	host := webex.Host()
+	var selected *routeCandidate
+	bestRank, bestLen := -1, -1
+	for _, c := range g.candidates {
+		rank, length, ok := c.match(webex, host)
+		if !ok {
+			continue
+		}
+		if rank > bestRank || (rank == bestRank && length > bestLen) ||
+			(rank == bestRank && length == bestLen && len(c.predicates) > len(selected.predicates)) {
+			selected, bestRank, bestLen = c, rank, length
+		}
+	}
+	if selected == nil {
+		return nil
+	}
+	return selected.mvce
+}
+
+// match 判断请求是否匹配；返回所匹配Host的精确度等级和长度
+func (c *routeCandidate) match(webex flux.ServerWebContext, host string) (rank int, length int, ok bool) {
+	if len(c.hosts) > 0 {
+		for _, h := range c.hosts {
+			if !listener.MatchHost(h, host) {
+				continue
+			}
+			r := 2
+			if strings.HasPrefix(h, "*.") {
+				r = 1
+			}
+			if r > rank || (r == rank && len(h) > length) {
+				rank, length = r, len(h)
+			}
+		}
+		if rank == 0 {
+			return 0, 0, false
+		}
+	}
+	for _, p := range c.predicates {
+		if !p.test(webex) {
+			return 0, 0, false
+		}
+	}
+	return rank, length, true
+}
@@ -0,0 +1,95 @@
+package flux
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"time"
+)
+
+// TLSPeer 请求客户端的TLS证书信息
+type TLSPeer struct {
+	Subject        string
+	Issuer         string
+	CommonName     string
+	SerialNumber   string
+	DNSNames       []string
+	EmailAddresses []string
+	URIs           []string
+	IPAddresses    []string
+	// 证书的SHA-256指纹(Hex)
+	Fingerprint string
+	NotBefore   time.Time
+	NotAfter    time.Time
+	// 证书是否已通过客户端CA验证
+	Verified bool
+}
+
+// NewTLSPeer 从TLS连接状态中读取客户端证书信息；客户端未提供证书时返回false
+func NewTLSPeer(state *tls.ConnectionState) (TLSPeer, bool) {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return TLSPeer{}, false
+	}
+	cert := state.PeerCertificates[0]
+	sum := sha256.Sum256(cert.Raw)
+	peer := TLSPeer{
+		Subject:        cert.Subject.String(),
+		Issuer:         cert.Issuer.String(),
+		CommonName:     cert.Subject.CommonName,
+		SerialNumber:   cert.SerialNumber.String(),
+		DNSNames:       cert.DNSNames,
+		EmailAddresses: cert.EmailAddresses,
+		URIs:           make([]string, len(cert.URIs)),
+		IPAddresses:    make([]string, len(cert.IPAddresses)),
+		Fingerprint:    hex.EncodeToString(sum[:]),
+		NotBefore:      cert.NotBefore,
+		NotAfter:       cert.NotAfter,
+		Verified:       len(state.VerifiedChains) > 0,
+	}
+	for i, u := range cert.URIs {
+		peer.URIs[i] = u.String()
+	}
+	for i, ip := range cert.IPAddresses {
+		peer.IPAddresses[i] = ip.String()
+	}
+	return peer, true
+}
+
+// SANs 返回证书的全部SubjectAltName
+func (p TLSPeer) SANs() []string {
+	sans := make([]string, 0, len(p.DNSNames)+len(p.EmailAddresses)+len(p.URIs)+len(p.IPAddresses))
+	sans = append(sans, p.DNSNames...)
+	sans = append(sans, p.EmailAddresses...)
+	sans = append(sans, p.URIs...)
+	return append(sans, p.IPAddresses...)
+}
+
+// Lookup 按字段名查找证书信息：subject, issuer, cn, serial, fingerprint, dns, email, uri, ip, san, verified
+func (p TLSPeer) Lookup(key string) (interface{}, bool) {
+	switch key {
+	case "subject":
+		return p.Subject, true
+	case "issuer":
+		return p.Issuer, true
+	case "cn":
+		return p.CommonName, true
+	case "serial":
+		return p.SerialNumber, true
+	case "fingerprint":
+		return p.Fingerprint, true
+	case "dns":
+		return p.DNSNames, true
+	case "email":
+		return p.EmailAddresses, true
+	case "uri":
+		return p.URIs, true
+	case "ip":
+		return p.IPAddresses, true
+	case "san":
+		return p.SANs(), true
+	case "verified":
+		return p.Verified, true
+	default:
+		return nil, false
+	}
+}
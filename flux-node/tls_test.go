@@ -0,0 +1,54 @@
+package flux
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	assert2 "github.com/stretchr/testify/assert"
+	"math/big"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestNewTLSPeer(t *testing.T) {
+	assert := assert2.New(t)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(err)
+	spiffe, _ := url.Parse("spiffe://example.org/partner-a")
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1001),
+		Subject:      pkix.Name{CommonName: "partner-a", Organization: []string{"Partner"}},
+		DNSNames:     []string{"a.partner.com"},
+		URIs:         []*url.URL{spiffe},
+		IPAddresses:  []net.IP{net.ParseIP("10.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	assert.NoError(err)
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(err)
+
+	_, ok := NewTLSPeer(nil)
+	assert.False(ok)
+	_, ok = NewTLSPeer(&tls.ConnectionState{})
+	assert.False(ok)
+
+	peer, ok := NewTLSPeer(&tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}})
+	assert.True(ok)
+	assert.False(peer.Verified)
+	assert.Equal("partner-a", peer.CommonName)
+	assert.Equal("1001", peer.SerialNumber)
+	assert.Len(peer.Fingerprint, 64)
+	assert.Equal([]string{"a.partner.com", "spiffe://example.org/partner-a", "10.0.0.1"}, peer.SANs())
+	v, ok := peer.Lookup("cn")
+	assert.True(ok)
+	assert.Equal("partner-a", v)
+	_, ok = peer.Lookup("unknown")
+	assert.False(ok)
+}
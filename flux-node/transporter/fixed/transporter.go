@@ -0,0 +1,108 @@
+package fixed
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/transporter"
+	"github.com/spf13/cast"
+	"net/http"
+)
+
+// 固定响应的Service属性
+const (
+	// 响应状态码，默认200
+	ServiceAttrTagFixedStatus = flux.ServiceAttrTagFixedStatus
+	// 响应数据；字符串原样返回，其它类型序列化为JSON
+	ServiceAttrTagFixedBody = flux.ServiceAttrTagFixedBody
+	// 响应ContentType，默认application/json
+	ServiceAttrTagFixedContentType = "fixedContentType"
+	// 响应Header键值对
+	ServiceAttrTagFixedHeaders = "fixedHeaders"
+)
+
+func init() {
+	ext.RegisterTransporter(flux.ProtoFixed, NewTransporter())
+}
+
+var (
+	_ flux.Transporter = new(RpcTransporter)
+)
+
+// RpcTransporter 返回Service属性定义的固定响应，不需要后端服务；用于维护页面、应用配置等场景。
+type RpcTransporter struct {
+	writer flux.TransportWriter
+}
+
+func NewTransporter() flux.Transporter {
+	return &RpcTransporter{
+		writer: new(transporter.DefaultTransportWriter),
+	}
+}
+
+func (b *RpcTransporter) Writer() flux.TransportWriter {
+	return b.writer
+}
+
+func (b *RpcTransporter) Transport(ctx *flux.Context) {
+	response, serr := b.InvokeCodec(ctx, ctx.Service())
+	if serr != nil {
+		ctx.Logger().Errorw("TRANSPORTER:FIXED:ERROR", "error", serr)
+		b.writer.WriteError(ctx, serr)
+		return
+	}
+	ctx.SetResponse(response)
+	header := ctx.ResponseWriter().Header()
+	for k, hv := range response.Headers {
+		for _, v := range hv {
+			header.Add(k, v)
+		}
+	}
+	contentType := ctx.Service().GetAttr(ServiceAttrTagFixedContentType).GetString()
+	if contentType == "" {
+		contentType = flux.MIMEApplicationJSONCharsetUTF8
+	}
+	if err := ctx.Write(response.StatusCode, contentType, response.Body.([]byte)); nil != err {
+		ctx.Logger().Errorw("TRANSPORTER:FIXED:WRITE", "error", err)
+	}
+}
+
+func (b *RpcTransporter) InvokeCodec(ctx *flux.Context, service flux.Service) (*flux.ResponseBody, *flux.ServeError) {
+	body, serr := b.Invoke(ctx, service)
+	if serr != nil {
+		return nil, serr
+	}
+	status := service.GetAttr(ServiceAttrTagFixedStatus).GetInt()
+	if status <= 0 {
+		status = http.StatusOK
+	}
+	headers := make(http.Header, 2)
+	for k, v := range cast.ToStringMapString(service.GetAttr(ServiceAttrTagFixedHeaders).Value) {
+		headers.Set(k, v)
+	}
+	return &flux.ResponseBody{
+		StatusCode: status,
+		Headers:    headers,
+		Body:       body,
+	}, nil
+}
+
+func (b *RpcTransporter) Invoke(_ *flux.Context, service flux.Service) (interface{}, *flux.ServeError) {
+	attr, ok := service.GetAttrEx(ServiceAttrTagFixedBody)
+	if !ok || attr.Value == nil {
+		return []byte{}, nil
+	}
+	if text, ok := attr.Value.(string); ok {
+		return []byte(text), nil
+	}
+	bytes, err := common.SerializeObject(attr.Value)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayInternal,
+			Message:    flux.ErrorMessageTransportDecodeResponse,
+			CauseError: err,
+		}
+	}
+	return bytes, nil
+}
@@ -0,0 +1,70 @@
+package fixed
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/internal"
+	"github.com/labstack/echo/v4"
+	assert2 "github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newFixedContext(service flux.Service) (*flux.Context, *httptest.ResponseRecorder) {
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest("GET", "http://gw.com/fixed", nil)
+	webex := internal.NewServeWebContext(echo.New().NewContext(request, recorder), "fixed", nil)
+	ctx := flux.NewContext()
+	ctx.Reset(webex, &flux.Endpoint{Service: service})
+	return ctx, recorder
+}
+
+func newFixedService(attrs ...flux.Attribute) flux.Service {
+	return flux.Service{EmbeddedAttributes: flux.EmbeddedAttributes{
+		Attributes: append([]flux.Attribute{{Name: flux.ServiceAttrTagRpcProto, Value: flux.ProtoFixed}}, attrs...),
+	}}
+}
+
+func TestFixedBody(t *testing.T) {
+	ext.RegisterSerializer(ext.TypeNameSerializerJson, flux.NewJsonSerializer())
+	cases := []struct {
+		name     string
+		body     interface{}
+		expected string
+	}{
+		{name: "string", body: `{"status":"ok"}`, expected: `{"status":"ok"}`},
+		{name: "slice", body: []interface{}{"a", true, 1.5}, expected: `["a",true,1.5]`},
+		{name: "struct", body: struct {
+			Status string `json:"status"`
+		}{Status: "ok"}, expected: `{"status":"ok"}`},
+		{name: "number", body: 12, expected: `12`},
+		{name: "nil", body: nil, expected: ``},
+	}
+	transporter := NewTransporter()
+	for _, c := range cases {
+		service := newFixedService(flux.Attribute{Name: ServiceAttrTagFixedBody, Value: c.body})
+		data, serr := transporter.Invoke(nil, service)
+		assert2.Nil(t, serr, c.name)
+		assert2.Equal(t, c.expected, string(data.([]byte)), c.name)
+	}
+	// 不支持JSON序列化的数据
+	_, serr := transporter.Invoke(nil, newFixedService(flux.Attribute{Name: ServiceAttrTagFixedBody, Value: make(chan int)}))
+	assert2.NotNil(t, serr)
+}
+
+func TestFixedTransport(t *testing.T) {
+	assert := assert2.New(t)
+	ext.RegisterSerializer(ext.TypeNameSerializerJson, flux.NewJsonSerializer())
+	service := newFixedService(
+		flux.Attribute{Name: ServiceAttrTagFixedStatus, Value: 503},
+		flux.Attribute{Name: ServiceAttrTagFixedBody, Value: []interface{}{"maintenance"}},
+		flux.Attribute{Name: ServiceAttrTagFixedHeaders, Value: map[string]interface{}{"Retry-After": "120"}},
+	)
+	ctx, recorder := newFixedContext(service)
+	NewTransporter().Transport(ctx)
+	assert.Equal(http.StatusServiceUnavailable, recorder.Code)
+	assert.Equal(`["maintenance"]`, recorder.Body.String())
+	assert.Equal("120", recorder.Header().Get("Retry-After"))
+	assert.Equal(flux.MIMEApplicationJSONCharsetUTF8, recorder.Header().Get(flux.HeaderContentType))
+}
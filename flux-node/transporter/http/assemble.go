@@ -42,7 +42,7 @@ func DefaultArgumentResolver(service *flux.Service, inURL *url.URL, bodyReader i
 	// 未定义参数，即透传Http请求：Rewrite inRequest path
 	newUrl := &url.URL{
 		Host:       service.Url,
-		Path:       service.Interface,
+		Path:       ExpandPathVars(service.Interface, ctx),
 		Scheme:     service.Scheme,
 		Opaque:     inURL.Opaque,
 		User:       inURL.User,
@@ -70,6 +70,17 @@ func DefaultArgumentResolver(service *flux.Service, inURL *url.URL, bodyReader i
 	return newRequest, err
 }
 
+// ExpandPathVars 使用请求的动态路径参数替换路径中的{name}占位符
+func ExpandPathVars(path string, ctx *flux.Context) string {
+	if !strings.Contains(path, "{") {
+		return path
+	}
+	for name := range ctx.PathVars() {
+		path = strings.ReplaceAll(path, "{"+name+"}", ctx.PathVar(name))
+	}
+	return path
+}
+
 func AssembleHttpValues(arguments []flux.Argument, ctx *flux.Context) (url.Values, error) {
 	values := make(url.Values, len(arguments))
 	for _, arg := range arguments {
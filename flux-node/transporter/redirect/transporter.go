@@ -0,0 +1,169 @@
+package redirect
+
+import (
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/transporter"
+	"github.com/bytepowered/flux/flux-pkg"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// 重定向的Service属性
+const (
+	// 重定向状态码，支持301/302/303/307/308，默认302
+	ServiceAttrTagRedirectStatus = "redirectStatus"
+	// 重定向地址模板：{name} 引用路径参数；{scope:key} 引用LookupExpr表达式的值；Scheme和Host不支持变量
+	ServiceAttrTagRedirectLocation = flux.ServiceAttrTagRedirectLocation
+	// 是否保留原请求的Query参数
+	ServiceAttrTagRedirectKeepQuery = "redirectKeepQuery"
+)
+
+func init() {
+	ext.RegisterTransporter(flux.ProtoRedirect, NewTransporter())
+}
+
+var (
+	_ flux.Transporter = new(RpcTransporter)
+)
+
+// RpcTransporter 按Service属性定义的地址模板返回重定向响应，不需要后端服务。
+type RpcTransporter struct {
+	writer flux.TransportWriter
+}
+
+func NewTransporter() flux.Transporter {
+	return &RpcTransporter{
+		writer: new(transporter.DefaultTransportWriter),
+	}
+}
+
+func (b *RpcTransporter) Writer() flux.TransportWriter {
+	return b.writer
+}
+
+func (b *RpcTransporter) Transport(ctx *flux.Context) {
+	response, serr := b.InvokeCodec(ctx, ctx.Service())
+	if serr != nil {
+		ctx.Logger().Errorw("TRANSPORTER:REDIRECT:ERROR", "error", serr)
+		b.writer.WriteError(ctx, serr)
+		return
+	}
+	ctx.SetResponse(response)
+	location := response.Body.(string)
+	ctx.ResponseWriter().Header().Set(flux.HeaderLocation, location)
+	if err := ctx.Write(response.StatusCode, flux.MIMETextPlainCharsetUTF8, []byte{}); nil != err {
+		ctx.Logger().Errorw("TRANSPORTER:REDIRECT:WRITE", "error", err)
+	}
+}
+
+func (b *RpcTransporter) InvokeCodec(ctx *flux.Context, service flux.Service) (*flux.ResponseBody, *flux.ServeError) {
+	location, serr := b.Invoke(ctx, service)
+	if serr != nil {
+		return nil, serr
+	}
+	headers := make(http.Header, 1)
+	headers.Set(flux.HeaderLocation, location.(string))
+	return &flux.ResponseBody{
+		StatusCode: RedirectStatus(service.GetAttr(ServiceAttrTagRedirectStatus).GetInt()),
+		Headers:    headers,
+		Body:       location,
+	}, nil
+}
+
+// Invoke 返回重定向地址
+func (b *RpcTransporter) Invoke(ctx *flux.Context, service flux.Service) (interface{}, *flux.ServeError) {
+	template := service.GetAttr(ServiceAttrTagRedirectLocation).GetString()
+	if template == "" {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayEndpoint,
+			Message:    flux.ErrorMessageRedirectLocationMissing,
+		}
+	}
+	location, err := ExpandLocation(ctx, template)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusBadRequest,
+			ErrorCode:  flux.ErrorCodeRequestInvalid,
+			Message:    flux.ErrorMessageRedirectLocationInvalid,
+			CauseError: err,
+		}
+	}
+	if service.GetAttr(ServiceAttrTagRedirectKeepQuery).GetBool() {
+		if query := ctx.Request().URL.RawQuery; query != "" {
+			if strings.Contains(location, "?") {
+				location += "&" + query
+			} else {
+				location += "?" + query
+			}
+		}
+	}
+	return location, nil
+}
+
+// RedirectStatus 返回有效的重定向状态码；不支持的状态码返回302
+func RedirectStatus(status int) int {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return status
+	default:
+		return http.StatusFound
+	}
+}
+
+// ExpandLocation 展开重定向地址模板中的变量：{name} 为路径参数，{scope:key} 为LookupExpr表达式；
+// 变量值按所在位置转义：Query之前按路径转义，Query和Fragment中按参数转义。展开后地址的Scheme或Host与模板不一致时，返回错误。
+func ExpandLocation(webex flux.ServerWebContext, template string) (string, error) {
+	var sb, literal strings.Builder
+	inQuery := false
+	for {
+		start := strings.IndexByte(template, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(template[start:], '}')
+		if end < 0 {
+			break
+		}
+		end += start
+		prefix := template[:start]
+		inQuery = inQuery || strings.ContainsAny(prefix, "?#")
+		sb.WriteString(prefix)
+		literal.WriteString(prefix)
+		literal.WriteString("x")
+		name := template[start+1 : end]
+		var value string
+		if scope, key, ok := fluxpkg.LookupParseExpr(name); ok {
+			value = common.LookupWebValue(webex, scope, key)
+		} else {
+			value = webex.PathVar(name)
+		}
+		if inQuery {
+			sb.WriteString(url.QueryEscape(value))
+		} else {
+			sb.WriteString(url.PathEscape(value))
+		}
+		template = template[end+1:]
+	}
+	sb.WriteString(template)
+	literal.WriteString(template)
+	location := sb.String()
+	expect, err := url.Parse(literal.String())
+	if nil != err {
+		return "", fmt.Errorf("invalid redirect location template: %w", err)
+	}
+	actual, err := url.Parse(location)
+	if nil != err {
+		return "", fmt.Errorf("invalid redirect location: %w", err)
+	}
+	if !strings.EqualFold(actual.Scheme, expect.Scheme) || !strings.EqualFold(actual.Host, expect.Host) ||
+		actual.User.String() != expect.User.String() {
+		return "", fmt.Errorf("redirect location host mismatch, location: %s", location)
+	}
+	return location, nil
+}
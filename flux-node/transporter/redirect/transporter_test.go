@@ -0,0 +1,40 @@
+package redirect
+
+import (
+	"github.com/bytepowered/flux/flux-node/internal"
+	"github.com/labstack/echo/v4"
+	assert2 "github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestExpandLocation(t *testing.T) {
+	cases := []struct {
+		template string
+		name     string
+		query    string
+		expected string
+		invalid  bool
+	}{
+		{template: "/static/{name}?lang={query:lang}", name: "a.html", query: "lang=zh", expected: "/static/a.html?lang=zh"},
+		{template: "/static/{name}?lang={query:lang}", name: "a/b?c", query: "lang=" + url.QueryEscape("zh&x=1"), expected: "/static/a%2Fb%3Fc?lang=zh%26x%3D1"},
+		{template: "https://www.brand.com/{name}", name: "@evil.com", expected: "https://www.brand.com/@evil.com"},
+		// 路径参数不能改变Host
+		{template: "/{name}", name: "/evil.com", expected: "/%2Fevil.com"},
+		{template: "{name}", name: "https://evil.com", invalid: true},
+		{template: "https://{name}/path", name: "evil.com", invalid: true},
+	}
+	for _, c := range cases {
+		request := httptest.NewRequest("GET", "http://gw.com/redirect?"+c.query, nil)
+		webex := internal.NewServeWebContext(echo.New().NewContext(request, httptest.NewRecorder()), "redirect", nil)
+		webex.SetPathVars(url.Values{"name": []string{c.name}})
+		location, err := ExpandLocation(webex, c.template)
+		if c.invalid {
+			assert2.Error(t, err, c.template)
+			continue
+		}
+		assert2.NoError(t, err, c.template)
+		assert2.Equal(t, c.expected, location, c.template)
+	}
+}
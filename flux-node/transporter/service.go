@@ -23,6 +23,7 @@ func DoTransport(ctx *flux.Context, transport flux.Transporter) {
 		transport.Writer().WriteError(ctx, serr)
 	} else {
 		fluxpkg.AssertNotNil(response, "exchange: <response> must-not nil, request-id: "+ctx.RequestId())
+		ctx.SetResponse(response)
 		for k, v := range response.Attachments {
 			ctx.SetAttribute(k, v)
 		}
@@ -76,6 +77,12 @@ func (r *DefaultTransportWriter) WriteError(ctx *flux.Context, err *flux.ServeEr
 		"message": err.Message,
 		"error":   cast.ToString(err.CauseError),
 	})
+	header := ctx.ResponseWriter().Header()
+	for k, hv := range err.Header {
+		for _, v := range hv {
+			header.Add(k, v)
+		}
+	}
 	r.write(ctx, err.StatusCode, bytes)
 }
 
@@ -0,0 +1,195 @@
+package static
+
+import (
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/transporter"
+	"github.com/spf13/cast"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"sync"
+	"time"
+)
+
+// 静态文件的Service属性
+const (
+	// 本地文件目录
+	ServiceAttrTagStaticRoot = flux.ServiceAttrTagStaticRoot
+	// 通过RegisterFileSystem注册的文件系统名称，优先于staticRoot
+	ServiceAttrTagStaticFs = flux.ServiceAttrTagStaticFs
+	// 固定返回的文件；未指定时，按路径参数查找文件
+	ServiceAttrTagStaticFile = "staticFile"
+	// 请求目录时返回的索引文件，默认index.html
+	ServiceAttrTagStaticIndex = "staticIndex"
+	// 文件路径的路径参数名，默认为尾部通配符参数 *
+	ServiceAttrTagStaticPathVar = "staticPathVar"
+	// 缓存时间，例如：10m, 1h；为空时不设置Cache-Control
+	ServiceAttrTagStaticMaxAge = "staticMaxAge"
+)
+
+const (
+	DefaultIndexFile = "index.html"
+	DefaultPathVar   = "*"
+)
+
+var (
+	fileSystems   = make(map[string]http.FileSystem, 2)
+	fileSystemsMu sync.RWMutex
+)
+
+// RegisterFileSystem 注册命名的文件系统，用于提供内嵌目录等非本地目录的静态文件
+func RegisterFileSystem(name string, fs http.FileSystem) {
+	fileSystemsMu.Lock()
+	defer fileSystemsMu.Unlock()
+	fileSystems[name] = fs
+}
+
+// FileSystemByName 返回命名的文件系统
+func FileSystemByName(name string) (http.FileSystem, bool) {
+	fileSystemsMu.RLock()
+	defer fileSystemsMu.RUnlock()
+	fs, ok := fileSystems[name]
+	return fs, ok
+}
+
+func init() {
+	ext.RegisterTransporter(flux.ProtoStatic, NewTransporter())
+}
+
+var (
+	_ flux.Transporter = new(RpcTransporter)
+)
+
+// RpcTransporter 从本地目录或注册的文件系统返回静态文件，不需要后端服务；
+// 支持ETag/Last-Modified条件请求、Range请求和Cache-Control缓存头。
+type RpcTransporter struct {
+	writer flux.TransportWriter
+}
+
+func NewTransporter() flux.Transporter {
+	return &RpcTransporter{
+		writer: new(transporter.DefaultTransportWriter),
+	}
+}
+
+func (b *RpcTransporter) Writer() flux.TransportWriter {
+	return b.writer
+}
+
+func (b *RpcTransporter) Transport(ctx *flux.Context) {
+	service := ctx.Service()
+	file, stat, serr := b.open(ctx, service)
+	if serr != nil {
+		ctx.Logger().Warnw("TRANSPORTER:STATIC:OPEN", "error", serr)
+		b.writer.WriteError(ctx, serr)
+		return
+	}
+	defer file.Close()
+	header := ctx.ResponseWriter().Header()
+	if maxAge := cast.ToDuration(service.GetAttr(ServiceAttrTagStaticMaxAge).GetString()); maxAge > 0 {
+		header.Set(flux.HeaderCacheControl, fmt.Sprintf("public, max-age=%d", int64(maxAge/time.Second)))
+	}
+	header.Set(flux.HeaderETag, fmt.Sprintf(`W/"%x-%x"`, stat.ModTime().Unix(), stat.Size()))
+	ctx.SetResponse(&flux.ResponseBody{StatusCode: http.StatusOK, Headers: header.Clone()})
+	http.ServeContent(ctx.ResponseWriter(), ctx.Request(), stat.Name(), stat.ModTime(), file)
+}
+
+func (b *RpcTransporter) InvokeCodec(ctx *flux.Context, service flux.Service) (*flux.ResponseBody, *flux.ServeError) {
+	body, serr := b.Invoke(ctx, service)
+	if serr != nil {
+		return nil, serr
+	}
+	return &flux.ResponseBody{
+		StatusCode: http.StatusOK,
+		Headers:    make(http.Header, 0),
+		Body:       body,
+	}, nil
+}
+
+// Invoke 返回静态文件的内容
+func (b *RpcTransporter) Invoke(ctx *flux.Context, service flux.Service) (interface{}, *flux.ServeError) {
+	file, _, serr := b.open(ctx, service)
+	if serr != nil {
+		return nil, serr
+	}
+	defer file.Close()
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayInternal,
+			Message:    flux.ErrorMessageTransportDecodeResponse,
+			CauseError: err,
+		}
+	}
+	return data, nil
+}
+
+// open 打开请求的文件；请求目录时打开目录下的索引文件
+func (b *RpcTransporter) open(ctx *flux.Context, service flux.Service) (http.File, os.FileInfo, *flux.ServeError) {
+	fs, ok := b.lookupFileSystem(service)
+	if !ok {
+		return nil, nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayEndpoint,
+			Message:    flux.ErrorMessageStaticFileSystemMissing,
+		}
+	}
+	name := service.GetAttr(ServiceAttrTagStaticFile).GetString()
+	if name == "" {
+		pathVar := service.GetAttr(ServiceAttrTagStaticPathVar).GetString()
+		if pathVar == "" {
+			pathVar = DefaultPathVar
+		}
+		name = ctx.PathVar(pathVar)
+	}
+	// Clean 保证路径不会越过根目录
+	name = path.Clean("/" + name)
+	file, stat, err := openFile(fs, name)
+	if err == nil && stat.IsDir() {
+		_ = file.Close()
+		index := service.GetAttr(ServiceAttrTagStaticIndex).GetString()
+		if index == "" {
+			index = DefaultIndexFile
+		}
+		file, stat, err = openFile(fs, path.Join(name, index))
+	}
+	if err != nil || stat.IsDir() {
+		if err == nil {
+			_ = file.Close()
+		}
+		return nil, nil, &flux.ServeError{
+			StatusCode: flux.StatusNotFound,
+			ErrorCode:  flux.ErrorCodeRequestNotFound,
+			Message:    flux.ErrorMessageStaticFileNotFound,
+			CauseError: err,
+		}
+	}
+	return file, stat, nil
+}
+
+func (b *RpcTransporter) lookupFileSystem(service flux.Service) (http.FileSystem, bool) {
+	if name := service.GetAttr(ServiceAttrTagStaticFs).GetString(); name != "" {
+		return FileSystemByName(name)
+	}
+	if root := service.GetAttr(ServiceAttrTagStaticRoot).GetString(); root != "" {
+		return http.Dir(root), true
+	}
+	return nil, false
+}
+
+func openFile(fs http.FileSystem, name string) (http.File, os.FileInfo, error) {
+	file, err := fs.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, nil, err
+	}
+	return file, stat, nil
+}
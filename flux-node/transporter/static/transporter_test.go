@@ -0,0 +1,66 @@
+package static
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/internal"
+	"github.com/labstack/echo/v4"
+	assert2 "github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newStaticContext(service flux.Service, name string) *flux.Context {
+	request := httptest.NewRequest("GET", "http://gw.com/static/"+name, nil)
+	webex := internal.NewServeWebContext(echo.New().NewContext(request, httptest.NewRecorder()), "static", nil)
+	webex.SetPathVars(url.Values{DefaultPathVar: []string{name}})
+	ctx := flux.NewContext()
+	ctx.Reset(webex, &flux.Endpoint{Service: service})
+	return ctx
+}
+
+func TestStaticPathTraversal(t *testing.T) {
+	assert := assert2.New(t)
+	dir, err := ioutil.TempDir("", "flux-static")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	root := filepath.Join(dir, "root")
+	assert.NoError(os.MkdirAll(filepath.Join(root, "docs"), 0755))
+	assert.NoError(ioutil.WriteFile(filepath.Join(root, "index.html"), []byte("index"), 0644))
+	assert.NoError(ioutil.WriteFile(filepath.Join(root, "docs", "a.txt"), []byte("a"), 0644))
+	assert.NoError(ioutil.WriteFile(filepath.Join(dir, "secret.yml"), []byte("secret"), 0644))
+	service := flux.Service{EmbeddedAttributes: flux.EmbeddedAttributes{Attributes: []flux.Attribute{
+		{Name: flux.ServiceAttrTagRpcProto, Value: flux.ProtoStatic},
+		{Name: ServiceAttrTagStaticRoot, Value: root},
+	}}}
+	cases := []struct {
+		name     string
+		expected string
+	}{
+		{name: "", expected: "index"},
+		{name: "docs/a.txt", expected: "a"},
+		{name: "docs/../index.html", expected: "index"},
+		// 越过根目录的路径，按根目录处理
+		{name: "../secret.yml"},
+		{name: "../../root/../secret.yml"},
+		{name: "docs/../../secret.yml"},
+		{name: "/../secret.yml"},
+		{name: "..\\secret.yml"},
+		{name: "docs"},
+	}
+	transporter := NewTransporter()
+	for _, c := range cases {
+		data, serr := transporter.Invoke(newStaticContext(service, c.name), service)
+		if c.expected == "" {
+			if assert.NotNil(serr, c.name) {
+				assert.Equal(flux.StatusNotFound, serr.StatusCode, c.name)
+			}
+			continue
+		}
+		assert.Nil(serr, c.name)
+		assert.Equal(c.expected, string(data.([]byte)), c.name)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"reflect"
 	"strconv"
 	"sync"
+	"time"
 )
 
 const (
@@ -50,11 +51,23 @@ func (se *Engine) Remove(scriptId string) {
 
 // EvalScriptId 执行指定ScriptId的脚本，执行指定函数；
 func (se *Engine) EvalScriptId(scriptId string, entryFun string, context interface{}) (v interface{}, err error) {
+	return se.EvalScriptIdTimeout(scriptId, entryFun, context, 0)
+}
+
+// EvalScriptIdTimeout 执行指定ScriptId的脚本，执行指定函数；执行超过指定时长时，中断脚本并返回错误；
+// 时长小于等于0时不限制执行时间。
+func (se *Engine) EvalScriptIdTimeout(scriptId string, entryFun string, context interface{}, timeout time.Duration) (v interface{}, err error) {
 	prop, ok := se.scripts.Load(scriptId)
 	if !ok || prop == nil {
 		return nil, fmt.Errorf("script not found, script-id: %s", scriptId)
 	}
 	runtime := goja.New()
+	if timeout > 0 {
+		timer := time.AfterFunc(timeout, func() {
+			runtime.Interrupt(fmt.Sprintf("execution timeout: %s", timeout))
+		})
+		defer timer.Stop()
+	}
 	_, rerr := runtime.RunProgram(prop.(*goja.Program))
 	if nil != rerr {
 		return nil, fmt.Errorf("compile script, error: %w", rerr)
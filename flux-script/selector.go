@@ -0,0 +1,112 @@
+package fluxscript
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"sync"
+	"time"
+)
+
+// Script选择器属性定义在Endpoint上，多个版本定义时，以版本号顺序的第一个为准
+const (
+	// 版本选择脚本；脚本的entry函数返回需要路由的版本号
+	EndpointAttrTagSelectorScript = "selectorScript"
+	// 脚本执行失败、超时或返回的版本不存在时，使用的兜底版本
+	EndpointAttrTagSelectorFallback = "selectorFallback"
+)
+
+const (
+	DefaultSelectorTimeout = time.Millisecond * 50
+)
+
+var _ flux.EndpointSelector = new(ScriptSelector)
+
+// NewScriptSelector 创建脚本版本选择器；timeout为脚本执行时长限制，fallback为默认的兜底版本；
+// 请求指定版本Header时，不参与选择。
+func NewScriptSelector(timeout time.Duration, fallback string, versionHeader string) *ScriptSelector {
+	if timeout <= 0 {
+		timeout = DefaultSelectorTimeout
+	}
+	return &ScriptSelector{engine: NewEngine(), timeout: timeout, fallback: fallback, versionHeader: versionHeader}
+}
+
+// ScriptSelector 执行Endpoint定义的JavaScript脚本，根据脚本返回的版本号选择Endpoint；
+// 脚本编译后缓存，按脚本内容复用；编译失败的脚本同样缓存，不重复编译。
+type ScriptSelector struct {
+	engine        *Engine
+	timeout       time.Duration
+	fallback      string
+	versionHeader string
+	failures      sync.Map // 编译失败的脚本：ScriptId -> error
+}
+
+func (s *ScriptSelector) Active(webex flux.ServerWebContext, _ string) bool {
+	return s.versionHeader == "" || webex.HeaderVar(s.versionHeader) == ""
+}
+
+func (s *ScriptSelector) DoSelect(webex flux.ServerWebContext, _ string, multi *flux.MVCEndpoint) (flux.Endpoint, bool) {
+	endpoints := multi.Endpoints()
+	var source, fallback, pattern string
+	for _, ep := range endpoints {
+		if source = ep.GetAttr(EndpointAttrTagSelectorScript).GetString(); source != "" {
+			fallback = ep.GetAttr(EndpointAttrTagSelectorFallback).GetString()
+			pattern = ep.HttpPattern
+			break
+		}
+	}
+	if source == "" {
+		return flux.Endpoint{}, false
+	}
+	if fallback == "" {
+		fallback = s.fallback
+	}
+	version, err := s.eval(webex, source, pattern)
+	if err != nil {
+		logger.Trace(webex.RequestId()).Warnw("SELECTOR:SCRIPT:EVAL", "fallback", fallback, "error", err)
+		return findVersion(endpoints, fallback)
+	}
+	if ep, ok := findVersion(endpoints, version); ok {
+		return ep, true
+	}
+	return findVersion(endpoints, fallback)
+}
+
+func (s *ScriptSelector) eval(webex flux.ServerWebContext, source, pattern string) (string, error) {
+	id, err := s.load(source)
+	if err != nil {
+		return "", err
+	}
+	v, err := s.engine.EvalScriptIdTimeout(id, ScriptEntryFunName, NewScriptContext(webex, pattern), s.timeout)
+	if err != nil {
+		return "", err
+	}
+	if version, ok := v.(string); ok {
+		return version, nil
+	}
+	return "", nil
+}
+
+// load 编译并缓存脚本；编译失败时缓存错误
+func (s *ScriptSelector) load(source string) (string, error) {
+	key := s.engine.scriptId([]byte(source))
+	if err, ok := s.failures.Load(key); ok {
+		return "", err.(error)
+	}
+	id, err := s.engine.Load(source)
+	if err != nil {
+		s.failures.Store(key, err)
+	}
+	return id, err
+}
+
+func findVersion(endpoints []*flux.Endpoint, version string) (flux.Endpoint, bool) {
+	if version == "" {
+		return flux.Endpoint{}, false
+	}
+	for _, ep := range endpoints {
+		if ep.Version == version {
+			return *ep, true
+		}
+	}
+	return flux.Endpoint{}, false
+}
@@ -0,0 +1,68 @@
+package fluxscript
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func newSelectorEndpoints(script string) *flux.MVCEndpoint {
+	v1 := &flux.Endpoint{Version: "v1", HttpPattern: "/test/script"}
+	v2 := &flux.Endpoint{Version: "v2", HttpPattern: "/test/script",
+		EmbeddedAttributes: flux.EmbeddedAttributes{Attributes: []flux.Attribute{
+			{Name: EndpointAttrTagSelectorScript, Value: script},
+		}},
+	}
+	multi := flux.NewMVCEndpoint(v1)
+	multi.Update(v2.Version, v2)
+	return multi
+}
+
+func TestScriptSelector(t *testing.T) {
+	asserter := assert.New(t)
+	ext.SetLoggerFactory(logger.DefaultFactory)
+	selector := NewScriptSelector(time.Millisecond*50, "v1", "X-Version")
+	webex := common.MockWebContext("script")
+	webex.Request().Header.Set("X-Env", "beta")
+	// 按脚本返回的版本选择
+	ep, ok := selector.DoSelect(webex, "", newSelectorEndpoints(`
+function entry(ctx) {
+	return ctx.getHeaderVar("X-Env") === "beta" ? "v2" : "v1";
+}`))
+	asserter.True(ok)
+	asserter.Equal("v2", ep.Version)
+	// 版本不存在，使用兜底版本
+	ep, ok = selector.DoSelect(webex, "", newSelectorEndpoints(`function entry(ctx) { return "v9"; }`))
+	asserter.True(ok)
+	asserter.Equal("v1", ep.Version)
+	// 执行超时，使用兜底版本
+	start := time.Now()
+	ep, ok = selector.DoSelect(webex, "", newSelectorEndpoints(`function entry(ctx) { while (true) {} }`))
+	asserter.True(ok)
+	asserter.Equal("v1", ep.Version)
+	asserter.True(time.Since(start) < time.Second)
+	// 编译失败，使用兜底版本，并缓存编译错误
+	for i := 0; i < 2; i++ {
+		ep, ok = selector.DoSelect(webex, "", newSelectorEndpoints(`function entry(ctx) {`))
+		asserter.True(ok)
+		asserter.Equal("v1", ep.Version)
+	}
+	failures := 0
+	selector.failures.Range(func(_, _ interface{}) bool {
+		failures++
+		return true
+	})
+	asserter.Equal(1, failures)
+	// 请求指定版本时，不参与选择
+	asserter.True(selector.Active(webex, ""))
+	webex.Request().Header.Set("X-Version", "v2")
+	asserter.False(selector.Active(webex, ""))
+	asserter.True(NewScriptSelector(0, "", "").Active(webex, ""))
+	// 未定义脚本
+	_, ok = selector.DoSelect(webex, "", flux.NewMVCEndpoint(&flux.Endpoint{Version: "v1"}))
+	asserter.False(ok)
+}